@@ -4,16 +4,25 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/jwt"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
 )
 
 func main() {
+	// "revoke" is the only subcommand, any other invocation generates a token as it always did
+	if len(os.Args) > 1 && os.Args[1] == "revoke" {
+		revoke(os.Args[2:])
+		return
+	}
+
 	privateKeyPath := flag.String("private-key", "", "Path to the RSA private key file (mandatory)")
 	subject := flag.String("sub", "", "Subject claim for the JWT (optional)")
 	expiration := flag.String("exp", "", "Expiration duration for the JWT in hours (optional)")
 	kid := flag.String("kid", "", "Key ID (kid) for the JWT (mandatory)")
+	jti := flag.String("jti", "", "JWT ID (jti) claim, used to revoke the token (optional, random if not set)")
 	outputFilePath := flag.String("output", "token.jwt", "Output file path for the JWT")
 
 	flag.Parse()
@@ -22,7 +31,7 @@ func main() {
 		logger.Fatal("Key ID (kid) and private key path must be provided")
 	}
 
-	tokenString, err := jwt.GenerateJWT(*kid, *privateKeyPath, *subject, *expiration)
+	tokenString, err := jwt.GenerateJWT(*kid, *privateKeyPath, *subject, *expiration, *jti)
 	if err != nil {
 		logger.Fatal(fmt.Sprintf("Error generating JWT: %v", err))
 	}
@@ -37,3 +46,55 @@ func main() {
 
 	fmt.Println("JWT saved to file:", *outputFilePath)
 }
+
+// revoke adds a jti, or a kid with an "issued before" time, to the revocation file read by the listener
+func revoke(args []string) {
+	revokeCmd := flag.NewFlagSet("revoke", flag.ExitOnError)
+	revocationFilePath := revokeCmd.String("revocation-file", "", "Path to the JWT revocation file (mandatory)")
+	jti := revokeCmd.String("jti", "", "JWT ID (jti) of the token to revoke")
+	kid := revokeCmd.String("kid", "", "Key ID (kid) whose tokens will be revoked")
+	issuedBefore := revokeCmd.String("issued-before", "", "Revoke the kid tokens issued before this time, RFC3339 or unix seconds (default: now)")
+	reason := revokeCmd.String("reason", "", "Reason of the revocation (optional)")
+
+	revokeCmd.Parse(args)
+
+	if *revocationFilePath == "" {
+		logger.Fatal("Revocation file path must be provided")
+	}
+	if (*jti == "") == (*kid == "") {
+		logger.Fatal("Exactly one of jti or kid must be provided")
+	}
+
+	revocationList, err := jwt.LoadRevocationList(*revocationFilePath)
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Failed to load revocation file: %v", err))
+	}
+
+	if *jti != "" {
+		revocationList.RevokeJti(*jti, *reason)
+		fmt.Println("Revoked token with jti:", *jti)
+	} else {
+		issuedBeforeTime, err := parseIssuedBefore(*issuedBefore)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Invalid issued-before value: %v", err))
+		}
+		revocationList.RevokeKid(*kid, issuedBeforeTime, *reason)
+		fmt.Printf("Revoked tokens of kid %s issued before %s\n", *kid, issuedBeforeTime.UTC().Format(time.RFC3339))
+	}
+
+	if err := jwt.SaveRevocationList(*revocationFilePath, revocationList); err != nil {
+		logger.Fatal(fmt.Sprintf("Failed to write revocation file: %v", err))
+	}
+	fmt.Println("Revocation file saved:", *revocationFilePath)
+}
+
+// parseIssuedBefore accepts RFC3339 or unix seconds. An empty value means now.
+func parseIssuedBefore(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if unixSecs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unixSecs, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
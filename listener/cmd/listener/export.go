@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/maintenance"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// runExport writes the stored signatures as JSON lines, to a file or stdout
+func runExport(args []string) error {
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	output := exportCmd.String("output", "", "Output file (default: stdout)")
+	network := exportCmd.String("network", "", "Export only this network (default: every network)")
+	tag := exportCmd.String("tag", "", "Export only this tag (default: every tag)")
+	exportCmd.Parse(args)
+
+	filter := store.Filter{}
+	if *network != "" {
+		filter.Networks = []string{*network}
+	}
+	if *tag != "" {
+		filter.Tags = []string{*tag}
+	}
+
+	config, err := loadConfig(config.ScopeDatabase)
+	if err != nil {
+		return err
+	}
+	signatureStore, closeStore, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := maintenance.ExportSignatures(ctx, signatureStore, filter, w)
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Exported %d documents", count))
+	return nil
+}
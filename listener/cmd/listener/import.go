@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/maintenance"
+)
+
+// runImport reads the JSON lines written by export, from a file or stdin
+func runImport(args []string) error {
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	input := importCmd.String("input", "", "Input file (default: stdin)")
+	importCmd.Parse(args)
+
+	config, err := loadConfig(config.ScopeDatabase)
+	if err != nil {
+		return err
+	}
+	signatureStore, closeStore, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	count, err := maintenance.ImportSignatures(ctx, signatureStore, r)
+	logger.Info(fmt.Sprintf("Imported %d documents", count))
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/jwt"
+)
+
+// runJwt runs the jwt subcommands. Only generate for now, revocations are managed with the jwt-generator revoke command
+func runJwt(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return fmt.Errorf("usage: listener jwt generate [flags]")
+	}
+
+	generateCmd := flag.NewFlagSet("jwt generate", flag.ExitOnError)
+	privateKeyPath := generateCmd.String("private-key", "", "Path to the RSA private key file (mandatory)")
+	subject := generateCmd.String("sub", "", "Subject claim for the JWT (optional)")
+	expiration := generateCmd.String("exp", "", "Expiration duration for the JWT in hours (optional)")
+	kid := generateCmd.String("kid", "", "Key ID (kid) for the JWT (mandatory)")
+	jti := generateCmd.String("jti", "", "JWT ID (jti) claim, used to revoke the token (optional, random if not set)")
+	outputFilePath := generateCmd.String("output", "", "Output file path for the JWT (default: stdout only)")
+	generateCmd.Parse(args[1:])
+
+	if *kid == "" || *privateKeyPath == "" {
+		return fmt.Errorf("key ID (kid) and private key path must be provided")
+	}
+
+	tokenString, err := jwt.GenerateJWT(*kid, *privateKeyPath, *subject, *expiration, *jti)
+	if err != nil {
+		return fmt.Errorf("error generating JWT: %w", err)
+	}
+	fmt.Println(tokenString)
+
+	if *outputFilePath != "" {
+		if err := os.WriteFile(*outputFilePath, []byte(tokenString), 0644); err != nil {
+			return fmt.Errorf("failed to write the JWT to file: %w", err)
+		}
+	}
+	return nil
+}
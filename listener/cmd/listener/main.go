@@ -1,95 +1,61 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
-	"github.com/herumi/bls-eth-go-binary/bls"
-	"github.com/robfig/cron"
-
-	"github.com/dappnode/validator-monitoring/listener/internal/api"
-	"github.com/dappnode/validator-monitoring/listener/internal/config"
-	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron" // Renamed to avoid conflict with the cron/v3 package
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
-	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
 )
 
+// command is a subcommand of the listener binary, it receives the arguments after its name
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"serve":      {"Start the API and the crons (default)", runServe},
+	"prune":      {"Remove the signatures older than the retention period", runPrune},
+	"revalidate": {"Verify again the stored signatures and refresh the validators status", runRevalidate},
+	"migrate":    {"Manage the schema migrations: migrate [up|status|down]", runMigrate},
+	"export":     {"Export the stored signatures as JSON lines", runExport},
+	"import":     {"Import the signatures exported with export", runImport},
+	"jwt":        {"Manage JWTs: jwt generate", runJwt},
+	"users":      {"Manage the JWT users file: users validate", runUsers},
+}
+
+// commandOrder is the order the commands are listed in the usage
+var commandOrder = []string{"serve", "prune", "revalidate", "migrate", "export", "import", "jwt", "users"}
+
 func main() {
-	logger.Info("Starting listener")
-	// Load config
-	config, err := config.GetConfig()
-	if err != nil {
-		logger.Fatal("Failed to load config: " + err.Error())
+	// Without a command the listener serves the API, as it always did
+	name := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
 	}
-	logger.SetLogLevelFromString(config.LogLevel)
 
-	// This is a configuration of the BLS library at the process level. Notice how bls.Init() does not return an initialized BLS object.
-	// Any call to bls functions within the process will use this configuration. We initialize bls before starting the api.
-	if err := bls.Init(bls.BLS12_381); err != nil {
-		logger.Fatal("Failed to initialize BLS: " + err.Error())
-	}
-	if err := bls.SetETHmode(bls.EthModeDraft07); err != nil {
-		logger.Fatal("Failed to set BLS ETH mode: " + err.Error())
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
 	}
-
-	// Connect to MongoDB client & get the collection
-	dbClient, err := mongodb.GetMongoDbClient(config.MongoDBURI)
-	if err != nil {
-		logger.Fatal("Failed to connect to MongoDB: " + err.Error())
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage()
+		os.Exit(2)
 	}
-	dbCollection := dbClient.Database("validatorMonitoring").Collection("signatures")
-	if dbCollection == nil {
-		logger.Fatal("Failed to connect to MongoDB collection")
+	if err := cmd.run(args); err != nil {
+		logger.Fatal(name + " failed: " + err.Error())
 	}
+}
 
-	s := api.NewApi(
-		config.Port,
-		dbClient,
-		dbCollection,
-		config.BeaconNodeURLs,
-		config.MaxEntriesPerBson,
-		config.JWTUsersFilePath,
-	)
-
-	// Start the API server in a goroutine. Needs to be in a goroutine to allow for the cron job to run,
-	// otherwise it blocks the main goroutine
-	go func() {
-		s.Start()
-	}()
-
-	// Set up the cron job
-	c := cron.New()
-
-	// The cron job runs once a day, see https://github.com/robfig/cron/blob/master/doc.go
-	// to test it running once a minute, replace "@daily" for "* * * * *"
-	c.AddFunc("@daily", func() {
-		// there are 24 * 30 = 720 hours in 30 days
-		apiCron.RemoveOldSignatures(dbCollection, 720)
-
-	})
-	c.AddFunc("@every 1m", func() {
-		apiCron.UpdateSignaturesStatus(dbCollection, config.BeaconNodeURLs)
-	})
-	c.Start()
-
-	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan // Block until a signal is received
-
-	// Stop the cron job
-	c.Stop()
-
-	// Shutdown the HTTP server gracefully with a given timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := s.Shutdown(ctx); err != nil {
-		logger.Error("Failed to shut down server gracefully: " + fmt.Sprintln(err))
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: listener <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].description)
 	}
-
-	logger.Info("Listener stopped gracefully")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Every command reads the same config as serve, and only validates the settings it uses. Run listener <command> -h for its flags.")
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/migrations"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// runMigrate manages the schema migrations of the database: up (default), status and down
+func runMigrate(args []string) error {
+	action := "up"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		action, args = args[0], args[1:]
+	}
+
+	migrateCmd := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	target := migrateCmd.Int("to", 0, "Target version. up: apply up to this version (default: latest). down: roll back the versions above it (mandatory)")
+	migrateCmd.Parse(args)
+
+	config, err := loadConfig(config.ScopeDatabase)
+	if err != nil {
+		return err
+	}
+	if config.Database == store.DatabasePostgres {
+		return runPostgresMigrate(migrateCmd, action, *target, config)
+	}
+	dbClient, dbCollection, err := connectCollection(config)
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect(context.Background())
+	ctx := context.Background()
+
+	switch action {
+	case "up":
+		applied, err := migrations.Up(ctx, dbCollection, migrationSettings(config), *target)
+		logger.Info(fmt.Sprintf("Applied migrations: %v", applied))
+		return err
+	case "down":
+		if !isFlagSet(migrateCmd, "to") {
+			return fmt.Errorf("migrate down requires --to, use --to 0 to roll back every migration")
+		}
+		rolledBack, err := migrations.Down(ctx, dbCollection, migrationSettings(config), *target)
+		logger.Info(fmt.Sprintf("Rolled back migrations: %v", rolledBack))
+		return err
+	case "status":
+		statuses, err := migrations.Status(ctx, dbCollection)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatuses(statuses)
+	default:
+		return fmt.Errorf("unknown migrate action %s, must be up, down or status", action)
+	}
+}
+
+// runPostgresMigrate runs the migrate action on the Postgres schema
+func runPostgresMigrate(migrateCmd *flag.FlagSet, action string, target int, config *config.Config) error {
+	pool, err := connectPostgres(config)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	ctx := context.Background()
+
+	switch action {
+	case "up":
+		applied, err := migrations.PostgresUp(ctx, pool, target)
+		logger.Info(fmt.Sprintf("Applied migrations: %v", applied))
+		return err
+	case "down":
+		if !isFlagSet(migrateCmd, "to") {
+			return fmt.Errorf("migrate down requires --to, use --to 0 to roll back every migration")
+		}
+		rolledBack, err := migrations.PostgresDown(ctx, pool, target)
+		logger.Info(fmt.Sprintf("Rolled back migrations: %v", rolledBack))
+		return err
+	case "status":
+		statuses, err := migrations.PostgresStatus(ctx, pool)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatuses(statuses)
+	default:
+		return fmt.Errorf("unknown migrate action %s, must be up, down or status", action)
+	}
+}
+
+func printMigrationStatuses(statuses []migrations.MigrationStatus) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		description := status.Description
+		if status.StorageMode != "" {
+			description += " (" + status.StorageMode + " storage mode only)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, appliedAt, description)
+	}
+	return w.Flush()
+}
+
+func isFlagSet(flagSet *flag.FlagSet, name string) bool {
+	set := false
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+// runPrune removes the old signatures once, as the daily cron does
+func runPrune(args []string) error {
+	pruneCmd := flag.NewFlagSet("prune", flag.ExitOnError)
+	hours := pruneCmd.Int("older-than-hours", 0, "Remove the signatures older than this number of hours (default: signatureRetentionHours)")
+	pruneCmd.Parse(args)
+
+	if *hours < 0 {
+		return fmt.Errorf("older-than-hours must be positive, got %d", *hours)
+	}
+
+	config, err := loadConfig(config.ScopeDatabase)
+	if err != nil {
+		return err
+	}
+	if *hours == 0 {
+		*hours = config.SignatureRetentionHours
+	}
+	signatureStore, closeStore, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	deleted, err := apiCron.RemoveOldSignatures(ctx, signatureStore, *hours)
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Removed %d documents with signatures older than %d hours", deleted, *hours))
+	return nil
+}
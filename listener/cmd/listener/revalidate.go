@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/maintenance"
+)
+
+// runRevalidate verifies again every stored signature and refreshes the status of the validators
+func runRevalidate(args []string) error {
+	revalidateCmd := flag.NewFlagSet("revalidate", flag.ExitOnError)
+	network := revalidateCmd.String("network", "", "Revalidate only this network (default: every enabled network)")
+	removeInactive := revalidateCmd.Bool("remove-inactive", false, "Also remove the active validators that became inactive (default: only the ones with status unknown, as the cron does)")
+	dryRun := revalidateCmd.Bool("dry-run", false, "Only report what would be removed or updated")
+	revalidateCmd.Parse(args)
+
+	config, err := loadConfig(config.ScopeDatabase | config.ScopeNetworks)
+	if err != nil {
+		return err
+	}
+	if err := initBLS(); err != nil {
+		return err
+	}
+
+	beaconNodeUrls := config.BeaconNodeURLs
+	if *network != "" {
+		urls, ok := config.BeaconNodeURLs[types.Network(*network)]
+		if !ok {
+			return fmt.Errorf("network %s is not enabled", *network)
+		}
+		beaconNodeUrls = map[types.Network][]string{types.Network(*network): urls}
+	}
+
+	signatureStore, closeStore, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := maintenance.RevalidateSignatures(ctx, signatureStore, beaconNodeUrls, config.BeaconNodeTimeout(), *removeInactive, *dryRun)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if *dryRun {
+		prefix = "Dry run: "
+	}
+	logger.Info(fmt.Sprintf("%sChecked %d documents: %d invalid entries, %d documents removed, %d documents set active",
+		prefix, result.Documents, result.InvalidEntries, result.RemovedDocuments, result.ActivatedDocuments))
+	return nil
+}
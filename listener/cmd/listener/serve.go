@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/routes"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// shutdownTimeout is how long the requests in flight and the running cron jobs have to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
+// cronLeaseName is the lease held by the replica that runs the cron jobs
+const cronLeaseName = "crons"
+
+// runServe starts the API and the crons until the process receives SIGINT or SIGTERM
+func runServe(args []string) error {
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	serveCmd.Parse(args)
+
+	logger.Info("Starting listener")
+	config, err := loadConfig(config.ScopeAll)
+	if err != nil {
+		return err
+	}
+	if err := initBLS(); err != nil {
+		return err
+	}
+
+	// Check every beacon node is on the chain of its network before serving anything. A beacon node on the wrong
+	// chain would silently mark every validator as inactive
+	if err := validation.CheckBeaconNodesGenesis(context.Background(), config.BeaconNodeURLs, config.NetworkSpecs, config.BeaconNodeTimeout()); err != nil {
+		logger.Fatal("Failed to check beacon nodes: " + err.Error())
+	}
+
+	// The users file is reloaded when it changes, but the listener does not start without a valid one
+	usersFile, err := middleware.NewUsersFile(config.JWTUsersFilePath, config.Tags, config.KnownNetworks())
+	if err != nil {
+		logger.Fatal("Failed to load JWT users file: " + err.Error())
+	}
+	// Revocation is enabled by the revocation file, which may not exist until the first token is revoked
+	var revocationFile *middleware.RevocationFile
+	if config.JWTRevocationFilePath != "" {
+		revocationFile, err = middleware.NewRevocationFile(config.JWTRevocationFilePath)
+		if err != nil {
+			logger.Fatal("Failed to load JWT revocation file: " + err.Error())
+		}
+	}
+	// Submitter auth is enabled by the submitters file, reloaded the same way
+	var submittersFile *middleware.SubmittersFile
+	if config.SubmittersFilePath != "" {
+		submittersFile, err = middleware.NewSubmittersFile(config.SubmittersFilePath, config.Tags)
+		if err != nil {
+			logger.Fatal("Failed to load submitters file: " + err.Error())
+		}
+	}
+
+	// Connect to the database & get the signature store
+	signatureStore, leases, runs, closeStore, err := openStoreWithLeases(config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	// The queries and the uniqueness of the documents rely on these indexes
+	if err := signatureStore.EnsureIndexes(context.Background()); err != nil {
+		return err
+	}
+	// A slow database fails the operation instead of piling up requests and cron jobs waiting for it
+	signatureStore = store.WithTimeout(signatureStore, time.Duration(config.DatabaseTimeoutSeconds)*time.Second)
+
+	// ctx is cancelled when the requests in flight and the running cron jobs must be aborted, once the shutdown
+	// timeout expires
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every replica schedules the cron jobs, but only the holder of the cron lease runs them
+	elector := leader.NewElector(leases, cronLeaseName, time.Duration(config.CronLeaseTTLSeconds)*time.Second)
+
+	// Set up the cron jobs, their schedules are in the config
+	scheduler := apiCron.NewScheduler(ctx, elector, runs)
+	for _, job := range cronJobs(config, signatureStore) {
+		if err := scheduler.Add(job); err != nil {
+			return err
+		}
+	}
+
+	s := api.NewApi(
+		config.Port,
+		signatureStore,
+		config.BeaconNodeURLs,
+		validation.NewValidatorsStatusCache(time.Duration(config.ValidatorStatusCacheTTLSeconds)*time.Second, config.BeaconNodeTimeout()),
+		usersFile,
+		revocationFile,
+		submittersFile,
+		routes.Limits{
+			MaxBodyBytes:            config.MaxBodyBytes,
+			MaxSignaturesPerRequest: config.MaxSignaturesPerRequest,
+			RateLimitRPS:            config.RateLimitRPS,
+			RateLimitBurst:          config.RateLimitBurst,
+			TrustedProxies:          config.TrustedProxyPrefixes(),
+		},
+		api.TLSConfig{
+			CertFile:     config.TLSCertFile,
+			KeyFile:      config.TLSKeyFile,
+			ClientCAFile: config.TLSClientCAFile,
+		},
+		api.Timeouts{
+			ReadHeader: time.Duration(config.HTTPReadHeaderTimeoutSeconds) * time.Second,
+			Read:       time.Duration(config.HTTPReadTimeoutSeconds) * time.Second,
+			Write:      time.Duration(config.HTTPWriteTimeoutSeconds) * time.Second,
+			Idle:       time.Duration(config.HTTPIdleTimeoutSeconds) * time.Second,
+		},
+		config.Tags,
+		config.ProofRegistry,
+		scheduler,
+	)
+
+	// The API serves in the background. It is started first, so a bad certificate or a port in use stops the listener
+	// before it takes the cron lease
+	if err := s.Start(ctx); err != nil {
+		return err
+	}
+	electorDone := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(electorDone)
+	}()
+	scheduler.Start()
+
+	// Set up signal handling for graceful shutdown. The listener also shuts down if the API stops serving
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	var serveErr error
+	select {
+	case <-sigChan:
+	case serveErr = <-s.Err():
+		logger.Error("API server stopped: " + serveErr.Error())
+	}
+	logger.Info(fmt.Sprintf("Shutting down, waiting up to %s for the requests in flight and the running cron jobs", shutdownTimeout))
+
+	// Stop scheduling cron jobs and the HTTP server at the same time, both wait for the work in progress
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+	cronDone := scheduler.Stop()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down server gracefully: " + fmt.Sprintln(err))
+	}
+	select {
+	case <-cronDone.Done():
+	case <-shutdownCtx.Done():
+		logger.Warn("Cron jobs still running after the shutdown timeout, cancelling them")
+	}
+
+	// Whatever is still running is cancelled, it stops before its next database operation
+	cancel()
+	select {
+	case <-cronDone.Done():
+	case <-time.After(5 * time.Second):
+		logger.Error("Cron jobs did not stop after being cancelled")
+	}
+	// the lease is released before closing the database, so another replica takes over right away
+	<-electorDone
+
+	if serveErr != nil {
+		return serveErr
+	}
+	logger.Info("Listener stopped gracefully")
+	return nil
+}
+
+// cronJobs returns the cron jobs with their schedules of the config
+func cronJobs(cfg *config.Config, signatureStore store.SignatureStore) []apiCron.Job {
+	return []apiCron.Job{
+		{
+			Name:     "removeOldSignatures",
+			Schedule: cfg.Crons.RemoveOldSignatures.Schedule,
+			Enabled:  cfg.Crons.RemoveOldSignatures.IsEnabled(),
+			Timeout:  cfg.Crons.RemoveOldSignatures.Timeout(),
+			Run: func(ctx context.Context) (map[string]int64, error) {
+				deleted, err := apiCron.RemoveOldSignatures(ctx, signatureStore, cfg.SignatureRetentionHours)
+				return map[string]int64{"removed": deleted}, err
+			},
+		},
+		{
+			Name:     "updateSignaturesStatus",
+			Schedule: cfg.Crons.UpdateSignaturesStatus.Schedule,
+			Enabled:  cfg.Crons.UpdateSignaturesStatus.IsEnabled(),
+			Timeout:  cfg.Crons.UpdateSignaturesStatus.Timeout(),
+			Run: func(ctx context.Context) (map[string]int64, error) {
+				result, err := apiCron.UpdateSignaturesStatus(ctx, signatureStore, cfg.BeaconNodeURLs, cfg.BeaconNodeTimeout())
+				return map[string]int64{"unknown": int64(result.Unknown), "activated": int64(result.Activated), "removed": int64(result.Removed)}, err
+			},
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/migrations"
+	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
+	"github.com/dappnode/validator-monitoring/listener/internal/postgres"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// loadConfig loads the config shared by every command, validating only the settings of the scopes the command uses,
+// and sets the log level
+func loadConfig(scopes config.Scope) (*config.Config, error) {
+	cfg, err := config.GetConfig(scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	logger.SetLogLevelFromString(cfg.LogLevel)
+	return cfg, nil
+}
+
+// initBLS configures the BLS library at the process level. Notice how bls.Init() does not return an initialized BLS object.
+// Any call to bls functions within the process will use this configuration. We initialize bls before verifying any signature.
+func initBLS() error {
+	if err := bls.Init(bls.BLS12_381); err != nil {
+		return fmt.Errorf("failed to initialize BLS: %w", err)
+	}
+	if err := bls.SetETHmode(bls.EthModeDraft07); err != nil {
+		return fmt.Errorf("failed to set BLS ETH mode: %w", err)
+	}
+	return nil
+}
+
+// interruptContext returns a context cancelled on SIGINT or SIGTERM, so a maintenance command stops before its next
+// database operation instead of being killed in the middle of one
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// connectCollection connects to MongoDB and returns the signatures collection
+func connectCollection(cfg *config.Config) (*mongo.Client, *mongo.Collection, error) {
+	dbClient, err := mongodb.GetMongoDbClient(cfg.MongoDBClientConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	return dbClient, dbClient.Database(cfg.MongoDBDatabase).Collection(cfg.MongoDBCollection), nil
+}
+
+// connectPostgres connects to the Postgres database
+func connectPostgres(cfg *config.Config) (*pgxpool.Pool, error) {
+	pool, err := postgres.GetPostgresPool(cfg.PostgresURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+	return pool, nil
+}
+
+// openStore connects to the configured database and returns its signature store, and the function that closes the
+// connection. Documents or rows of an older schema would be served and updated wrongly, so the schema must be up to date
+func openStore(cfg *config.Config) (store.SignatureStore, func(), error) {
+	signatureStore, _, _, closeStore, err := openStoreWithLeases(cfg)
+	return signatureStore, closeStore, err
+}
+
+// openStoreWithLeases is openStore that also returns the lease and run stores of the same database, used to elect the
+// replica that runs the crons and to share their last runs
+func openStoreWithLeases(cfg *config.Config) (store.SignatureStore, leader.LeaseStore, leader.RunStore, func(), error) {
+	ctx := context.Background()
+	if cfg.Database == store.DatabasePostgres {
+		pool, err := connectPostgres(cfg)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if err := migrations.CheckPostgresSchema(ctx, pool); err != nil {
+			pool.Close()
+			return nil, nil, nil, nil, err
+		}
+		return store.NewPostgresStore(pool), leader.NewPostgresLeaseStore(pool), leader.NewPostgresRunStore(pool), pool.Close, nil
+	}
+
+	dbClient, dbCollection, err := connectCollection(cfg)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	closeClient := func() {
+		// Disconnect waits for the operations in progress, up to the timeout
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := dbClient.Disconnect(ctx); err != nil {
+			logger.Error("Failed to disconnect from MongoDB: " + err.Error())
+			return
+		}
+		logger.Info("Disconnected from MongoDB")
+	}
+	if err := migrations.CheckSchema(ctx, dbCollection, migrationSettings(cfg)); err != nil {
+		closeClient()
+		return nil, nil, nil, nil, err
+	}
+	// The time-series store refuses to open while documents still have their entries embedded
+	signatureStore, err := store.NewMongoStore(ctx, cfg.StorageMode, dbCollection, cfg.MaxEntriesPerBson, signatureRetention(cfg))
+	if err != nil {
+		closeClient()
+		return nil, nil, nil, nil, fmt.Errorf("failed to open the %s store: %w", cfg.StorageMode, err)
+	}
+	return signatureStore, leader.NewMongoLeaseStore(dbCollection), leader.NewMongoRunStore(dbCollection), closeClient, nil
+}
+
+func signatureRetention(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.SignatureRetentionHours) * time.Hour
+}
+
+// migrationSettings returns the settings of the config the MongoDB migrations depend on
+func migrationSettings(cfg *config.Config) migrations.Settings {
+	return migrations.Settings{StorageMode: cfg.StorageMode, MaxEntriesPerBson: cfg.MaxEntriesPerBson, Retention: signatureRetention(cfg)}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/config"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+// runUsers runs the users subcommands. validate checks the users file as the listener loads it at startup
+func runUsers(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("usage: listener users validate [flags]")
+	}
+
+	validateCmd := flag.NewFlagSet("users validate", flag.ExitOnError)
+	usersFilePath := validateCmd.String("file", "", "Path to the users file (default: the one of the config)")
+	validateCmd.Parse(args[1:])
+
+	config, err := loadConfig(config.ScopeUsers)
+	if err != nil {
+		return err
+	}
+	if *usersFilePath == "" {
+		*usersFilePath = config.JWTUsersFilePath
+	}
+
+	keyIds, err := middleware.LoadUsersFile(*usersFilePath, config.Tags, config.KnownNetworks())
+	if err != nil {
+		return fmt.Errorf("invalid users file %s: %w", *usersFilePath, err)
+	}
+	logger.Info(fmt.Sprintf("Users file %s is valid, %d kids", *usersFilePath, len(keyIds)))
+	return nil
+}
@@ -2,58 +2,126 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/routes"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 )
 
+// Timeouts are the timeouts of the HTTP server, see http.Server. Write is also the deadline of the work of a request
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
 type httpApi struct {
-	server            *http.Server
-	port              string
-	dbClient          *mongo.Client
-	dbCollection      *mongo.Collection
-	beaconNodeUrls    map[types.Network]string
-	maxEntriesPerBson int
-	jwtUsersFilePath  string
+	server                *http.Server
+	port                  string
+	signatureStore        store.SignatureStore
+	beaconNodeUrls        map[types.Network][]string
+	validatorsStatusCache *validation.ValidatorsStatusCache
+	usersFile             *middleware.UsersFile
+	revocationFile        *middleware.RevocationFile
+	submittersFile        *middleware.SubmittersFile
+	limits                routes.Limits
+	tlsConfig             TLSConfig
+	timeouts              Timeouts
+	tags                  types.TagRegistry
+	proofTypes            proofs.Registry
+	scheduler             *apiCron.Scheduler
+	// serveErr receives why the server stopped, nil once shut down
+	serveErr chan error
 }
 
 // create a new api instance
-func NewApi(port string, dbClient *mongo.Client, dbCollection *mongo.Collection, beaconNodeUrls map[types.Network]string, maxEntriesPerBson int, jwtUsersFilePath string) *httpApi {
+func NewApi(port string, signatureStore store.SignatureStore, beaconNodeUrls map[types.Network][]string, validatorsStatusCache *validation.ValidatorsStatusCache, usersFile *middleware.UsersFile, revocationFile *middleware.RevocationFile, submittersFile *middleware.SubmittersFile, limits routes.Limits, tlsConfig TLSConfig, timeouts Timeouts, tags types.TagRegistry, proofTypes proofs.Registry, scheduler *apiCron.Scheduler) *httpApi {
 	return &httpApi{
-		port:              port,
-		dbClient:          dbClient,
-		dbCollection:      dbCollection,
-		beaconNodeUrls:    beaconNodeUrls,
-		maxEntriesPerBson: maxEntriesPerBson,
-		jwtUsersFilePath:  jwtUsersFilePath,
+		port:                  port,
+		signatureStore:        signatureStore,
+		beaconNodeUrls:        beaconNodeUrls,
+		validatorsStatusCache: validatorsStatusCache,
+		usersFile:             usersFile,
+		revocationFile:        revocationFile,
+		submittersFile:        submittersFile,
+		limits:                limits,
+		tlsConfig:             tlsConfig,
+		timeouts:              timeouts,
+		tags:                  tags,
+		proofTypes:            proofTypes,
+		scheduler:             scheduler,
 	}
 }
 
-func (s *httpApi) Start() {
-	logger.Info("Server is running on port " + s.port)
-
+// Start listens on the port and serves the API in the background until Shutdown is called. The TLS setup and the port
+// are checked before returning, see Err for the errors once serving. ctx is the base context of every request,
+// cancelling it cancels the requests in flight
+func (s *httpApi) Start(ctx context.Context) error {
 	// if somehow s.server is not nil, it means the server is already running, this should never happen
 	if s.server != nil {
-		logger.Fatal("HTTP server already started")
+		return errors.New("HTTP server already started")
 	}
 
-	s.server = &http.Server{
-		Addr:    ":" + s.port,
-		Handler: routes.SetupRouter(s.dbCollection, s.beaconNodeUrls, s.maxEntriesPerBson, s.jwtUsersFilePath),
+	server := &http.Server{
+		Addr:        ":" + s.port,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+		Handler:     middleware.RequestTimeoutMiddleware(routes.SetupRouter(s.signatureStore, s.beaconNodeUrls, s.validatorsStatusCache, s.usersFile, s.revocationFile, s.submittersFile, s.limits, s.tlsConfig.ClientCAFile != "", s.tags, s.proofTypes, s.scheduler), s.timeouts.Write),
+		// without these a slow or idle client holds its connection and goroutine forever
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		ReadTimeout:       s.timeouts.Read,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
 	}
 
-	// ListenAndServe returns ErrServerClosed to indicate that the server has been shut down when the server is closed gracefully. We need to
-	// handle this error to avoid treating it as a fatal error. See https://pkg.go.dev/net/http#Server.ListenAndServe
-	err := s.server.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
-		logger.Fatal("Failed to start server: " + err.Error())
-	} else if err == http.ErrServerClosed {
-		logger.Info("Server closed gracefully")
+	if s.tlsConfig.CertFile != "" {
+		serverTLSConfig, err := newServerTLSConfig(s.tlsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to set up TLS: %w", err)
+		}
+		server.TLSConfig = serverTLSConfig
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %w", s.port, err)
 	}
+
+	s.server = server
+	s.serveErr = make(chan error, 1)
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			logger.Info("Server is running on port " + s.port + " over HTTPS")
+			// cert and key files are empty since the certificate is provided by TLSConfig.GetCertificate
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			logger.Info("Server is running on port " + s.port)
+			err = server.Serve(listener)
+		}
+		// Serve returns ErrServerClosed once the server is shut down gracefully, it is not an error. See
+		// https://pkg.go.dev/net/http#Server.Serve
+		if err == http.ErrServerClosed {
+			logger.Info("Server closed gracefully")
+			err = nil
+		}
+		s.serveErr <- err
+	}()
+	return nil
+}
+
+// Err returns a channel that receives why the server stopped serving after Start, nil if it was shut down
+func (s *httpApi) Err() <-chan error {
+	return s.serveErr
 }
 
 // Shutdown gracefully shuts down the server without interrupting any active connections
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+// TLSConfig are the paths of the files used to serve over HTTPS. TLS is disabled if CertFile is empty.
+// If ClientCAFile is set, the read endpoints require a client certificate signed by that CA (mTLS)
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// certReloader serves the certificate from disk, reloading it whenever the cert or key files change.
+// This way a renewed certificate (e.g. by certbot) is used without restarting the listener
+type certReloader struct {
+	certFile    string
+	keyFile     string
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) reload() error {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.certModTime = certInfo.ModTime()
+	c.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// isOutdated returns true if the cert or key files changed since they were loaded
+func (c *certReloader) isOutdated() bool {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !certInfo.ModTime().Equal(c.certModTime) || !keyInfo.ModTime().Equal(c.keyModTime)
+}
+
+// GetCertificate is used as tls.Config.GetCertificate. If reloading fails (e.g. the cert was written but
+// the key not yet) the previous certificate keeps being served
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c.isOutdated() {
+		if err := c.reload(); err != nil {
+			logger.Error("Failed to reload TLS certificate, keeping the previous one: " + err.Error())
+		} else {
+			logger.Info("TLS certificate reloaded")
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// newServerTLSConfig builds the tls.Config of the server. Client certificates are verified if given but not
+// required at the TLS level, since only the read endpoints require them
+func newServerTLSConfig(tlsConfig TLSConfig) (*tls.Config, error) {
+	reloader, err := newCertReloader(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	serverTLSConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if tlsConfig.ClientCAFile != "" {
+		caData, err := os.ReadFile(tlsConfig.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA file: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no valid certificates found in TLS client CA file %s", tlsConfig.ClientCAFile)
+		}
+		serverTLSConfig.ClientCAs = clientCAs
+		serverTLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return serverTLSConfig, nil
+}
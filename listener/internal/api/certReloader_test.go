@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/routes"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert returns a certificate signed by parent, or self-signed if parent is nil
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signerCert, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+// writeTestCert writes the cert and key files with a modification time, so a rewrite is always seen as a change
+func writeTestCert(t *testing.T, cert *testCert, certFile, keyFile string, modTime time.Time) {
+	t.Helper()
+	for file, data := range map[string][]byte{certFile: cert.certPEM, keyFile: cert.keyPEM} {
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func servedCommonName(t *testing.T, reloader *certReloader) string {
+	t.Helper()
+	cert, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+	writeTestCert(t, newTestCert(t, "first", nil), certFile, keyFile, now)
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := servedCommonName(t, reloader); name != "first" {
+		t.Fatalf("Expected the first certificate, got %s", name)
+	}
+
+	// a renewed certificate is served without restarting
+	writeTestCert(t, newTestCert(t, "second", nil), certFile, keyFile, now.Add(time.Minute))
+	if name := servedCommonName(t, reloader); name != "second" {
+		t.Fatalf("Expected the renewed certificate, got %s", name)
+	}
+
+	// a cert that does not match its key, e.g. written before the key, keeps the previous one
+	third := newTestCert(t, "third", nil)
+	if err := os.WriteFile(certFile, third.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(certFile, now.Add(2*time.Minute), now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if name := servedCommonName(t, reloader); name != "second" {
+		t.Fatalf("Expected the previous certificate while the key is not updated, got %s", name)
+	}
+	writeTestCert(t, third, certFile, keyFile, now.Add(3*time.Minute))
+	if name := servedCommonName(t, reloader); name != "third" {
+		t.Fatalf("Expected the third certificate once the key is updated, got %s", name)
+	}
+}
+
+func TestClientCertRequiredOnReadEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "client-ca", nil)
+	otherCA := newTestCert(t, "other-ca", nil)
+	certFile, keyFile, clientCAFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "client-ca.pem")
+	writeTestCert(t, newTestCert(t, "listener", ca), certFile, keyFile, time.Now())
+	if err := os.WriteFile(clientCAFile, ca.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	serverTLSConfig, err := newServerTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the requests tested are rejected before the store, the beacon nodes or the users file are used
+	usersFilePath := filepath.Join(dir, "users.json")
+	if err := os.WriteFile(usersFilePath, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	usersFile, err := middleware.NewUsersFile(usersFilePath, types.NewTagRegistry(types.DefaultTags), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := routes.SetupRouter(nil, nil, nil, usersFile, nil, nil, routes.Limits{}, true, types.NewTagRegistry(types.DefaultTags), nil, nil)
+	// the server is started over the TLS config of the listener, StartTLS would serve the httptest certificate
+	server := httptest.NewUnstartedServer(router)
+	server.Listener = tls.NewListener(server.Listener, serverTLSConfig)
+	server.Start()
+	defer server.Close()
+	serverURL := "https://" + server.Listener.Addr().String()
+
+	newClient := func(clientCert *testCert) *http.Client {
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(ca.cert)
+		clientTLSConfig := &tls.Config{RootCAs: rootCAs}
+		if clientCert != nil {
+			keyPair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// sent even if not signed by a CA the server accepts, which a client would not do by itself
+			clientTLSConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &keyPair, nil
+			}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLSConfig}}
+	}
+	doRequest := func(client *http.Client, method string) (int, string, error) {
+		req, _ := http.NewRequest(method, serverURL+"/signatures", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body := new(strings.Builder)
+		_, err = io.Copy(body, resp.Body)
+		return resp.StatusCode, body.String(), err
+	}
+
+	// without a client certificate the read endpoints are rejected, while POST /signatures is still open
+	code, body, err := doRequest(newClient(nil), http.MethodGet)
+	if err != nil || code != http.StatusUnauthorized || !strings.Contains(body, "client certificate") {
+		t.Errorf("Expected GET without a client certificate to be rejected, got %d %q %v", code, body, err)
+	}
+	code, body, err = doRequest(newClient(nil), http.MethodPost)
+	if err != nil || code != http.StatusBadRequest {
+		t.Errorf("Expected POST without a client certificate to reach the handler, got %d %q %v", code, body, err)
+	}
+
+	// with a client certificate of the CA, the request goes on to the JWT auth
+	code, body, err = doRequest(newClient(newTestCert(t, "reader", ca)), http.MethodGet)
+	if err != nil || code != http.StatusUnauthorized || !strings.Contains(body, "Authorization header is required") {
+		t.Errorf("Expected GET with a client certificate to reach the JWT auth, got %d %q %v", code, body, err)
+	}
+
+	// a client certificate of another CA fails the TLS handshake
+	if _, _, err := doRequest(newClient(newTestCert(t, "intruder", otherCA)), http.MethodGet); err == nil {
+		t.Errorf("Expected a client certificate of another CA to be rejected")
+	}
+}
+
+func TestStartTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	// a missing certificate is reported by Start, before serving anything
+	s := NewApi("0", nil, nil, nil, nil, nil, nil, routes.Limits{}, TLSConfig{CertFile: certFile, KeyFile: keyFile}, Timeouts{}, nil, nil, nil)
+	if err := s.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to set up TLS") {
+		t.Fatalf("Expected Start to fail to set up TLS, got %v", err)
+	}
+
+	// once fixed, the server starts and is shut down cleanly
+	writeTestCert(t, newTestCert(t, "listener", nil), certFile, keyFile, time.Now())
+	s = NewApi("0", nil, nil, nil, nil, nil, nil, routes.Limits{}, TLSConfig{CertFile: certFile, KeyFile: keyFile}, Timeouts{}, nil, nil, nil)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start the server: %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-s.Err(); err != nil {
+		t.Errorf("Expected no error once shut down, got %v", err)
+	}
+}
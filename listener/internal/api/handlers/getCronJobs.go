@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+type cronJobsResponse struct {
+	// Leader is whether this replica runs the jobs. The last runs are the ones of any replica
+	Leader bool                `json:"leader"`
+	Jobs   []apiCron.JobStatus `json:"jobs"`
+}
+
+func GetCronJobs(w http.ResponseWriter, r *http.Request, scheduler *apiCron.Scheduler) {
+	statuses, err := scheduler.Statuses(r.Context())
+	if err != nil {
+		logger.Error("Failed to get the cron jobs: " + err.Error())
+		respondError(w, http.StatusInternalServerError, "could not read the cron jobs")
+		return
+	}
+	respondOK(w, cronJobsResponse{Leader: scheduler.IsLeader(), Jobs: statuses})
+}
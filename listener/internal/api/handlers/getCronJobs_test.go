@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+)
+
+// fixedLeaseStore gives the lease to every holder if leader is true, to none otherwise
+type fixedLeaseStore struct {
+	leader bool
+}
+
+func (s *fixedLeaseStore) TryAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	return s.leader, nil
+}
+
+func (s *fixedLeaseStore) Release(ctx context.Context, name string, holder string) error {
+	return nil
+}
+
+// fixedRunStore returns the given runs or error, and records the saved runs
+type fixedRunStore struct {
+	runs  map[string]leader.Run
+	err   error
+	saved chan leader.Run
+}
+
+func (s *fixedRunStore) SaveRun(ctx context.Context, run leader.Run) error {
+	s.saved <- run
+	return nil
+}
+
+func (s *fixedRunStore) GetRuns(ctx context.Context) (map[string]leader.Run, error) {
+	return s.runs, s.err
+}
+
+// newScheduler returns a scheduler with a disabled job named test, leading or not once its first election is done
+func newScheduler(t *testing.T, leading bool, runs leader.RunStore, run func(ctx context.Context) (map[string]int64, error)) *apiCron.Scheduler {
+	elector := leader.NewElector(&fixedLeaseStore{leader: leading}, "crons", time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go elector.Run(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for elector.IsLeader() != leading && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	scheduler := apiCron.NewScheduler(ctx, elector, runs)
+	if err := scheduler.Add(apiCron.Job{Name: "test", Schedule: "@daily", Run: run}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { <-scheduler.Stop().Done() })
+	return scheduler
+}
+
+func TestGetCronJobs(t *testing.T) {
+	startedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	runs := &fixedRunStore{runs: map[string]leader.Run{
+		"test": {Name: "test", Trigger: "manual", Holder: "replica-1", StartedAt: startedAt, Duration: 1200 * time.Millisecond, Counts: map[string]int64{"removed": 42}, Error: "beacon node down"},
+	}}
+	scheduler := newScheduler(t, false, runs, nil)
+
+	rec := httptest.NewRecorder()
+	GetCronJobs(rec, httptest.NewRequest(http.MethodGet, "/admin/crons", nil), scheduler)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var response cronJobsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode the response: %v", err)
+	}
+	// the run of another replica is reported
+	if response.Leader || len(response.Jobs) != 1 {
+		t.Fatalf("Unexpected response: %s", rec.Body.String())
+	}
+	job := response.Jobs[0]
+	if job.Name != "test" || job.RanBy != "replica-1" || job.LastDuration != "1.2s" || job.LastCounts["removed"] != 42 || job.LastError != "beacon node down" || !job.LastRunAt.Equal(startedAt) {
+		t.Errorf("Unexpected job status: %+v", job)
+	}
+
+	// the error of the database is not exposed
+	runs.err = errors.New("connection refused to mongo:27017")
+	rec = httptest.NewRecorder()
+	GetCronJobs(rec, httptest.NewRequest(http.MethodGet, "/admin/crons", nil), scheduler)
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "mongo:27017") {
+		t.Errorf("Expected a generic error, got %d %s", rec.Code, rec.Body.String())
+	}
+}
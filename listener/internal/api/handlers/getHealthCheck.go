@@ -2,6 +2,12 @@ package handlers
 
 import "net/http"
 
+type healthCheckResponse struct {
+	Message string `json:"message"`
+}
+
+// GetHealthCheck only tells the server is running, it is not authenticated. The checks of the dependencies are in
+// GetReadyz and the index drift in GetIndexes
 func GetHealthCheck(w http.ResponseWriter, r *http.Request) {
-	respondOK(w, "Server is running")
+	respondOK(w, healthCheckResponse{Message: "Server is running"})
 }
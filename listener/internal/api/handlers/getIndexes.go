@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+type indexesResponse struct {
+	// IndexDrift lists the differences between the indexes of the database and the ones the listener requires, empty
+	// if none
+	IndexDrift []string `json:"indexDrift"`
+}
+
+func GetIndexes(w http.ResponseWriter, r *http.Request, signatureStore store.SignatureStore) {
+	drift, err := signatureStore.CheckIndexes(r.Context())
+	if err != nil {
+		// the error of the database is only logged, it may tell more about the deployment than an admin needs
+		logger.Error("Failed to check indexes: " + err.Error())
+		respondError(w, http.StatusInternalServerError, "could not check the indexes")
+		return
+	}
+	if len(drift) > 0 {
+		logger.Warn("Index drift detected, restart the listener to recreate the missing indexes")
+	}
+	respondOK(w, indexesResponse{IndexDrift: drift})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// indexesStore returns the given drift or error from CheckIndexes
+type indexesStore struct {
+	store.SignatureStore
+	drift []string
+	err   error
+}
+
+func (s *indexesStore) CheckIndexes(ctx context.Context) ([]string, error) {
+	return s.drift, s.err
+}
+
+func TestGetIndexes(t *testing.T) {
+	testCases := []struct {
+		description  string
+		store        *indexesStore
+		expectedCode int
+		expectedBody string
+	}{
+		{"No drift", &indexesStore{drift: []string{}}, http.StatusOK, `{"indexDrift":[]}`},
+		{"Drift", &indexesStore{drift: []string{"index status of signatures is missing"}}, http.StatusOK, `{"indexDrift":["index status of signatures is missing"]}`},
+		{"Database error is not exposed", &indexesStore{err: errors.New("connection refused to mongo:27017")}, http.StatusInternalServerError, `"could not check the indexes"`},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		GetIndexes(rec, httptest.NewRequest(http.MethodGet, "/admin/indexes", nil), tc.store)
+
+		if rec.Code != tc.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tc.description, tc.expectedCode, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), tc.expectedBody) {
+			t.Errorf("%s: expected body to contain %s, got %s", tc.description, tc.expectedBody, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "mongo:27017") {
+			t.Errorf("%s: expected the database error not to be exposed, got %s", tc.description, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+type livezResponse struct {
+	Status string `json:"status"`
+}
+
+// GetLivez answers as long as the process serves requests. It does not check any dependency, so an orchestrator does
+// not restart the listener while the database or the beacon nodes are down
+func GetLivez(w http.ResponseWriter, r *http.Request) {
+	respondOK(w, livezResponse{Status: checkOK})
+}
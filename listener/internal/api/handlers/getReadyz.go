@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// readyzTimeout is how long the checks have, orchestrators usually give up on a probe after a few seconds
+const readyzTimeout = 5 * time.Second
+
+const (
+	checkOK       = "ok"
+	checkFail     = "fail"
+	checkDegraded = "degraded"
+)
+
+type readyzCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// readyzBeaconNode is identified by its index in the beacon node URLs of the network, the URL is not exposed since it
+// may hold an API key
+type readyzBeaconNode struct {
+	Index  int                              `json:"index"`
+	Status string                           `json:"status"`
+	Sync   *validation.BeaconNodeSyncStatus `json:"sync,omitempty"`
+	Error  string                           `json:"error,omitempty"`
+}
+
+type readyzNetwork struct {
+	// Status is ok if at least one beacon node of the network is synced, degraded otherwise
+	Status      string             `json:"status"`
+	BeaconNodes []readyzBeaconNode `json:"beaconNodes"`
+}
+
+type readyzResponse struct {
+	Status    string                          `json:"status"`
+	Database  readyzCheck                     `json:"database"`
+	UsersFile readyzCheck                     `json:"usersFile"`
+	Networks  map[types.Network]readyzNetwork `json:"networks"`
+}
+
+// GetReadyz checks the dependencies of the listener: the database, the JWT users file and the beacon nodes of every
+// network. Answers 503 if the database or the users file fail and 200 otherwise, with the result of every check. A
+// network without a synced beacon node is only degraded, the signatures of the other networks are still served. The
+// endpoint is not authenticated, so the errors are generic and the details are only logged
+func GetReadyz(w http.ResponseWriter, r *http.Request, signatureStore store.SignatureStore, beaconNodeUrls map[types.Network][]string, usersFile *middleware.UsersFile) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyzTimeout)
+	defer cancel()
+
+	response := readyzResponse{Status: checkOK, Networks: checkNetworks(ctx, beaconNodeUrls)}
+	response.Database = toReadyzCheck(signatureStore.Ping(ctx), "database is not reachable")
+	response.UsersFile = toReadyzCheck(usersFile.Err(), "users file is not valid")
+
+	code := http.StatusOK
+	if response.Database.Status != checkOK || response.UsersFile.Status != checkOK {
+		response.Status = checkFail
+		code = http.StatusServiceUnavailable
+	} else {
+		for _, network := range response.Networks {
+			if network.Status != checkOK {
+				response.Status = checkDegraded
+			}
+		}
+	}
+	if response.Status != checkOK {
+		logger.Warn("Readiness check " + response.Status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkNetworks checks the sync status of every beacon node concurrently, so a beacon node that is down does not delay
+// the checks of the others
+func checkNetworks(ctx context.Context, beaconNodeUrls map[types.Network][]string) map[types.Network]readyzNetwork {
+	var wg sync.WaitGroup
+	beaconNodes := make(map[types.Network][]readyzBeaconNode)
+	for network, urls := range beaconNodeUrls {
+		beaconNodes[network] = make([]readyzBeaconNode, len(urls))
+		for i, url := range urls {
+			wg.Add(1)
+			go func(result *readyzBeaconNode, url string) {
+				defer wg.Done()
+				*result = checkBeaconNode(ctx, network, i, url)
+			}(&beaconNodes[network][i], url)
+		}
+	}
+	wg.Wait()
+
+	networks := make(map[types.Network]readyzNetwork)
+	for network, nodes := range beaconNodes {
+		status := checkDegraded
+		for _, node := range nodes {
+			if node.Status == checkOK {
+				status = checkOK
+			}
+		}
+		networks[network] = readyzNetwork{Status: status, BeaconNodes: nodes}
+	}
+	return networks
+}
+
+func checkBeaconNode(ctx context.Context, network types.Network, index int, url string) readyzBeaconNode {
+	syncStatus, err := validation.CheckBeaconNodeSyncing(ctx, url, readyzTimeout)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Readiness check of beacon node %d of %s failed: %v", index, network, err))
+		return readyzBeaconNode{Index: index, Status: checkFail, Error: "beacon node is not reachable"}
+	}
+	result := readyzBeaconNode{Index: index, Status: checkOK, Sync: &syncStatus}
+	if !syncStatus.Synced() {
+		result.Status = checkFail
+		result.Error = "beacon node is not synced"
+	}
+	return result
+}
+
+// toReadyzCheck logs the error and answers the given generic message instead
+func toReadyzCheck(err error, message string) readyzCheck {
+	if err != nil {
+		logger.Warn("Readiness check failed, " + message + ": " + err.Error())
+		return readyzCheck{Status: checkFail, Error: message}
+	}
+	return readyzCheck{Status: checkOK}
+}
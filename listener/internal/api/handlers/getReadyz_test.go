@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// pingStore returns the given error from Ping
+type pingStore struct {
+	store.SignatureStore
+	err error
+}
+
+func (s *pingStore) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// newBeaconNode serves the given sync status, or 500 if it is empty
+func newBeaconNode(t *testing.T, syncStatus string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if syncStatus == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"data":` + syncStatus + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestGetReadyz(t *testing.T) {
+	usersFilePath := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(usersFilePath, []byte(`{"a": {"publicKey": "key", "tags": ["solo"]}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	usersFile, err := middleware.NewUsersFile(usersFilePath, types.NewTagRegistry(types.DefaultTags), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	synced := newBeaconNode(t, `{"head_slot":"100","sync_distance":"0","is_syncing":false,"is_optimistic":false,"el_offline":false}`)
+	syncing := newBeaconNode(t, `{"head_slot":"100","sync_distance":"20","is_syncing":true,"is_optimistic":false,"el_offline":false}`)
+	down := newBeaconNode(t, "")
+	beaconNodeUrls := map[types.Network][]string{
+		types.Mainnet: {down, synced},
+		types.Holesky: {syncing, down + "/?apikey=secret"},
+	}
+
+	testCases := []struct {
+		description    string
+		store          *pingStore
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"Network without a synced beacon node", &pingStore{}, http.StatusOK, checkDegraded},
+		{"Database down", &pingStore{err: errors.New("connection refused to mongo:27017")}, http.StatusServiceUnavailable, checkFail},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		GetReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil), tc.store, beaconNodeUrls, usersFile)
+
+		if rec.Code != tc.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tc.description, tc.expectedCode, rec.Code)
+		}
+		var response readyzResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to decode the response: %v", tc.description, err)
+		}
+		if response.Status != tc.expectedStatus {
+			t.Errorf("%s: expected status %s, got %s", tc.description, tc.expectedStatus, response.Status)
+		}
+		if mainnet := response.Networks[types.Mainnet]; mainnet.Status != checkOK || mainnet.BeaconNodes[0].Status != checkFail || mainnet.BeaconNodes[1].Index != 1 {
+			t.Errorf("%s: expected mainnet to be ok with its second beacon node, got %+v", tc.description, mainnet)
+		}
+		if holesky := response.Networks[types.Holesky]; holesky.Status != checkDegraded {
+			t.Errorf("%s: expected holesky to be degraded, got %+v", tc.description, holesky)
+		}
+		for _, secret := range []string{"127.0.0.1", "apikey", "mongo:27017"} {
+			if strings.Contains(rec.Body.String(), secret) {
+				t.Errorf("%s: expected %s not to be exposed, got %s", tc.description, secret, rec.Body.String())
+			}
+		}
+	}
+
+	// a users file that can not be loaded fails the check
+	if err := os.Remove(usersFilePath); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	GetReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil), &pingStore{}, nil, usersFile)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"usersFile":{"status":"fail","error":"users file is not valid"}`) {
+		t.Errorf("Expected the users file to fail the check, got %d %s", rec.Code, rec.Body.String())
+	}
+}
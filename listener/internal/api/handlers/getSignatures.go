@@ -5,14 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 )
 
-func GetSignatures(w http.ResponseWriter, r *http.Request, dbCollection *mongo.Collection) {
+func GetSignatures(w http.ResponseWriter, r *http.Request, signatureStore store.SignatureStore) {
 	logger.Debug("Received new GET '/signatures' request")
 	// Get tags from the context
 	tags, ok := r.Context().Value(middleware.TagsKey).([]string)
@@ -23,20 +25,17 @@ func GetSignatures(w http.ResponseWriter, r *http.Request, dbCollection *mongo.C
 		return
 	}
 
-	// Query MongoDB for documents with tags matching the context tags
-	var results []bson.M
-	filter := bson.M{
-		"tag": bson.M{"$in": tags},
-	}
-	cursor, err := dbCollection.Find(context.Background(), filter)
+	// Query the store for the validators within the scope of the token
+	filter := getScopeFilter(r.Context(), tags)
+	metadataFilter, err := getMetadataFilter(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to query MongoDB: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer cursor.Close(context.Background())
-
-	if err := cursor.All(context.Background(), &results); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to read cursor: %v", err), http.StatusInternalServerError)
+	filter.Metadata = metadataFilter
+	results, err := signatureStore.GetSignatures(r.Context(), filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query signatures: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -46,3 +45,33 @@ func GetSignatures(w http.ResponseWriter, r *http.Request, dbCollection *mongo.C
 		http.Error(w, fmt.Sprintf("Failed to encode results: %v", err), http.StatusInternalServerError)
 	}
 }
+
+// getScopeFilter returns the filter that restricts a read query to the tags, networks and pubkeys the token has
+// access to. Every read query must start from this filter.
+func getScopeFilter(ctx context.Context, tags []string) store.Filter {
+	filter := store.Filter{Tags: tags}
+	// nil networks or pubkeys mean the token is not restricted by them
+	if networks, ok := ctx.Value(middleware.NetworksKey).([]string); ok && networks != nil {
+		filter.Networks = networks
+	}
+	if pubkeys, ok := ctx.Value(middleware.PubkeysKey).([]string); ok && pubkeys != nil {
+		filter.Pubkeys = pubkeys
+	}
+	return filter
+}
+
+// getMetadataFilter returns the metadata fields to filter the entries by, from the query parameters named as them,
+// e.g. ?consensusClient=lighthouse&brainVersion=0.1.20. Any other query parameter is an error, so a typo does not
+// silently return the entries unfiltered
+func getMetadataFilter(r *http.Request) (map[string]string, error) {
+	metadataFilter := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		if !slices.Contains(types.MetadataFields, name) {
+			return nil, fmt.Errorf("unknown query parameter %s, the metadata filters are %s", name, strings.Join(types.MetadataFields, ", "))
+		}
+		if values[0] != "" {
+			metadataFilter[name] = values[0]
+		}
+	}
+	return metadataFilter, nil
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// filterStore records the filter of the last GetSignatures. The other operations are not used by the handlers tested
+type filterStore struct {
+	store.SignatureStore
+	filter *store.Filter
+}
+
+func (s *filterStore) GetSignatures(ctx context.Context, filter store.Filter) ([]store.Validator, error) {
+	s.filter = &filter
+	return []store.Validator{}, nil
+}
+
+func TestGetSignaturesMetadataFilter(t *testing.T) {
+	testCases := []struct {
+		description    string
+		query          string
+		expectedCode   int
+		expectedFilter map[string]string
+	}{
+		{"No filters", "", http.StatusOK, map[string]string{}},
+		{"Metadata filters", "?consensusClient=lighthouse&brainVersion=0.1.20", http.StatusOK, map[string]string{"consensusClient": "lighthouse", "brainVersion": "0.1.20"}},
+		{"Empty filter", "?web3signer=", http.StatusOK, map[string]string{}},
+		{"Unknown filter", "?consensusclient=lighthouse", http.StatusBadRequest, nil},
+		{"Unknown parameter", "?network=mainnet", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range testCases {
+		signatureStore := &filterStore{}
+		req := httptest.NewRequest(http.MethodGet, "/signatures"+tc.query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TagsKey, []string{"solo"}))
+		rec := httptest.NewRecorder()
+		GetSignatures(rec, req, signatureStore)
+
+		if rec.Code != tc.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tc.description, tc.expectedCode, rec.Code)
+			continue
+		}
+		if tc.expectedFilter == nil {
+			if signatureStore.filter != nil {
+				t.Errorf("%s: expected the store not to be queried", tc.description)
+			}
+			continue
+		}
+		if signatureStore.filter == nil || !maps.Equal(signatureStore.filter.Metadata, tc.expectedFilter) {
+			t.Errorf("%s: expected metadata filter %v, got %+v", tc.description, tc.expectedFilter, signatureStore.filter)
+		}
+	}
+}
+
+func TestGetScopeFilter(t *testing.T) {
+	tags := []string{"solo"}
+
+	// a token without restrictions reads every network and pubkey of its tags
+	ctx := context.WithValue(context.Background(), middleware.NetworksKey, []string(nil))
+	ctx = context.WithValue(ctx, middleware.PubkeysKey, []string(nil))
+	filter := getScopeFilter(ctx, tags)
+	if !slices.Equal(filter.Tags, tags) || filter.Networks != nil || filter.Pubkeys != nil {
+		t.Errorf("Expected a filter by tags only, got %+v", filter)
+	}
+
+	// a restricted token reads only its networks and pubkeys
+	networks := []string{"mainnet"}
+	pubkeys := []string{"0xa06251962339450df57631d128fa54e4d54e2d17015571f1bcccd9b45c6ea971245f209cc9be087d5440bec19495a99a"}
+	ctx = context.WithValue(context.Background(), middleware.NetworksKey, networks)
+	ctx = context.WithValue(ctx, middleware.PubkeysKey, pubkeys)
+	filter = getScopeFilter(ctx, tags)
+	if !slices.Equal(filter.Tags, tags) || !slices.Equal(filter.Networks, networks) || !slices.Equal(filter.Pubkeys, pubkeys) {
+		t.Errorf("Expected a filter by tags, networks and pubkeys, got %+v", filter)
+	}
+
+	// an empty restriction does not grant access to anything, it must not be dropped from the filter
+	ctx = context.WithValue(context.Background(), middleware.PubkeysKey, []string{})
+	filter = getScopeFilter(ctx, tags)
+	if filter.Pubkeys == nil || len(filter.Pubkeys) != 0 {
+		t.Errorf("Expected an empty pubkeys restriction to be kept, got %+v", filter)
+	}
+}
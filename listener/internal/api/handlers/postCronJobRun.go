@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/gorilla/mux"
+)
+
+// PostCronJobRun starts a run of the cron job in the background, only on the leader replica. Its result is reported by
+// GetCronJobs
+func PostCronJobRun(w http.ResponseWriter, r *http.Request, scheduler *apiCron.Scheduler) {
+	name := mux.Vars(r)["name"]
+	err := scheduler.Trigger(name)
+	switch {
+	case errors.Is(err, apiCron.ErrJobNotFound):
+		respondError(w, http.StatusNotFound, err.Error()+": "+name)
+		return
+	case errors.Is(err, apiCron.ErrJobRunning):
+		respondError(w, http.StatusConflict, err.Error()+": "+name)
+		return
+	case errors.Is(err, apiCron.ErrNotLeader):
+		// the load balancer may send the retry to the leader
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	logger.Info("Cron job " + name + " triggered from the admin API")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+	}{Message: "Started cron job " + name})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+	"github.com/gorilla/mux"
+)
+
+func TestPostCronJobRun(t *testing.T) {
+	release := make(chan struct{})
+	runs := &fixedRunStore{saved: make(chan leader.Run, 1)}
+	leading := newScheduler(t, true, runs, func(ctx context.Context) (map[string]int64, error) {
+		<-release
+		return map[string]int64{"removed": 1}, nil
+	})
+	notLeading := newScheduler(t, false, runs, nil)
+
+	doRequest := func(scheduler *apiCron.Scheduler, name string) int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/admin/crons/"+name+"/run", nil), map[string]string{"name": name})
+		rec := httptest.NewRecorder()
+		PostCronJobRun(rec, req, scheduler)
+		return rec.Code
+	}
+
+	if code := doRequest(leading, "missing"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown job, got %d", http.StatusNotFound, code)
+	}
+	if code := doRequest(notLeading, "test"); code != http.StatusConflict {
+		t.Errorf("Expected status %d on a replica that is not the leader, got %d", http.StatusConflict, code)
+	}
+	if code := doRequest(leading, "test"); code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, code)
+	}
+	if code := doRequest(leading, "test"); code != http.StatusConflict {
+		t.Errorf("Expected status %d while the job runs, got %d", http.StatusConflict, code)
+	}
+
+	close(release)
+	if run := <-runs.saved; run.Trigger != "manual" || run.Counts["removed"] != 1 || run.Error != "" {
+		t.Errorf("Unexpected saved run: %+v", run)
+	}
+	<-leading.Stop().Done()
+	if code := doRequest(leading, "test"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d once stopped, got %d", http.StatusServiceUnavailable, code)
+	}
+}
@@ -7,15 +7,15 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 )
 
-func PostSignatures(w http.ResponseWriter, r *http.Request, dbCollection *mongo.Collection, beaconNodeUrls map[types.Network]string, maxEntriesPerBson int) {
+func PostSignatures(w http.ResponseWriter, r *http.Request, signatureStore store.SignatureStore, beaconNodeUrls map[types.Network][]string, validatorsStatusCache *validation.ValidatorsStatusCache, maxBodyBytes int64, maxSignaturesPerRequest int, tags types.TagRegistry, proofTypes proofs.Registry) {
 	logger.Debug("Received new POST '/signatures' request")
 	var requests []types.SignatureRequest
 
@@ -26,27 +26,50 @@ func PostSignatures(w http.ResponseWriter, r *http.Request, dbCollection *mongo.
 		return
 	}
 	network := types.Network(networkVar)
-	beaconNodeUrl, ok := beaconNodeUrls[network]
+	networkBeaconNodeUrls, ok := beaconNodeUrls[network]
 	if !ok {
 		respondError(w, http.StatusBadRequest, "Invalid network")
 		return
 	}
 
-	// Parse and validate request body
+	// Parse and validate request body. The body is limited since every signature costs a beacon node call and a BLS verification
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			logger.Warn("Request body too large: " + err.Error())
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body too large, max %d bytes", maxBodyBytes))
+			return
+		}
 		logger.Error("Failed to decode request body: " + err.Error())
 		respondError(w, http.StatusBadRequest, "Invalid request format")
 		return
 	}
 
+	if len(requests) > maxSignaturesPerRequest {
+		logger.Warn(fmt.Sprintf("Too many signatures in request: %d", len(requests)))
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Too many signatures in request, max %d", maxSignaturesPerRequest))
+		return
+	}
+
 	if len(requests) == 0 {
 		logger.Error("No valid requests in payload")
 		respondError(w, http.StatusBadRequest, "No requests in payload")
 		return
 	}
 
+	// If submitter auth is enabled, the submitter can only post signatures with its allowed tags
+	submitter, _ := r.Context().Value(middleware.SubmitterKey).(string)
+	if submitterTags, ok := r.Context().Value(middleware.SubmitterTagsKey).([]string); ok {
+		requests = filterSubmitterTags(requests, submitter, submitterTags)
+		if len(requests) == 0 {
+			respondError(w, http.StatusForbidden, "No requests with tags allowed for submitter")
+			return
+		}
+	}
+
 	// Process each request and validate
-	requestsValidatedAndDecoded, err := validation.ValidateAndDecodeRequests(requests)
+	requestsValidatedAndDecoded, err := validation.ValidateAndDecodeRequests(requests, network, tags, proofTypes)
 	if err != nil {
 		logger.Error("Failed to validate and decode requests: " + err.Error())
 		respondError(w, http.StatusBadRequest, "No valid requests")
@@ -58,31 +81,54 @@ func PostSignatures(w http.ResponseWriter, r *http.Request, dbCollection *mongo.
 		return
 	}
 
-	// Get active validators and process signatures
-	pubkeys := getPubkeys(requestsValidatedAndDecoded)
-	validatorsStatusMap, err := validation.GetValidatorsStatus(pubkeys, beaconNodeUrl)
+	// Verify the signatures before querying the beacon node, so forged signatures do not cost a beacon node call
+	verifiedRequests := filterValidSignatures(requestsValidatedAndDecoded)
+	if len(verifiedRequests) == 0 {
+		respondError(w, http.StatusBadRequest, "No valid signatures")
+		return
+	}
+
+	// Get the status of the validators with verified signatures
+	pubkeys := getPubkeys(verifiedRequests)
+	validatorsStatusMap, err := validatorsStatusCache.GetValidatorsStatus(r.Context(), network, pubkeys, networkBeaconNodeUrls)
 	if err != nil {
 		logger.Error("Failed to get active validators: " + err.Error())
 		respondError(w, http.StatusInternalServerError, "Failed to get active validators: "+err.Error())
 		return
 	}
 
-	validSignatures := filterAndVerifySignatures(requestsValidatedAndDecoded, validatorsStatusMap)
+	validSignatures := filterActiveValidators(verifiedRequests, validatorsStatusMap)
 	if len(validSignatures) == 0 {
-		respondError(w, http.StatusBadRequest, "No valid signatures")
+		respondError(w, http.StatusBadRequest, "No signatures from active validators")
 		return
 	}
 
-	// Insert valid signatures into MongoDB
-	if err := insertSignaturesIntoDB(validSignatures, network, dbCollection, maxEntriesPerBson); err != nil {
-		logger.Error("Failed to insert signatures into MongoDB: " + err.Error())
-		respondError(w, http.StatusInternalServerError, "Failed to insert signatures into MongoDB: "+err.Error())
+	// Insert valid signatures into the store
+	if err := insertSignaturesIntoDB(r.Context(), validSignatures, network, submitter, signatureStore, proofTypes); err != nil {
+		logger.Error("Failed to store signatures: " + err.Error())
+		respondError(w, http.StatusInternalServerError, "Failed to store signatures: "+err.Error())
 		return
 	}
 
 	respondOK(w, "Finished processing signatures")
 }
 
+func filterSubmitterTags(requests []types.SignatureRequest, submitter string, submitterTags []string) []types.SignatureRequest {
+	allowedTags := make(map[types.Tag]bool)
+	for _, tag := range submitterTags {
+		allowedTags[types.Tag(tag)] = true
+	}
+	allowedRequests := []types.SignatureRequest{}
+	for _, req := range requests {
+		if !allowedTags[req.Tag] {
+			logger.Warn("Submitter " + submitter + " is not allowed to post signatures with tag " + string(req.Tag))
+			continue
+		}
+		allowedRequests = append(allowedRequests, req)
+	}
+	return allowedRequests
+}
+
 func getPubkeys(requests []types.SignatureRequestDecoded) []string {
 	pubkeys := make([]string, len(requests))
 	for i, req := range requests {
@@ -91,8 +137,22 @@ func getPubkeys(requests []types.SignatureRequestDecoded) []string {
 	return pubkeys
 }
 
-func filterAndVerifySignatures(requests []types.SignatureRequestDecoded, validatorsStatusMap map[string]types.Status) []types.SignatureRequestDecodedWithStatus {
-	validSignatures := []types.SignatureRequestDecodedWithStatus{}
+// filterValidSignatures verifies all the signatures of the request at once, it is much faster than one by one
+func filterValidSignatures(requests []types.SignatureRequestDecoded) []types.SignatureRequestDecoded {
+	results := validation.VerifySignatures(requests)
+	validRequests := []types.SignatureRequestDecoded{}
+	for i, req := range requests {
+		if results[i] {
+			validRequests = append(validRequests, req)
+		} else {
+			logger.Warn("Invalid signature: " + req.Signature)
+		}
+	}
+	return validRequests
+}
+
+func filterActiveValidators(requests []types.SignatureRequestDecoded, validatorsStatusMap map[string]types.Status) []types.SignatureRequestDecodedWithStatus {
+	activeRequests := []types.SignatureRequestDecodedWithStatus{}
 	for _, req := range requests {
 		status, ok := validatorsStatusMap[req.Pubkey]
 		if !ok {
@@ -103,70 +163,47 @@ func filterAndVerifySignatures(requests []types.SignatureRequestDecoded, validat
 			logger.Warn("Inactive validator: " + req.Pubkey)
 			continue
 		}
-		reqWithStatus := types.SignatureRequestDecodedWithStatus{
+		activeRequests = append(activeRequests, types.SignatureRequestDecodedWithStatus{
 			SignatureRequestDecoded: req,
 			Status:                  status,
-		}
-		if isValid, err := validation.VerifySignature(reqWithStatus); err == nil && isValid {
-			validSignatures = append(validSignatures, reqWithStatus)
-		} else {
-			logger.Warn("Invalid signature: " + req.Signature)
-		}
+		})
 	}
-	return validSignatures
+	return activeRequests
 }
 
-func insertSignaturesIntoDB(signatures []types.SignatureRequestDecodedWithStatus, network types.Network, dbCollection *mongo.Collection, maxEntriesPerBson int) error {
+// insertSignaturesIntoDB stores the signatures. submitter is the id of the authenticated submitter, empty if submitter auth is disabled.
+// The decoded payload of every entry has the envelope fields plus the ones its proof type stores
+func insertSignaturesIntoDB(ctx context.Context, signatures []types.SignatureRequestDecodedWithStatus, network types.Network, submitter string, signatureStore store.SignatureStore, proofTypes proofs.Registry) error {
 	for _, req := range signatures {
-		filter := bson.M{
-			"pubkey":  req.Pubkey,
-			"tag":     req.Tag,
-			"network": network,
+		decodedPayload := map[string]interface{}{
+			"type":      req.DecodedPayload.Type,
+			"platform":  req.DecodedPayload.Platform,
+			"timestamp": req.DecodedPayload.Timestamp,
 		}
-
-		// Check the number of entries
-		var result struct {
-			Entries []bson.M `bson:"entries"`
+		if req.DecodedPayload.Version != "" {
+			decodedPayload["version"] = req.DecodedPayload.Version
 		}
-		err := dbCollection.FindOne(context.Background(), filter).Decode(&result)
-		if err != nil && err != mongo.ErrNoDocuments {
-			return err
+		if proofHandler, ok := proofTypes[req.DecodedPayload.Type]; ok {
+			for field, value := range proofHandler.StoredFields(req.DecodedPayload) {
+				decodedPayload[field] = value
+			}
 		}
-
-		// mongo DB has a limit of 16MB per document
-		// if this limit is reached the following exception is thrown: `write exception: write errors: [Resulting document after update is larger than 16777216]`
-		if len(result.Entries) >= maxEntriesPerBson {
-			return errors.New("Max number of entries reached for pubkey " + req.Pubkey + ". Max entries per pubkey: " + fmt.Sprint(maxEntriesPerBson))
+		entry := store.Entry{
+			Payload:        req.Payload,
+			Signature:      req.Signature,
+			DecodedPayload: decodedPayload,
+			Submitter:      submitter,
 		}
-
-		// Create a base update document with $push operation
-		update := bson.M{
-			"$push": bson.M{
-				"entries": bson.M{
-					"payload":   req.Payload,
-					"signature": req.Signature,
-					"decodedPayload": bson.M{
-						"type":      req.DecodedPayload.Type,
-						"platform":  req.DecodedPayload.Platform,
-						"timestamp": req.DecodedPayload.Timestamp,
-					},
-				},
-			},
-		}
-
-		// Only update status unknown -> active
-		if req.Status == "active" {
-			update["$set"] = bson.M{"status": req.Status}
-		} else {
-			update["$setOnInsert"] = bson.M{"status": req.Status}
+		if metadata := req.DecodedPayload.Metadata.Merge(req.Metadata); !metadata.IsEmpty() {
+			entry.Metadata = &metadata
 		}
 
-		options := options.Update().SetUpsert(true)
-		if _, err := dbCollection.UpdateOne(context.Background(), filter, update, options); err != nil {
+		key := store.ValidatorKey{Network: network, Pubkey: req.Pubkey, Tag: req.Tag}
+		if err := signatureStore.InsertSignature(ctx, key, req.Status, entry); err != nil {
 			return err
 		}
 
-		logger.Debug("New Signature " + req.Signature + " inserted into MongoDB")
+		logger.Debug("New Signature " + req.Signature + " stored")
 	}
 	return nil
 }
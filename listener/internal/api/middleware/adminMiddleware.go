@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// AdminMiddleware requires the kid of the token to be an admin in the users file. It must run after JWTMiddleware,
+// which sets the admin flag of the kid in the context
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin, _ := r.Context().Value(AdminKey).(bool)
+		if !admin {
+			http.Error(w, "admin access is required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	handler := AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		description  string
+		admin        any
+		expectedCode int
+	}{
+		{"Admin kid", true, http.StatusOK},
+		{"Kid that is not an admin", false, http.StatusForbidden},
+		{"Without JWTMiddleware", nil, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/crons", nil)
+		if tc.admin != nil {
+			req = req.WithContext(context.WithValue(req.Context(), AdminKey, tc.admin))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tc.description, tc.expectedCode, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// ClientCertMiddleware requires the request to come with a client certificate verified by the TLS server (mTLS).
+// The certificate is verified against the client CA during the TLS handshake, here we only check it was sent.
+func ClientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "A valid client certificate is required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,27 +1,69 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	jwtutil "github.com/dappnode/validator-monitoring/listener/internal/jwt"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type KeyId struct {
 	PublicKey string   `json:"publicKey"`
 	Tags      []string `json:"tags"`
+	// Networks optionally restricts the networks the kid can read. Empty means all networks
+	Networks []string `json:"networks,omitempty"`
+	// Pubkeys optionally restricts the validators the kid can read. Empty (and no PubkeysFile) means all pubkeys
+	Pubkeys []string `json:"pubkeys,omitempty"`
+	// PubkeysFile is an optional allowlist file with one pubkey per line, relative to the users file directory.
+	// Its pubkeys are added to Pubkeys
+	PubkeysFile string `json:"pubkeysFile,omitempty"`
+	// Admin grants access to the admin endpoints, on top of the signatures of its tags
+	Admin bool `json:"admin,omitempty"`
+
+	// allowedPubkeys are Pubkeys and the ones of PubkeysFile, normalized. Loaded along with the users file
+	allowedPubkeys []string
 }
 
 type contextKey string
 
-const TagsKey contextKey = "tags"
+const (
+	TagsKey     contextKey = "tags"
+	NetworksKey contextKey = "networks"
+	PubkeysKey  contextKey = "pubkeys"
+	AdminKey    contextKey = "admin"
+)
+
+// UsersFile is the JWT users file, reloaded when it changes. See LoadUsersFile
+type UsersFile = ReloadingFile[map[string]KeyId]
+
+// NewUsersFile loads the users file, which must be valid. Later versions that are not valid are ignored
+func NewUsersFile(filePath string, tags types.TagRegistry, networks map[types.Network]bool) (*UsersFile, error) {
+	return NewReloadingFile(filePath, func(filePath string) (map[string]KeyId, error) {
+		return LoadUsersFile(filePath, tags, networks)
+	})
+}
+
+// RevocationFile is the JWT revocation file, reloaded when it changes. See jwtutil.LoadRevocationList
+type RevocationFile = ReloadingFile[*jwtutil.RevocationList]
 
-// JWTMiddleware dynamically checks tokens against public keys loaded from a JSON file
-func JWTMiddleware(next http.Handler, jwtUsersFilePath string) http.Handler {
+// NewRevocationFile loads the revocation file, which must be valid if it exists. A missing file means nothing is
+// revoked yet. Later versions that are not valid are ignored
+func NewRevocationFile(filePath string) (*RevocationFile, error) {
+	return NewOptionalReloadingFile(filePath, jwtutil.LoadRevocationList)
+}
+
+// JWTMiddleware dynamically checks tokens against the public keys of the users file. If revocationFile is not nil,
+// tokens revoked by jti or by kid are rejected.
+func JWTMiddleware(next http.Handler, usersFile *UsersFile, revocationFile *RevocationFile) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -37,12 +79,8 @@ func JWTMiddleware(next http.Handler, jwtUsersFilePath string) http.Handler {
 
 		tokenString := parts[1]
 
-		// Load all key ids from the whitelist JSON data file
-		keyIds, err := loadKeyIds(jwtUsersFilePath)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Internal server error: %v", err), http.StatusInternalServerError)
-			return
-		}
+		// Get all key ids of the whitelist JSON data file, as of its last valid version
+		keyIds := usersFile.Get()
 
 		// Parse and verify the token
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -83,6 +121,16 @@ func JWTMiddleware(next http.Handler, jwtUsersFilePath string) http.Handler {
 			return
 		}
 
+		// Check the token has not been revoked. The revocation file is reloaded when it changes, same as the users
+		// file, so revoking a token does not require restarting the listener
+		if revocationFile != nil {
+			jti, issuedAt := getJtiAndIssuedAt(token)
+			if revocationFile.Get().IsRevoked(kid, jti, issuedAt) {
+				http.Error(w, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+		}
+
 		// If the key id is found, but no tags are associated with it, it means the key is not authorized to access
 		// any signature. This should never happen.
 		if len(entry.Tags) == 0 {
@@ -90,13 +138,70 @@ func JWTMiddleware(next http.Handler, jwtUsersFilePath string) http.Handler {
 			return
 		}
 
-		// Store tags in context. We will use this in the handler to query MongoDB
+		// Networks and pubkeys are optional restrictions, nil in the context means the kid is not restricted
+		var pubkeys []string
+		if len(entry.Pubkeys) > 0 || entry.PubkeysFile != "" {
+			pubkeys = entry.allowedPubkeys
+			// Same as with tags, a pubkeys restriction without any pubkey does not grant access to anything
+			if len(pubkeys) == 0 {
+				http.Error(w, "no authorized pubkeys found for given kid", http.StatusUnauthorized)
+				return
+			}
+		}
+		var networks []string
+		if len(entry.Networks) > 0 {
+			networks = entry.Networks
+		}
+
+		// Store the kid scope in context. We will use this in the handler to query MongoDB
 		ctx := context.WithValue(r.Context(), TagsKey, entry.Tags)
+		ctx = context.WithValue(ctx, NetworksKey, networks)
+		ctx = context.WithValue(ctx, PubkeysKey, pubkeys)
+		ctx = context.WithValue(ctx, AdminKey, entry.Admin)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func loadKeyIds(filePath string) (map[string]KeyId, error) {
+// loadAllowedPubkeys merges the inline pubkeys of a kid with the ones of its allowlist file. Pubkeys are
+// normalized so they match the stored ones.
+func loadAllowedPubkeys(entry KeyId, usersFileDir string) ([]string, error) {
+	pubkeys := []string{}
+	for _, pubkey := range entry.Pubkeys {
+		pubkeys = append(pubkeys, types.NormalizePubkey(pubkey))
+	}
+	if entry.PubkeysFile == "" {
+		return pubkeys, nil
+	}
+
+	pubkeysFilePath := entry.PubkeysFile
+	if !filepath.IsAbs(pubkeysFilePath) {
+		pubkeysFilePath = filepath.Join(usersFileDir, pubkeysFilePath)
+	}
+	file, err := os.Open(pubkeysFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// one pubkey per line, empty lines and lines starting with # are ignored
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pubkeys = append(pubkeys, types.NormalizePubkey(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pubkeys, nil
+}
+
+// LoadUsersFile loads and validates the users file, along with the pubkeys files of its kids. Every tag of every kid
+// must be in the tag registry and every network in networks, so a typo in the file does not silently grant access to
+// nothing. The whole file is rejected if any kid is invalid
+func LoadUsersFile(filePath string, tags types.TagRegistry, networks map[types.Network]bool) (map[string]KeyId, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -107,5 +212,38 @@ func loadKeyIds(filePath string) (map[string]KeyId, error) {
 		return nil, err
 	}
 
+	for kid, entry := range keys {
+		for _, tag := range entry.Tags {
+			if _, ok := tags[types.Tag(tag)]; !ok {
+				return nil, fmt.Errorf("invalid users file: kid %s has unknown tag %s", kid, tag)
+			}
+		}
+		for _, network := range entry.Networks {
+			if !networks[types.Network(network)] {
+				return nil, fmt.Errorf("invalid users file: kid %s has unknown network %s", kid, network)
+			}
+		}
+		// The pubkeys files are read once per version of the users file, not on every request
+		entry.allowedPubkeys, err = loadAllowedPubkeys(entry, filepath.Dir(filePath))
+		if err != nil {
+			return nil, fmt.Errorf("invalid users file: kid %s: %w", kid, err)
+		}
+		keys[kid] = entry
+	}
+
 	return keys, nil
 }
+
+// getJtiAndIssuedAt returns the jti and iat claims of a parsed token. Missing claims are returned as zero values.
+func getJtiAndIssuedAt(token *jwt.Token) (string, time.Time) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", time.Unix(0, 0)
+	}
+	jti, _ := claims["jti"].(string)
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return jti, time.Unix(0, 0)
+	}
+	return jti, issuedAt.Time
+}
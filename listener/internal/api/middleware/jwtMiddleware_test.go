@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+func TestLoadUsersFile(t *testing.T) {
+	tags := types.NewTagRegistry(types.DefaultTags)
+	networks := map[types.Network]bool{types.Mainnet: true, types.Holesky: true}
+	dir := t.TempDir()
+	usersFilePath := filepath.Join(dir, "users.json")
+	if err := os.WriteFile(filepath.Join(dir, "pubkeys.txt"), []byte("# operator pubkeys\n\n0xBBBB\ncccc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		description   string
+		content       string
+		expectedError string
+	}{
+		{"Valid file", `{"a": {"publicKey": "key", "tags": ["solo"], "networks": ["mainnet"], "pubkeys": ["0xAAAA"], "pubkeysFile": "pubkeys.txt"}}`, ""},
+		{"Unknown tag", `{"a": {"publicKey": "key", "tags": ["lido"]}}`, "unknown tag lido"},
+		{"Unknown network", `{"a": {"publicKey": "key", "tags": ["solo"], "networks": ["mainet"]}}`, "unknown network mainet"},
+		{"Missing pubkeys file", `{"a": {"publicKey": "key", "tags": ["solo"], "pubkeysFile": "missing.txt"}}`, "missing.txt"},
+	}
+	for _, tc := range testCases {
+		if err := os.WriteFile(usersFilePath, []byte(tc.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		keyIds, err := LoadUsersFile(usersFilePath, tags, networks)
+		if tc.expectedError == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.description, err)
+		}
+		if tc.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedError)) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.description, tc.expectedError, err)
+		}
+		// the pubkeys of the file are loaded along with the users file, merged with the inline ones
+		if err == nil && !slices.Equal(keyIds["a"].allowedPubkeys, []string{"0xaaaa", "0xbbbb", "0xcccc"}) {
+			t.Errorf("%s: expected the inline and file pubkeys, got %v", tc.description, keyIds["a"].allowedPubkeys)
+		}
+	}
+}
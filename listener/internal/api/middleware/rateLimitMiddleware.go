@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// idle clients are removed from the limiter after this time, so the map does not grow forever
+const rateLimiterIdleTimeout = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter is a token bucket limiter per client: the client IP with RateLimitMiddleware, or the authenticated
+// submitter with SubmitterRateLimitMiddleware. Behind trusted proxies, the IP of the client is taken from
+// X-Forwarded-For.
+type RateLimiter struct {
+	mu             sync.Mutex
+	clients        map[string]*clientLimiter
+	rps            rate.Limit
+	burst          int
+	trustedProxies []netip.Prefix
+	lastCleanup    time.Time
+}
+
+// NewRateLimiter creates a limiter that allows rps requests per second per client, with bursts of up to burst requests
+func NewRateLimiter(rps float64, burst int, trustedProxies []netip.Prefix) *RateLimiter {
+	return &RateLimiter{
+		clients:        make(map[string]*clientLimiter),
+		rps:            rate.Limit(rps),
+		burst:          burst,
+		trustedProxies: trustedProxies,
+		lastCleanup:    time.Now(),
+	}
+}
+
+// reserve takes a token for the client and returns how long the client has to wait before its request
+// is allowed. A zero delay means the request is allowed now.
+func (l *RateLimiter) reserve(client string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) > rateLimiterIdleTimeout {
+		for key, c := range l.clients {
+			if now.Sub(c.lastSeen) > rateLimiterIdleTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	c, exists := l.clients[client]
+	if !exists {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[client] = c
+	}
+	c.lastSeen = now
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return rateLimiterIdleTimeout
+	}
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		// the request is rejected, so the token must be given back
+		reservation.CancelAt(now)
+	}
+	return delay
+}
+
+// RateLimitMiddleware rejects with 429 and a Retry-After header the requests of clients that are over the limit. It
+// must wrap the auth middlewares, so requests with invalid credentials are also limited
+func RateLimitMiddleware(next http.Handler, limiter *RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := limiter.reserve(limiter.clientIP(r)); delay > 0 {
+			rejectTooManyRequests(w, delay)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SubmitterRateLimitMiddleware is RateLimitMiddleware per submitter, so a submitter spread across IPs is limited as
+// one, and submitters behind the same IP are limited apart. It must run after SubmitterAuthMiddleware, which sets the
+// submitter in the context. Requests without a submitter are not limited by it
+func SubmitterRateLimitMiddleware(next http.Handler, limiter *RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitterId, ok := r.Context().Value(SubmitterKey).(string)
+		if ok {
+			if delay := limiter.reserve(submitterId); delay > 0 {
+				rejectTooManyRequests(w, delay)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func rejectTooManyRequests(w http.ResponseWriter, delay time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(delay.Seconds()))))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
+
+// clientIP returns the IP of the client of the request. If the request comes from a trusted proxy, it is the last IP
+// of X-Forwarded-For that is not a trusted proxy: every proxy appends the IP it got the request from, while the
+// first IPs can be forged by the client
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !l.isTrustedProxy(addr) {
+		return ip
+	}
+
+	forwardedFor := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwardedFor[i]))
+		if err != nil {
+			// a malformed hop was not added by a trusted proxy, the closest known IP is the client
+			break
+		}
+		addr = hop
+		if !l.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+func (l *RateLimiter) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range l.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// 1 request per second with a burst of 2: the third request in a row must be limited
+	handler := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), NewRateLimiter(1, 2, nil))
+
+	doRequest := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/signatures", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest("10.0.0.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, rec.Code)
+		}
+	}
+
+	rec := doRequest("10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected Retry-After header to be set")
+	}
+
+	// Other clients are not affected
+	if rec := doRequest("10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a different IP, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	limiter := NewRateLimiter(1, 2, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")})
+
+	testCases := []struct {
+		description  string
+		remoteAddr   string
+		forwardedFor []string
+		expectedIP   string
+	}{
+		{"Direct client", "203.0.113.1:1234", nil, "203.0.113.1"},
+		{"Untrusted client forging the header", "203.0.113.1:1234", []string{"198.51.100.1"}, "203.0.113.1"},
+		{"Behind a trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"Forged hop before the client", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"Chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.1, 192.168.1.1", "10.0.0.2"}, "198.51.100.1"},
+		{"Trusted proxy without header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"Malformed hop", "10.0.0.1:1234", []string{"198.51.100.1, not-an-ip"}, "10.0.0.1"},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/signatures", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for _, value := range tc.forwardedFor {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		if ip := limiter.clientIP(req); ip != tc.expectedIP {
+			t.Errorf("%s: expected client IP %s, got %s", tc.description, tc.expectedIP, ip)
+		}
+	}
+}
+
+func TestSubmitterRateLimitMiddleware(t *testing.T) {
+	// 1 request per second with a burst of 1 per submitter
+	handler := SubmitterRateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), NewRateLimiter(1, 1, nil))
+
+	doRequest := func(submitterId string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/signatures", nil)
+		req.RemoteAddr = remoteAddr
+		if submitterId != "" {
+			req = req.WithContext(context.WithValue(req.Context(), SubmitterKey, submitterId))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := doRequest("brain-1", "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	// the same submitter from another IP is limited
+	rec := doRequest("brain-1", "10.0.0.2:1234")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status %d with Retry-After, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	// another submitter behind the same IP is not
+	if rec := doRequest("brain-2", "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for another submitter, got %d", http.StatusOK, rec.Code)
+	}
+	// nor requests without a submitter
+	if rec := doRequest("", "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d without a submitter, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+// ReloadingFile is a file loaded again whenever it changes on disk, so it can be edited without restarting the
+// listener and without reading it on every request. A version that fails to load is logged and the last valid one
+// keeps being used, so a typo in the file does not lock everyone out
+type ReloadingFile[T any] struct {
+	path string
+	load func(filePath string) (T, error)
+	// optional files may be missing, load then returns their empty version
+	optional bool
+
+	mu      sync.Mutex
+	value   T
+	modTime time.Time
+	size    int64
+	// err is why the version on disk is not the one used, nil if it is
+	err error
+	// missing is whether the value is the empty version of a missing optional file
+	missing bool
+}
+
+// NewReloadingFile loads the file with load. Unlike a reload, an invalid file is an error
+func NewReloadingFile[T any](filePath string, load func(filePath string) (T, error)) (*ReloadingFile[T], error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	value, err := load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ReloadingFile[T]{path: filePath, load: load, value: value, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// NewOptionalReloadingFile is NewReloadingFile for a file that may not exist yet. load must return the empty version
+// of a missing file, which is used until the file is created, and again if it is removed
+func NewOptionalReloadingFile[T any](filePath string, load func(filePath string) (T, error)) (*ReloadingFile[T], error) {
+	if _, err := os.Stat(filePath); !errors.Is(err, os.ErrNotExist) {
+		file, err := NewReloadingFile(filePath, load)
+		if err != nil {
+			return nil, err
+		}
+		file.optional = true
+		return file, nil
+	}
+	value, err := load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ReloadingFile[T]{path: filePath, load: load, optional: true, value: value, missing: true}, nil
+}
+
+// Get returns the last valid version of the file, reloading it first if it changed
+func (f *ReloadingFile[T]) Get() T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil && f.optional && errors.Is(err, os.ErrNotExist) {
+		if !f.missing {
+			f.reload()
+			f.modTime, f.size, f.missing = time.Time{}, 0, f.err == nil
+		}
+		return f.value
+	}
+	if err != nil {
+		if f.err == nil {
+			logger.Error(fmt.Sprintf("Failed to read %s, keeping its last valid version: %v", f.path, err))
+		}
+		// reloaded as soon as it is back
+		f.modTime, f.size, f.err = time.Time{}, 0, err
+		return f.value
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.value
+	}
+
+	f.modTime, f.size, f.missing = info.ModTime(), info.Size(), false
+	f.reload()
+	return f.value
+}
+
+// reload loads the file again, keeping the last valid version if it fails. Must be called with the lock held
+func (f *ReloadingFile[T]) reload() {
+	value, err := f.load(f.path)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to reload %s, keeping its last valid version: %v", f.path, err))
+		f.err = err
+		return
+	}
+	logger.Info("Reloaded " + f.path)
+	f.value, f.err = value, nil
+}
+
+// Err returns why the version of the file on disk is not the one used, nil if it is
+func (f *ReloadingFile[T]) Err() error {
+	f.Get()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Path returns the path of the file
+func (f *ReloadingFile[T]) Path() string {
+	return f.path
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+func TestUsersFileReload(t *testing.T) {
+	tags := types.NewTagRegistry(types.DefaultTags)
+	usersFilePath := filepath.Join(t.TempDir(), "users.json")
+	writeUsersFile := func(content string, modTime time.Time) {
+		if err := os.WriteFile(usersFilePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(usersFilePath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the listener does not start with an invalid file
+	now := time.Now()
+	writeUsersFile(`{"a": {"publicKey": "key", "tags": ["lido"]}}`, now)
+	if _, err := NewUsersFile(usersFilePath, tags, nil); err == nil {
+		t.Fatalf("Expected an invalid users file to be rejected at startup")
+	}
+
+	writeUsersFile(`{"a": {"publicKey": "key", "tags": ["solo"]}}`, now)
+	usersFile, err := NewUsersFile(usersFilePath, tags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new valid version is used right away
+	writeUsersFile(`{"a": {"publicKey": "key", "tags": ["solo"]}, "b": {"publicKey": "key", "tags": ["obol"]}}`, now.Add(time.Minute))
+	if keyIds := usersFile.Get(); len(keyIds) != 2 || usersFile.Err() != nil {
+		t.Fatalf("Expected the new version with 2 kids, got %v, %v", keyIds, usersFile.Err())
+	}
+
+	// an invalid version keeps the last valid one, for every kid
+	writeUsersFile(`{"a": {"publicKey": "key", "tags": ["solo"]}, "b": {"publicKey": "key", "tags": ["lido"]}}`, now.Add(2*time.Minute))
+	if keyIds := usersFile.Get(); len(keyIds) != 2 || keyIds["b"].Tags[0] != "obol" {
+		t.Fatalf("Expected the last valid version to be kept, got %v", keyIds)
+	}
+	if usersFile.Err() == nil {
+		t.Errorf("Expected the error of the invalid version to be reported")
+	}
+
+	// and so does a deleted file
+	if err := os.Remove(usersFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if keyIds := usersFile.Get(); len(keyIds) != 2 {
+		t.Fatalf("Expected the last valid version to be kept, got %v", keyIds)
+	}
+
+	// once fixed, it is reloaded
+	writeUsersFile(`{"a": {"publicKey": "key", "tags": ["solo"]}}`, now.Add(3*time.Minute))
+	if keyIds := usersFile.Get(); len(keyIds) != 1 || usersFile.Err() != nil {
+		t.Fatalf("Expected the fixed version with 1 kid, got %v, %v", keyIds, usersFile.Err())
+	}
+}
+
+func TestRevocationFileReload(t *testing.T) {
+	revocationFilePath := filepath.Join(t.TempDir(), "revocations.json")
+	writeRevocationFile := func(content string, modTime time.Time) {
+		if err := os.WriteFile(revocationFilePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(revocationFilePath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a missing file means nothing is revoked yet
+	revocationFile, err := NewRevocationFile(revocationFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocationFile.Get().IsRevoked("a", "leaked", time.Now()) {
+		t.Fatalf("Expected nothing to be revoked without a revocation file")
+	}
+
+	// the first revocation is used right away
+	now := time.Now()
+	writeRevocationFile(`{"jtis": {"leaked": {"revokedAt": 1}}, "kids": {}}`, now)
+	if !revocationFile.Get().IsRevoked("a", "leaked", time.Now()) || revocationFile.Err() != nil {
+		t.Fatalf("Expected the created file to be loaded, got %v", revocationFile.Err())
+	}
+
+	// an invalid version keeps the last valid one, so revoked tokens stay revoked
+	writeRevocationFile(`{"jtis": `, now.Add(time.Minute))
+	if !revocationFile.Get().IsRevoked("a", "leaked", time.Now()) {
+		t.Fatalf("Expected the last valid version to be kept")
+	}
+	if revocationFile.Err() == nil {
+		t.Errorf("Expected the error of the invalid version to be reported")
+	}
+
+	// a removed file revokes nothing, same as at startup
+	if err := os.Remove(revocationFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if revocationFile.Get().IsRevoked("a", "leaked", time.Now()) || revocationFile.Err() != nil {
+		t.Fatalf("Expected nothing to be revoked once the file is removed, got %v", revocationFile.Err())
+	}
+
+	// the listener does not start with an invalid file
+	writeRevocationFile(`{"jtis": `, now)
+	if _, err := NewRevocationFile(revocationFilePath); err == nil {
+		t.Errorf("Expected an invalid revocation file to be rejected at startup")
+	}
+}
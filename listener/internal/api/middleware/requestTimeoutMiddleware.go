@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestTimeoutMiddleware sets a deadline on the context of the request. The calls to the beacon nodes and the
+// database made with it are cancelled once the response can no longer be written, or when the client disconnects
+func RequestTimeoutMiddleware(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	var ctxErr error
+	handler := RequestTimeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a slow dependency that honours the context
+		select {
+		case <-r.Context().Done():
+			ctxErr = r.Context().Err()
+		case <-time.After(time.Second):
+		}
+	}), 10*time.Millisecond)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/signatures", nil))
+	if !errors.Is(ctxErr, context.DeadlineExceeded) {
+		t.Errorf("Expected the request context to expire, got: %v", ctxErr)
+	}
+}
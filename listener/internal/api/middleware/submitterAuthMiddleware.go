@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+// Submitter is a dappnode instance allowed to post signatures
+type Submitter struct {
+	// ApiKeyHash is the hex encoded sha256 of the submitter API key. The key itself is never stored
+	ApiKeyHash string   `json:"apiKeyHash"`
+	Tags       []string `json:"tags"`
+}
+
+const (
+	SubmitterKey     contextKey = "submitter"
+	SubmitterTagsKey contextKey = "submitterTags"
+)
+
+// ApiKeyHeader is the header where submitters send their API key
+const ApiKeyHeader = "X-Api-Key"
+
+// SubmittersFile is the submitters file, reloaded when it changes. See LoadSubmittersFile
+type SubmittersFile = ReloadingFile[map[string]Submitter]
+
+// NewSubmittersFile loads the submitters file, which must be valid. Later versions that are not valid are ignored
+func NewSubmittersFile(filePath string, tags types.TagRegistry) (*SubmittersFile, error) {
+	return NewReloadingFile(filePath, func(filePath string) (map[string]Submitter, error) {
+		return LoadSubmittersFile(filePath, tags)
+	})
+}
+
+// SubmitterAuthMiddleware checks the API key of the request against the submitters of the submitters file and
+// stores the submitter id and its allowed tags in the context
+func SubmitterAuthMiddleware(next http.Handler, submittersFile *SubmittersFile) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get(ApiKeyHeader)
+		if apiKey == "" {
+			http.Error(w, ApiKeyHeader+" header is required", http.StatusUnauthorized)
+			return
+		}
+
+		// The submitters file is reloaded when it changes, so submitters can be added or removed without a restart
+		submitterId, submitter, found := findSubmitter(submittersFile.Get(), apiKey)
+		if !found {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		// Same as with JWT kids, a submitter without tags is not allowed to post any signature
+		if len(submitter.Tags) == 0 {
+			http.Error(w, "no authorized tags found for given submitter", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), SubmitterKey, submitterId)
+		ctx = context.WithValue(ctx, SubmitterTagsKey, submitter.Tags)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// findSubmitter returns the submitter whose API key hash matches the given key. Every hash is compared in
+// constant time to avoid leaking information about the stored hashes. Hashes are unique, see LoadSubmittersFile
+func findSubmitter(submitters map[string]Submitter, apiKey string) (string, Submitter, bool) {
+	apiKeyHash := sha256.Sum256([]byte(apiKey))
+	var foundId string
+	var found Submitter
+	for id, submitter := range submitters {
+		storedHash, err := decodeApiKeyHash(submitter.ApiKeyHash)
+		if err != nil {
+			continue
+		}
+		if subtle.ConstantTimeCompare(apiKeyHash[:], storedHash) == 1 {
+			foundId, found = id, submitter
+		}
+	}
+	return foundId, found, foundId != ""
+}
+
+// LoadSubmittersFile loads and validates the submitters file. Every API key hash must be a sha256 used by a single
+// submitter, otherwise which submitter posted a signature would depend on the map order. Every tag must be in the
+// tag registry, same as the tags of the JWT users file
+func LoadSubmittersFile(filePath string, tags types.TagRegistry) (map[string]Submitter, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var submitters map[string]Submitter
+	if err := json.Unmarshal(data, &submitters); err != nil {
+		return nil, err
+	}
+
+	submitterByHash := make(map[string]string)
+	for id, submitter := range submitters {
+		storedHash, err := decodeApiKeyHash(submitter.ApiKeyHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid submitters file: submitter %s: %w", id, err)
+		}
+		if other, ok := submitterByHash[string(storedHash)]; ok {
+			return nil, fmt.Errorf("invalid submitters file: submitters %s and %s have the same API key hash", other, id)
+		}
+		submitterByHash[string(storedHash)] = id
+		for _, tag := range submitter.Tags {
+			if _, ok := tags[types.Tag(tag)]; !ok {
+				return nil, fmt.Errorf("invalid submitters file: submitter %s has unknown tag %s", id, tag)
+			}
+		}
+	}
+
+	return submitters, nil
+}
+
+// decodeApiKeyHash decodes a hex encoded sha256, with or without 0x prefix
+func decodeApiKeyHash(apiKeyHash string) ([]byte, error) {
+	storedHash, err := hex.DecodeString(strings.TrimPrefix(apiKeyHash, "0x"))
+	if err != nil || len(storedHash) != sha256.Size {
+		return nil, fmt.Errorf("apiKeyHash must be a hex encoded sha256")
+	}
+	return storedHash, nil
+}
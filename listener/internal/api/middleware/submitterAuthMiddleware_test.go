@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+func apiKeyHash(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func writeSubmittersFile(t *testing.T, content string) string {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "submitters.json")
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestSubmitterAuthMiddleware(t *testing.T) {
+	tags := types.NewTagRegistry(types.DefaultTags)
+	submittersFile, err := NewSubmittersFile(writeSubmittersFile(t, `{
+		"instance-1": {"apiKeyHash": "`+apiKeyHash("key-1")+`", "tags": ["solo"]},
+		"instance-2": {"apiKeyHash": "0x`+apiKeyHash("key-2")+`", "tags": []}
+	}`), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var submitter string
+	var submitterTags []string
+	handler := SubmitterAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitter, _ = r.Context().Value(SubmitterKey).(string)
+		submitterTags, _ = r.Context().Value(SubmitterTagsKey).([]string)
+		w.WriteHeader(http.StatusOK)
+	}), submittersFile)
+
+	testCases := []struct {
+		description  string
+		apiKey       string
+		expectedCode int
+	}{
+		{"Missing API key", "", http.StatusUnauthorized},
+		{"Unknown API key", "key-3", http.StatusUnauthorized},
+		{"Submitter without tags", "key-2", http.StatusForbidden},
+		{"Valid API key", "key-1", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/signatures", nil)
+		if tc.apiKey != "" {
+			req.Header.Set(ApiKeyHeader, tc.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tc.description, tc.expectedCode, rec.Code)
+		}
+	}
+	if submitter != "instance-1" || !slices.Equal(submitterTags, []string{"solo"}) {
+		t.Errorf("Expected submitter instance-1 with tags [solo] in the context, got %s %v", submitter, submitterTags)
+	}
+}
+
+func TestLoadSubmittersFile(t *testing.T) {
+	tags := types.NewTagRegistry(types.DefaultTags)
+	testCases := []struct {
+		description   string
+		content       string
+		expectedError string
+	}{
+		{"Valid file", `{"a": {"apiKeyHash": "` + apiKeyHash("key-1") + `", "tags": ["solo"]}, "b": {"apiKeyHash": "` + apiKeyHash("key-2") + `", "tags": ["obol"]}}`, ""},
+		{"Duplicated hash", `{"a": {"apiKeyHash": "` + apiKeyHash("key-1") + `", "tags": ["solo"]}, "b": {"apiKeyHash": "0x` + apiKeyHash("key-1") + `", "tags": ["obol"]}}`, "same API key hash"},
+		{"Invalid hash", `{"a": {"apiKeyHash": "key-1", "tags": ["solo"]}}`, "must be a hex encoded sha256"},
+		{"Unknown tag", `{"a": {"apiKeyHash": "` + apiKeyHash("key-1") + `", "tags": ["lido"]}}`, "unknown tag lido"},
+	}
+	for _, tc := range testCases {
+		_, err := LoadSubmittersFile(writeSubmittersFile(t, tc.content), tags)
+		if tc.expectedError == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.description, err)
+		}
+		if tc.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedError)) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.description, tc.expectedError, err)
+		}
+	}
+}
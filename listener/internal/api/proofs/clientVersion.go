@@ -0,0 +1,28 @@
+package proofs
+
+import "github.com/dappnode/validator-monitoring/listener/internal/api/types"
+
+// ClientVersionType reports the clients a validator is running with, e.g.
+// {"type":"CLIENT_VERSION",...,"consensusClient":"lighthouse/v5.1.3","executionClient":"geth/v1.14.0"}
+const ClientVersionType = "CLIENT_VERSION"
+
+type clientVersion struct{}
+
+func (clientVersion) Type() string { return ClientVersionType }
+
+func (clientVersion) Validate(payload types.DecodedPayload) error {
+	if _, err := stringField(payload, "consensusClient"); err != nil {
+		return err
+	}
+	_, err := stringField(payload, "executionClient")
+	return err
+}
+
+func (clientVersion) StoredFields(payload types.DecodedPayload) map[string]interface{} {
+	consensusClient, _ := stringField(payload, "consensusClient")
+	executionClient, _ := stringField(payload, "executionClient")
+	return map[string]interface{}{
+		"consensusClient": consensusClient,
+		"executionClient": executionClient,
+	}
+}
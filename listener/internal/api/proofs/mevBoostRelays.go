@@ -0,0 +1,49 @@
+package proofs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+// MevBoostRelaysType attests the MEV-boost relays a validator is registered with, e.g.
+// {"type":"MEV_BOOST_RELAYS",...,"relays":["https://0xac6e...@boost-relay.flashbots.net"]}
+const MevBoostRelaysType = "MEV_BOOST_RELAYS"
+
+type mevBoostRelays struct{}
+
+func (mevBoostRelays) Type() string { return MevBoostRelaysType }
+
+func (mevBoostRelays) Validate(payload types.DecodedPayload) error {
+	_, err := getRelays(payload)
+	return err
+}
+
+func (mevBoostRelays) StoredFields(payload types.DecodedPayload) map[string]interface{} {
+	relays, _ := getRelays(payload)
+	return map[string]interface{}{
+		"relays": relays,
+	}
+}
+
+// getRelays returns the relays of the payload, an array of https URLs. An empty array means no relay is used
+func getRelays(payload types.DecodedPayload) ([]string, error) {
+	value, ok := payload.Fields["relays"]
+	if !ok {
+		return nil, errors.New(`missing field "relays"`)
+	}
+	var relays []string
+	if len(value) == 0 || value[0] != '[' || json.Unmarshal(value, &relays) != nil {
+		return nil, errors.New(`field "relays" must be an array of strings`)
+	}
+	for _, relay := range relays {
+		parsed, err := url.Parse(relay)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid relay URL %q, it must be an https URL", relay)
+		}
+	}
+	return relays, nil
+}
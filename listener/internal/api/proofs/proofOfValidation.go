@@ -0,0 +1,17 @@
+package proofs
+
+import "github.com/dappnode/validator-monitoring/listener/internal/api/types"
+
+// ProofOfValidationType is the proof sent periodically by the brain for every validator, to prove it is still
+// running in a dappnode. It is the default proof type, its payload is only the envelope
+const ProofOfValidationType = "PROOF_OF_VALIDATION"
+
+type proofOfValidation struct{}
+
+func (proofOfValidation) Type() string { return ProofOfValidationType }
+
+func (proofOfValidation) Validate(payload types.DecodedPayload) error { return nil }
+
+func (proofOfValidation) StoredFields(payload types.DecodedPayload) map[string]interface{} {
+	return nil
+}
@@ -0,0 +1,79 @@
+package proofs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+// Handler validates and stores the payloads of a proof type. Every payload shares the same envelope (type, platform,
+// timestamp and version), validated before the handler of its type is called. The rest of the payload fields belong
+// to the proof type.
+type Handler interface {
+	// Type is the payload type handled, e.g. PROOF_OF_VALIDATION
+	Type() string
+	// Validate checks the fields of the payload specific to the proof type
+	Validate(payload types.DecodedPayload) error
+	// StoredFields returns the fields specific to the proof type stored in the entry, next to the envelope ones
+	StoredFields(payload types.DecodedPayload) map[string]interface{}
+}
+
+// Registry is the set of proof types accepted by the listener, indexed by type
+type Registry map[string]Handler
+
+// AvailableHandlers are all the proof types the listener knows how to handle
+var AvailableHandlers = []Handler{
+	proofOfValidation{},
+	clientVersion{},
+	mevBoostRelays{},
+}
+
+// DefaultTypes are the proof types accepted when none are configured
+var DefaultTypes = []string{ProofOfValidationType}
+
+// NewRegistry builds the registry of the given proof types
+func NewRegistry(proofTypes []string) (Registry, error) {
+	registry := make(Registry)
+	for _, proofType := range proofTypes {
+		handler, ok := findAvailableHandler(proofType)
+		if !ok {
+			return nil, fmt.Errorf("unknown proof type %s, available types are: %v", proofType, AvailableTypes())
+		}
+		registry[proofType] = handler
+	}
+	return registry, nil
+}
+
+// AvailableTypes returns the sorted types of the available handlers
+func AvailableTypes() []string {
+	availableTypes := make([]string, len(AvailableHandlers))
+	for i, handler := range AvailableHandlers {
+		availableTypes[i] = handler.Type()
+	}
+	sort.Strings(availableTypes)
+	return availableTypes
+}
+
+func findAvailableHandler(proofType string) (Handler, bool) {
+	for _, handler := range AvailableHandlers {
+		if handler.Type() == proofType {
+			return handler, true
+		}
+	}
+	return nil, false
+}
+
+// stringField returns a required string field of the payload
+func stringField(payload types.DecodedPayload, name string) (string, error) {
+	value, ok := payload.Fields[name]
+	if !ok {
+		return "", fmt.Errorf("missing field %q", name)
+	}
+	var str string
+	if len(value) == 0 || value[0] != '"' || json.Unmarshal(value, &str) != nil || str == "" {
+		return "", fmt.Errorf("field %q must be a non empty string", name)
+	}
+	return str, nil
+}
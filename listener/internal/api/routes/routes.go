@@ -2,28 +2,93 @@ package routes
 
 import (
 	"net/http"
+	"net/netip"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/api/handlers"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/middleware"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
+	apiCron "github.com/dappnode/validator-monitoring/listener/internal/cron"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupRouter(dbCollection *mongo.Collection, beaconNodeUrls map[types.Network]string, maxEntriesPerBson int, jwtUsersFilePath string) *mux.Router {
+// Limits are the request size and rate limits applied to the signatures routes
+type Limits struct {
+	MaxBodyBytes            int64
+	MaxSignaturesPerRequest int
+	// RateLimitRPS is the number of requests per second allowed per client IP. 0 disables rate limiting
+	RateLimitRPS   float64
+	RateLimitBurst int
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header gives the IP of the client
+	TrustedProxies []netip.Prefix
+}
+
+func SetupRouter(signatureStore store.SignatureStore, beaconNodeUrls map[types.Network][]string, validatorsStatusCache *validation.ValidatorsStatusCache, usersFile *middleware.UsersFile, revocationFile *middleware.RevocationFile, submittersFile *middleware.SubmittersFile, limits Limits, requireClientCert bool, tags types.TagRegistry, proofTypes proofs.Registry, scheduler *apiCron.Scheduler) *mux.Router {
 	r := mux.NewRouter()
 
+	// rateLimit wraps a handler with the rate limiter, if enabled. Both signatures routes share the same limiter, it
+	// is the outermost middleware so requests rejected by auth are limited too
+	rateLimit := func(next http.Handler) http.Handler { return next }
+	// submitterRateLimit wraps a handler with the limiter per submitter, with the same limits. It runs after the
+	// submitter auth
+	submitterRateLimit := func(next http.Handler) http.Handler { return next }
+	if limits.RateLimitRPS > 0 {
+		limiter := middleware.NewRateLimiter(limits.RateLimitRPS, limits.RateLimitBurst, limits.TrustedProxies)
+		rateLimit = func(next http.Handler) http.Handler {
+			return middleware.RateLimitMiddleware(next, limiter)
+		}
+		submitterLimiter := middleware.NewRateLimiter(limits.RateLimitRPS, limits.RateLimitBurst, nil)
+		submitterRateLimit = func(next http.Handler) http.Handler {
+			return middleware.SubmitterRateLimitMiddleware(next, submitterLimiter)
+		}
+	}
+
 	// Define routes
 	r.HandleFunc("/", handlers.GetHealthCheck).Methods(http.MethodGet)
-	// closure function to inject dbCollection into the handler
-	r.HandleFunc("/signatures", func(w http.ResponseWriter, r *http.Request) {
-		handlers.PostSignatures(w, r, dbCollection, beaconNodeUrls, maxEntriesPerBson)
-	}).Methods(http.MethodPost)
+	// liveness and readiness probes, without auth nor rate limit so orchestrators can poll them
+	r.HandleFunc("/livez", handlers.GetLivez).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetReadyz(w, r, signatureStore, beaconNodeUrls, usersFile)
+	}).Methods(http.MethodGet)
+	// closure function to inject signatureStore into the handler
+	var postSignaturesHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.PostSignatures(w, r, signatureStore, beaconNodeUrls, validatorsStatusCache, limits.MaxBodyBytes, limits.MaxSignaturesPerRequest, tags, proofTypes)
+	})
+	// submitter auth is optional, if enabled (submittersFile not nil) it uses the API key middleware
+	if submittersFile != nil {
+		postSignaturesHandler = middleware.SubmitterAuthMiddleware(submitterRateLimit(postSignaturesHandler), submittersFile)
+	}
+	r.Handle("/signatures", rateLimit(postSignaturesHandler)).Methods(http.MethodPost)
 
 	// this method uses JWTmiddleware as auth
-	r.Handle("/signatures", middleware.JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetSignatures(w, r, dbCollection)
-	}), jwtUsersFilePath)).Methods(http.MethodGet)
+	getSignaturesHandler := middleware.JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetSignatures(w, r, signatureStore)
+	}), usersFile, revocationFile)
+	// with mTLS enabled, read endpoints also require a client certificate on top of the JWT
+	if requireClientCert {
+		getSignaturesHandler = middleware.ClientCertMiddleware(getSignaturesHandler)
+	}
+	r.Handle("/signatures", rateLimit(getSignaturesHandler)).Methods(http.MethodGet)
+
+	// admin routes require the kid of the JWT to be an admin, and a client certificate with mTLS enabled
+	admin := func(next http.HandlerFunc) http.Handler {
+		handler := middleware.JWTMiddleware(middleware.AdminMiddleware(next), usersFile, revocationFile)
+		if requireClientCert {
+			handler = middleware.ClientCertMiddleware(handler)
+		}
+		return handler
+	}
+	r.Handle("/admin/crons", admin(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetCronJobs(w, r, scheduler)
+	})).Methods(http.MethodGet)
+	r.Handle("/admin/crons/{name}/run", admin(func(w http.ResponseWriter, r *http.Request) {
+		handlers.PostCronJobRun(w, r, scheduler)
+	})).Methods(http.MethodPost)
+	r.Handle("/admin/indexes", admin(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetIndexes(w, r, signatureStore)
+	})).Methods(http.MethodGet)
 
 	return r
 }
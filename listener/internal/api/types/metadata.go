@@ -0,0 +1,87 @@
+package types
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Metadata describes the node a proof was sent from, so failures can be correlated with the software a validator
+// runs. Every field is optional. It can be sent in the signed payload and in the request, the signed one wins.
+type Metadata struct {
+	BrainVersion    string `json:"brainVersion,omitempty" bson:"brainVersion,omitempty"`
+	ConsensusClient string `json:"consensusClient,omitempty" bson:"consensusClient,omitempty"`
+	ExecutionClient string `json:"executionClient,omitempty" bson:"executionClient,omitempty"`
+	DappnodeVersion string `json:"dappnodeVersion,omitempty" bson:"dappnodeVersion,omitempty"`
+	Web3Signer      string `json:"web3signer,omitempty" bson:"web3signer,omitempty"`
+}
+
+// MetadataFields are the JSON names of the metadata fields, also used as GET /signatures filters
+var MetadataFields = []string{"brainVersion", "consensusClient", "executionClient", "dappnodeVersion", "web3signer"}
+
+// UnmarshalJSON decodes the fields that are exactly one of MetadataFields. Other fields are ignored, and so never
+// stored, so a newer brain can send fields this listener does not know, same as the fields of the payload.
+// encoding/json alone would also match the fields case-insensitively
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		if !slices.Contains(MetadataFields, name) {
+			delete(fields, name)
+		}
+	}
+	known, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	// metadata without methods, so decoding it does not call UnmarshalJSON again
+	type metadata Metadata
+	var decoded metadata
+	if err := json.Unmarshal(known, &decoded); err != nil {
+		return err
+	}
+	*m = Metadata(decoded)
+	return nil
+}
+
+// Fields returns the metadata fields by JSON name
+func (m Metadata) Fields() map[string]string {
+	return map[string]string{
+		"brainVersion":    m.BrainVersion,
+		"consensusClient": m.ConsensusClient,
+		"executionClient": m.ExecutionClient,
+		"dappnodeVersion": m.DappnodeVersion,
+		"web3signer":      m.Web3Signer,
+	}
+}
+
+// IsEmpty returns whether no metadata field is set
+func (m Metadata) IsEmpty() bool {
+	return m == Metadata{}
+}
+
+// Merge returns the metadata with the fields not set filled from other
+func (m Metadata) Merge(other *Metadata) Metadata {
+	if other == nil {
+		return m
+	}
+	merged := m
+	if merged.BrainVersion == "" {
+		merged.BrainVersion = other.BrainVersion
+	}
+	if merged.ConsensusClient == "" {
+		merged.ConsensusClient = other.ConsensusClient
+	}
+	if merged.ExecutionClient == "" {
+		merged.ExecutionClient = other.ExecutionClient
+	}
+	if merged.DappnodeVersion == "" {
+		merged.DappnodeVersion = other.DappnodeVersion
+	}
+	if merged.Web3Signer == "" {
+		merged.Web3Signer = other.Web3Signer
+	}
+	return merged
+}
@@ -0,0 +1,67 @@
+package types
+
+// NetworkSpec is the chain metadata of a network. It is used to check that the beacon nodes of a network
+// are actually on that chain
+type NetworkSpec struct {
+	Name           Network
+	GenesisTime    uint64
+	SecondsPerSlot uint64
+	SlotsPerEpoch  uint64
+	// GenesisValidatorsRoot is the 0x prefixed hex root returned by /eth/v1/beacon/genesis
+	GenesisValidatorsRoot string
+	// GenesisForkVersion is the 0x prefixed hex fork version returned by /eth/v1/beacon/genesis
+	GenesisForkVersion string
+}
+
+// DefaultNetworkSpecs are the networks known by the listener. Other networks can be added, and these ones
+// overridden, from the config file
+var DefaultNetworkSpecs = map[Network]NetworkSpec{
+	Mainnet: {
+		Name:                  Mainnet,
+		GenesisTime:           1606824023,
+		SecondsPerSlot:        12,
+		SlotsPerEpoch:         32,
+		GenesisValidatorsRoot: "0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95",
+		GenesisForkVersion:    "0x00000000",
+	},
+	Holesky: {
+		Name:                  Holesky,
+		GenesisTime:           1695902400,
+		SecondsPerSlot:        12,
+		SlotsPerEpoch:         32,
+		GenesisValidatorsRoot: "0x9143aa7c615a7f7115e2b6aac319c03529df8242ae705fba9df39b79c59fa8b1",
+		GenesisForkVersion:    "0x01017000",
+	},
+	Gnosis: {
+		Name:                  Gnosis,
+		GenesisTime:           1638993340,
+		SecondsPerSlot:        5,
+		SlotsPerEpoch:         16,
+		GenesisValidatorsRoot: "0xf5dcb5564e829aab27264b9becd5dfaa017085611224cb3036f573368dbb9d47",
+		GenesisForkVersion:    "0x00000064",
+	},
+	Lukso: {
+		Name:                  Lukso,
+		GenesisTime:           1684856400,
+		SecondsPerSlot:        12,
+		SlotsPerEpoch:         32,
+		GenesisValidatorsRoot: "0xd7cc24d150c617450dfa8176ef45a01dadb885a75a1a4c32d4a6828f8f088760",
+		GenesisForkVersion:    "0x42000001",
+	},
+	Hoodi: {
+		Name:                  Hoodi,
+		GenesisTime:           1742213400,
+		SecondsPerSlot:        12,
+		SlotsPerEpoch:         32,
+		GenesisValidatorsRoot: "0x212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f",
+		GenesisForkVersion:    "0x10000910",
+	},
+	Sepolia: {
+		Name:                  Sepolia,
+		GenesisTime:           1655733600,
+		SecondsPerSlot:        12,
+		SlotsPerEpoch:         32,
+		GenesisValidatorsRoot: "0xd8ea171f3c94aea21ebc42a1ed61052acf3f9209c00e4efbaaddac09ed9b8078",
+		GenesisForkVersion:    "0x90000069",
+	},
+}
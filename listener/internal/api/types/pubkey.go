@@ -0,0 +1,13 @@
+package types
+
+import "strings"
+
+// NormalizePubkey returns the pubkey lowercased and with the 0x prefix, the format sent by the brain and returned by
+// the beacon node. Pubkeys are stored and compared in this format, so a pubkey in uppercase hex is the same validator
+func NormalizePubkey(pubkey string) string {
+	pubkey = strings.ToLower(strings.TrimSpace(pubkey))
+	if !strings.HasPrefix(pubkey, "0x") {
+		pubkey = "0x" + pubkey
+	}
+	return pubkey
+}
@@ -0,0 +1,51 @@
+package types
+
+// TagSpec is a staking protocol tag accepted by the listener
+type TagSpec struct {
+	Name Tag `yaml:"name"`
+	// DisplayName is a human readable name of the tag, e.g. "Rocket Pool"
+	DisplayName string `yaml:"displayName"`
+	// Networks optionally restricts the networks the tag can be used in. Empty means any network
+	Networks []Network `yaml:"networks"`
+}
+
+// TagRegistry are the tags accepted by the listener, by name
+type TagRegistry map[Tag]TagSpec
+
+// DefaultTags are the tags accepted when no tags are configured, in sync with brain
+var DefaultTags = []TagSpec{
+	{Name: Obol, DisplayName: "Obol"},
+	{Name: Diva, DisplayName: "Diva"},
+	{Name: Ssv, DisplayName: "SSV"},
+	{Name: Rocketpool, DisplayName: "Rocket Pool"},
+	{Name: Stakewise, DisplayName: "StakeWise"},
+	{Name: Stakehouse, DisplayName: "Stakehouse"},
+	{Name: Solo, DisplayName: "Solo"},
+	{Name: Stader, DisplayName: "Stader"},
+}
+
+// NewTagRegistry builds a registry from a list of tags
+func NewTagRegistry(tags []TagSpec) TagRegistry {
+	registry := make(TagRegistry, len(tags))
+	for _, tag := range tags {
+		registry[tag.Name] = tag
+	}
+	return registry
+}
+
+// IsAllowed returns whether the tag exists and can be used in the network
+func (r TagRegistry) IsAllowed(tag Tag, network Network) bool {
+	spec, ok := r[tag]
+	if !ok {
+		return false
+	}
+	if len(spec.Networks) == 0 {
+		return true
+	}
+	for _, allowedNetwork := range spec.Networks {
+		if allowedNetwork == network {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,23 @@
 package types
 
-// In sync with brain
-type Network string // "mainnet" | "holesky" | "gnosis" | "lukso"
+import "encoding/json"
+
+// In sync with brain. Networks are not a closed set, any network defined in the registry can be served
+// @see DefaultNetworkSpecs
+type Network string // "mainnet" | "holesky" | "gnosis" | "lukso" | "hoodi" | "sepolia"
 
 const (
 	Mainnet Network = "mainnet"
 	Holesky Network = "holesky"
 	Gnosis  Network = "gnosis"
 	Lukso   Network = "lukso"
+	Hoodi   Network = "hoodi"
+	Sepolia Network = "sepolia"
 )
 
-// In sync with brain
+// In sync with brain. These are the default tags, the accepted ones are loaded from config
 // @see https://github.com/dappnode/StakingBrain/blob/0aaeefa8aec1b21ba2f2882cb444747419a3ff5d/packages/common/src/types/db/types.ts#L27
+// @see DefaultTags
 type Tag string //  "obol" | "diva" | "ssv" | "rocketpool" | "stakewise" | "stakehouse" | "solo" | "stader"
 
 const (
@@ -30,14 +36,26 @@ type SignatureRequest struct {
 	Pubkey    string `json:"pubkey"`
 	Signature string `json:"signature"`
 	Tag       Tag    `json:"tag"`
+	// Metadata is optional and not signed, the metadata of the signed payload takes precedence
+	Metadata *Metadata `json:"metadata,omitempty"`
 }
 
 type DecodedPayload struct {
 	Type      string `json:"type"`
 	Platform  string `json:"platform"`
 	Timestamp string `json:"timestamp"`
+	// Version selects what the validator signed, see PayloadVersionRaw
+	Version string `json:"version,omitempty"`
+	// Metadata is the optional signed metadata of the node
+	Metadata Metadata `json:"metadata,omitempty"`
+	// Fields are the raw payload fields besides the envelope ones above, specific to the proof type
+	Fields map[string]json.RawMessage `json:"-"`
 }
 
+// PayloadVersionRaw payloads are signed as raw bytes, without domain. An empty version is also raw, it is what the
+// first clients sent. It is the only version so far
+const PayloadVersionRaw = "1"
+
 type SignatureRequestDecoded struct {
 	DecodedPayload DecodedPayload `json:"decodedPayload"`
 	SignatureRequest
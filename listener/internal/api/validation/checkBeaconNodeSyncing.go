@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// https://ethereum.github.io/beacon-APIs/#/Node/getSyncingStatus /eth/v1/node/syncing
+type syncingApiResponse struct {
+	Data struct {
+		HeadSlot     string `json:"head_slot"`
+		SyncDistance string `json:"sync_distance"`
+		IsSyncing    bool   `json:"is_syncing"`
+		IsOptimistic bool   `json:"is_optimistic"`
+		ElOffline    bool   `json:"el_offline"`
+	} `json:"data"`
+}
+
+// BeaconNodeSyncStatus is the sync status reported by a beacon node
+type BeaconNodeSyncStatus struct {
+	HeadSlot     string `json:"headSlot"`
+	SyncDistance string `json:"syncDistance"`
+	IsSyncing    bool   `json:"isSyncing"`
+	IsOptimistic bool   `json:"isOptimistic"`
+	ElOffline    bool   `json:"elOffline"`
+}
+
+// Synced returns whether the beacon node can be trusted for the status of the validators. An optimistic beacon node
+// or one without execution client may report outdated statuses
+func (s BeaconNodeSyncStatus) Synced() bool {
+	return !s.IsSyncing && !s.IsOptimistic && !s.ElOffline
+}
+
+// CheckBeaconNodeSyncing returns the sync status of the beacon node. An error means the beacon node could not be reached
+// within timeout
+func CheckBeaconNodeSyncing(ctx context.Context, beaconNodeUrl string, timeout time.Duration) (BeaconNodeSyncStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/eth/v1/node/syncing", beaconNodeUrl), nil)
+	if err != nil {
+		return BeaconNodeSyncStatus{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return BeaconNodeSyncStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return BeaconNodeSyncStatus{}, fmt.Errorf("unexpected response status from beacon node when retrieving sync status: %s", resp.Status)
+	}
+
+	var apiResponse syncingApiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return BeaconNodeSyncStatus{}, fmt.Errorf("error decoding sync status from beacon node: %w", err)
+	}
+	return BeaconNodeSyncStatus(apiResponse.Data), nil
+}
@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newSyncingServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eth/v1/node/syncing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestCheckBeaconNodeSyncing(t *testing.T) {
+	syncedServer := newSyncingServer(`{"data":{"head_slot":"100","sync_distance":"0","is_syncing":false,"is_optimistic":false,"el_offline":false}}`)
+	defer syncedServer.Close()
+	optimisticServer := newSyncingServer(`{"data":{"head_slot":"100","sync_distance":"0","is_syncing":false,"is_optimistic":true,"el_offline":false}}`)
+	defer optimisticServer.Close()
+
+	status, err := CheckBeaconNodeSyncing(context.Background(), syncedServer.URL, 5*time.Second)
+	if err != nil || !status.Synced() {
+		t.Errorf("Expected a synced beacon node, got: %+v, %v", status, err)
+	}
+
+	status, err = CheckBeaconNodeSyncing(context.Background(), optimisticServer.URL, 5*time.Second)
+	if err != nil || status.Synced() {
+		t.Errorf("Expected an optimistic beacon node not to be synced, got: %+v, %v", status, err)
+	}
+
+	if _, err := CheckBeaconNodeSyncing(context.Background(), "http://127.0.0.1:1", 5*time.Second); err == nil {
+		t.Errorf("Expected an error for a beacon node that is down")
+	}
+}
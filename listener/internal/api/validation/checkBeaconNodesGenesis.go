@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+// https://ethereum.github.io/beacon-APIs/#/Beacon/getGenesis /eth/v1/beacon/genesis
+type genesisApiResponse struct {
+	Data struct {
+		GenesisTime           string `json:"genesis_time"`
+		GenesisValidatorsRoot string `json:"genesis_validators_root"`
+		GenesisForkVersion    string `json:"genesis_fork_version"`
+	} `json:"data"`
+}
+
+// CheckBeaconNodesGenesis checks that every beacon node is on the chain of its network, comparing its genesis with the
+// network registry. A beacon node on the wrong chain would report every validator as inactive, so it is an error.
+// Beacon nodes that can not be reached within timeout are only logged, since they may be temporarily down.
+func CheckBeaconNodesGenesis(ctx context.Context, beaconNodeUrls map[types.Network][]string, networkSpecs map[types.Network]types.NetworkSpec, timeout time.Duration) error {
+	var mismatches []string
+	for network, urls := range beaconNodeUrls {
+		spec, ok := networkSpecs[network]
+		if !ok {
+			return fmt.Errorf("network %s has no chain metadata in the registry", network)
+		}
+		for _, url := range urls {
+			matches, err := CheckBeaconNodeGenesis(ctx, url, spec, timeout)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("Could not check the genesis of beacon node %s for network %s: %v", url, network, err))
+				continue
+			}
+			if !matches {
+				mismatches = append(mismatches, fmt.Sprintf("beacon node %s is not on the %s chain", url, network))
+				continue
+			}
+			logger.Info(fmt.Sprintf("Beacon node %s is on the %s chain", url, network))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("beacon nodes on the wrong chain: %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// CheckBeaconNodeGenesis returns whether the genesis of the beacon node matches the network spec. An error means the
+// genesis could not be retrieved.
+func CheckBeaconNodeGenesis(ctx context.Context, beaconNodeUrl string, spec types.NetworkSpec, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/eth/v1/beacon/genesis", beaconNodeUrl), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected response status from beacon node when retrieving genesis: %s", resp.Status)
+	}
+
+	var apiResponse genesisApiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return false, fmt.Errorf("error decoding genesis from beacon node: %w", err)
+	}
+
+	if !strings.EqualFold(apiResponse.Data.GenesisValidatorsRoot, spec.GenesisValidatorsRoot) {
+		logger.Error(fmt.Sprintf("Beacon node %s genesis validators root %s does not match the %s one %s", beaconNodeUrl, apiResponse.Data.GenesisValidatorsRoot, spec.Name, spec.GenesisValidatorsRoot))
+		return false, nil
+	}
+	if genesisTime, err := strconv.ParseUint(apiResponse.Data.GenesisTime, 10, 64); err != nil || genesisTime != spec.GenesisTime {
+		logger.Error(fmt.Sprintf("Beacon node %s genesis time %s does not match the %s one %d", beaconNodeUrl, apiResponse.Data.GenesisTime, spec.Name, spec.GenesisTime))
+		return false, nil
+	}
+	if !strings.EqualFold(apiResponse.Data.GenesisForkVersion, spec.GenesisForkVersion) {
+		logger.Error(fmt.Sprintf("Beacon node %s genesis fork version %s does not match the %s one %s", beaconNodeUrl, apiResponse.Data.GenesisForkVersion, spec.Name, spec.GenesisForkVersion))
+		return false, nil
+	}
+	return true, nil
+}
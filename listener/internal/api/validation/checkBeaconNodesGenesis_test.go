@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+func newGenesisServer(genesisValidatorsRoot string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eth/v1/beacon/genesis" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"genesis_time":"1695902400","genesis_validators_root":"` + genesisValidatorsRoot + `","genesis_fork_version":"0x01017000"}}`))
+	}))
+}
+
+func TestCheckBeaconNodesGenesis(t *testing.T) {
+	holeskyServer := newGenesisServer(types.DefaultNetworkSpecs[types.Holesky].GenesisValidatorsRoot)
+	defer holeskyServer.Close()
+	wrongChainServer := newGenesisServer(types.DefaultNetworkSpecs[types.Mainnet].GenesisValidatorsRoot)
+	defer wrongChainServer.Close()
+
+	networkSpecs := map[types.Network]types.NetworkSpec{
+		types.Holesky: types.DefaultNetworkSpecs[types.Holesky],
+	}
+
+	// A beacon node that is down is not an error, it may be temporarily down
+	err := CheckBeaconNodesGenesis(context.Background(), map[types.Network][]string{
+		types.Holesky: {holeskyServer.URL, "http://127.0.0.1:1"},
+	}, networkSpecs, 5*time.Second)
+	if err != nil {
+		t.Errorf("Expected no error for beacon nodes on the right chain, got: %v", err)
+	}
+
+	err = CheckBeaconNodesGenesis(context.Background(), map[types.Network][]string{
+		types.Holesky: {holeskyServer.URL, wrongChainServer.URL},
+	}, networkSpecs, 5*time.Second)
+	if err == nil {
+		t.Errorf("Expected an error for a beacon node on the wrong chain")
+	}
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -34,17 +35,16 @@ type activeValidatorsApiResponse struct {
 	} `json:"data"`
 }
 
-// GetValidatorsStatus checks the active status of validators from a specific beacon node.
+// GetValidatorsStatus checks the active status of validators from the beacon nodes of a network. The beacon nodes
+// are tried in order, the next one is used only if the previous one is down. Every beacon node has up to timeout to
+// answer, so a beacon node that hangs does not prevent trying the next one.
 // @returns validatorStatusMap error
-func GetValidatorsStatus(pubkeys []string, beaconNodeUrl string) (map[string]types.Status, error) {
+func GetValidatorsStatus(ctx context.Context, pubkeys []string, beaconNodeUrls []string, timeout time.Duration) (map[string]types.Status, error) {
 	if len(pubkeys) == 0 {
 		logger.Warn("No public keys provided to retrieve active validators")
 		return nil, fmt.Errorf("no public keys provided to retrieve active validators from beacon node")
 	}
 
-	// Use a map to store validator statuses
-	statusMap := make(map[string]types.Status)
-
 	// Serialize the request body to JSON
 	// See https://ethereum.github.io/beacon-APIs/#/Beacon/postStateValidators
 	// returns only active validators
@@ -60,35 +60,62 @@ func GetValidatorsStatus(pubkeys []string, beaconNodeUrl string) (map[string]typ
 		return nil, err
 	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{Timeout: 10 * time.Second}
+	for _, beaconNodeUrl := range beaconNodeUrls {
+		statusMap, beaconNodeDown, err := getValidatorsStatusFromBeaconNode(ctx, pubkeys, jsonData, beaconNodeUrl, timeout)
+		// the caller is gone, there is no point in trying the next beacon node nor in storing the signatures
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if beaconNodeDown {
+			continue
+		}
+		return statusMap, err
+	}
+
+	// every beacon node is down
+	logger.Error("All beacon nodes are down, keeping signatures to be stored with status unknown")
+	return getMapUnknown(pubkeys), nil
+}
+
+// getValidatorsStatusFromBeaconNode queries a single beacon node. beaconNodeDown is true if the beacon node
+// could not be reached or answered with a server error, so the next beacon node can be tried
+func getValidatorsStatusFromBeaconNode(ctx context.Context, pubkeys []string, jsonData []byte, beaconNodeUrl string, timeout time.Duration) (statusMap map[string]types.Status, beaconNodeDown bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 	apiUrl := fmt.Sprintf("%s/eth/v1/beacon/states/head/validators", beaconNodeUrl)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
 	// Make API call
-	resp, err := client.Post(apiUrl, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		logger.Error("Failed to make request to beacon node: " + err.Error())
-		return getMapUnknown(pubkeys), nil
+		logger.Error("Failed to make request to beacon node " + beaconNodeUrl + ": " + err.Error())
+		return nil, true, nil
 	}
 	defer resp.Body.Close()
 
 	// Check if it's any server error 5xx
 	if resp.StatusCode >= 500 && resp.StatusCode < 600 {
-		logger.Error("internal server error due to server issue, keeping signatures to be stored with status unknown: " + resp.Status)
-		return getMapUnknown(pubkeys), nil
+		logger.Error("internal server error from beacon node " + beaconNodeUrl + ": " + resp.Status)
+		return nil, true, nil
 	}
 
 	// Check the HTTP response status before reading the body and return nil if not ok
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response status from beacon node when retrieving active validators: " + resp.Status)
+		return nil, false, fmt.Errorf("unexpected response status from beacon node when retrieving active validators: " + resp.Status)
 	}
 
 	// Decode the API response directly into the ApiResponse struct
 	var apiResponse activeValidatorsApiResponse
 	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("error decoding response data from beacon node: " + err.Error())
+		return nil, false, fmt.Errorf("error decoding response data from beacon node: " + err.Error())
 	}
 
+	// Use a map to store validator statuses
+	statusMap = make(map[string]types.Status)
 	for _, pubkey := range pubkeys {
 		statusMap[pubkey] = types.Inactive
 	}
@@ -96,7 +123,7 @@ func GetValidatorsStatus(pubkeys []string, beaconNodeUrl string) (map[string]typ
 		statusMap[validator.Validator.Pubkey] = types.Active
 	}
 
-	return statusMap, nil
+	return statusMap, false, nil
 }
 
 func getMapUnknown(pubkeys []string) map[string]types.Status {
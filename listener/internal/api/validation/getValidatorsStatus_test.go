@@ -1,13 +1,15 @@
 package validation
 
 import (
+	"context"
 	"testing"
+	"time"
 )
 
 func TestGetActiveValidators(t *testing.T) {
 	// Setup the input data
-	beaconNodeUrls := map[string]string{
-		"holesky": "https://holeskyvals.53650f79ab75c6ff.dyndns.dappnode.io",
+	beaconNodeUrls := map[string][]string{
+		"holesky": {"https://holeskyvals.53650f79ab75c6ff.dyndns.dappnode.io"},
 	}
 
 	requestsDecoded := []string{
@@ -16,7 +18,7 @@ func TestGetActiveValidators(t *testing.T) {
 		"0xa24a030d7d8ca3c5e1f5824760d0f4157a7a89bcca6414377cca97e6e63445bef0e1b63761ee35a0fc46bb317e31b34b",
 	}
 
-	validatorsStatusMap, _ := GetValidatorsStatus(requestsDecoded, beaconNodeUrls["holesky"])
+	validatorsStatusMap, _ := GetValidatorsStatus(context.Background(), requestsDecoded, beaconNodeUrls["holesky"], 10*time.Second)
 
 	// You may need to mock the server's response or adjust the expected values here according to your actual setup
 	expectedNumValidators := 3 // This should match the number of mock validators that are "active"
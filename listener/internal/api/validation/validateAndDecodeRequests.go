@@ -1,26 +1,33 @@
 package validation
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
 )
 
 // ValidateAndDecodeRequests filters out Recieved Invalid Reques from the input array. The returned array contains only the valid requests, with the payload decoded.
-func ValidateAndDecodeRequests(requests []types.SignatureRequest) ([]types.SignatureRequestDecoded, error) {
+// Only the tags of the registry allowed in the network, and the proof types of the registry, are accepted.
+func ValidateAndDecodeRequests(requests []types.SignatureRequest, network types.Network, tags types.TagRegistry, proofTypes proofs.Registry) ([]types.SignatureRequestDecoded, error) {
 	var validRequests []types.SignatureRequestDecoded
 	for _, req := range requests {
-		if !isValidCodedRequest(&req) {
+		if !isValidCodedRequest(&req, network, tags) {
 			logger.Debug("Skipping request due to invalid fields or format.")
 			continue
 		}
-		decodedPayload, err := decodeAndValidatePayload(req.Payload)
+		decodedPayload, err := decodeAndValidatePayload(req.Payload, proofTypes)
 		if err != nil {
 			logger.Error("Failed to decode payload: " + err.Error())
 			continue
@@ -32,35 +39,27 @@ func ValidateAndDecodeRequests(requests []types.SignatureRequest) ([]types.Signa
 				Pubkey:    req.Pubkey,
 				Signature: req.Signature,
 				Tag:       req.Tag,
+				Metadata:  req.Metadata,
 			},
 		})
 	}
 	return validRequests, nil
 }
 
-// isValidCodedRequest checks if the request has all the required fields, the correct signature format, and a valid BLS pubkey
-// TODO: validate network and tag against enums
-func isValidCodedRequest(req *types.SignatureRequest) bool {
+// isValidCodedRequest checks if the request has all the required fields, an allowed tag, the correct signature format, and a valid BLS pubkey.
+// The pubkey of req is normalized
+func isValidCodedRequest(req *types.SignatureRequest, network types.Network, tags types.TagRegistry) bool {
 	// Check for any empty required fields
 	if req.Tag == "" || req.Signature == "" || req.Payload == "" || req.Pubkey == "" {
 		logger.Debug("Received Invalid Request: One or more required fields are empty.")
 		return false
 	}
 
-	// Define a map for quick lookup of valid tags.
-	validTags := map[types.Tag]bool{
-		types.Obol:       true,
-		types.Diva:       true,
-		types.Ssv:        true,
-		types.Rocketpool: true,
-		types.Stakewise:  true,
-		types.Stakehouse: true,
-		types.Solo:       true,
-		types.Stader:     true,
-	}
+	// The pubkey is stored normalized, so the same validator in uppercase hex is not a different one
+	req.Pubkey = types.NormalizePubkey(req.Pubkey)
 
-	// If the req.tag is not true, it's invalid
-	if _, ok := validTags[req.Tag]; !ok {
+	// The tag must be in the registry and allowed in the network
+	if !tags.IsAllowed(req.Tag, network) {
 		logger.Debug("Received Invalid Request: Invalid tag.")
 		return false
 	}
@@ -84,25 +83,34 @@ func isValidCodedRequest(req *types.SignatureRequest) bool {
 		return false
 	}
 
+	// Metadata is optional, but must not be abused to store arbitrary data
+	if req.Metadata != nil {
+		if err := validateMetadata(*req.Metadata); err != nil {
+			logger.Debug("Received Invalid Request: " + err.Error())
+			return false
+		}
+	}
+
 	// TODO: verify also signature
 
 	return true
 }
 
-// decodeAndValidatePayload decodes the base64 encoded payload and validates the format. It must be a valid JSON with the correct fields:
+// decodeAndValidatePayload decodes the base64 encoded payload and validates the format. It must be a valid JSON with the correct envelope fields:
 // - Platform: "dappnode"
-// - Type: "PROOF_OF_VALIDATION"
+// - Type: a proof type of the registry, its handler validates the rest of the fields
 // - Timestamp: a valid Unix timestamp within the last 30 days
-func decodeAndValidatePayload(payload string) (types.DecodedPayload, error) {
+// - Version: optional, empty or types.PayloadVersionRaw
+func decodeAndValidatePayload(payload string, proofTypes proofs.Registry) (types.DecodedPayload, error) {
 	// Decode the base64 payload into bytes and unmarshal into DecodedPayload
 	decodedBytes, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
 		return types.DecodedPayload{}, errors.New("invalid base64 encoding")
 	}
 
-	var decodedPayload types.DecodedPayload
-	if err := json.Unmarshal(decodedBytes, &decodedPayload); err != nil {
-		return types.DecodedPayload{}, errors.New("error unmarshalling JSON")
+	decodedPayload, err := parsePayload(decodedBytes)
+	if err != nil {
+		return types.DecodedPayload{}, err
 	}
 
 	// validate platform
@@ -110,9 +118,17 @@ func decodeAndValidatePayload(payload string) (types.DecodedPayload, error) {
 		return types.DecodedPayload{}, errors.New("invalid payload: must be from 'dappnode'")
 	}
 
-	// validate type
-	if decodedPayload.Type != "PROOF_OF_VALIDATION" {
-		return types.DecodedPayload{}, errors.New("invalid type: must be 'PROOF_OF_VALIDATION'")
+	// validate type, it must have a handler in the registry
+	proofHandler, ok := proofTypes[decodedPayload.Type]
+	if !ok {
+		return types.DecodedPayload{}, errors.New("invalid type: " + decodedPayload.Type + " is not an accepted proof type")
+	}
+
+	// validate version, it selects what was signed
+	switch decodedPayload.Version {
+	case "", types.PayloadVersionRaw:
+	default:
+		return types.DecodedPayload{}, errors.New("invalid version: must be '" + types.PayloadVersionRaw + "'")
 	}
 
 	// validate timestamp. Must be a valid Unix timestamp within the last 30 days
@@ -126,5 +142,110 @@ func decodeAndValidatePayload(payload string) (types.DecodedPayload, error) {
 		return types.DecodedPayload{}, errors.New("invalid or old timestamp: must be within the last 30 days and not empty")
 	}
 
+	// validate the fields specific to the proof type
+	if err := proofHandler.Validate(decodedPayload); err != nil {
+		return types.DecodedPayload{}, fmt.Errorf("invalid %s payload: %w", decodedPayload.Type, err)
+	}
+
 	return decodedPayload, nil
 }
+
+// payloadRequiredFields are the envelope fields every payload must have, as JSON strings
+var payloadRequiredFields = []string{"type", "platform", "timestamp"}
+
+// payloadOptionalFields are the envelope fields that may be missing, as JSON strings when present
+var payloadOptionalFields = []string{"version"}
+
+// parsePayload parses the signed payload strictly, since the signature is verified over these exact bytes and the
+// decoded payload must not be ambiguous: it must be a single JSON object without duplicated keys, with every required
+// envelope field present as a string. The rest of the fields are kept raw for the handler of the proof type, unknown
+// fields are allowed so the brain can add fields without breaking older listeners.
+func parsePayload(payloadBytes []byte) (types.DecodedPayload, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payloadBytes))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return types.DecodedPayload{}, errors.New("invalid payload: must be a JSON object")
+	}
+
+	fields := make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return types.DecodedPayload{}, errors.New("invalid payload: malformed JSON")
+		}
+		key := token.(string) // object keys are always strings
+		if _, duplicated := fields[key]; duplicated {
+			return types.DecodedPayload{}, fmt.Errorf("invalid payload: duplicated field %q", key)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return types.DecodedPayload{}, errors.New("invalid payload: malformed JSON")
+		}
+		fields[key] = value
+	}
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return types.DecodedPayload{}, errors.New("invalid payload: malformed JSON")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return types.DecodedPayload{}, errors.New("invalid payload: unexpected data after the JSON object")
+	}
+
+	// metadata is an optional envelope object
+	var metadata types.Metadata
+	if value, ok := fields["metadata"]; ok {
+		delete(fields, "metadata")
+		if len(value) == 0 || value[0] != '{' || json.Unmarshal(value, &metadata) != nil {
+			return types.DecodedPayload{}, errors.New(`invalid payload: field "metadata" must be an object, with the fields ` + strings.Join(types.MetadataFields, ", ") + " as strings")
+		}
+		if err := validateMetadata(metadata); err != nil {
+			return types.DecodedPayload{}, fmt.Errorf("invalid payload: %w", err)
+		}
+	}
+
+	values := make(map[string]string)
+	for _, field := range append(payloadRequiredFields, payloadOptionalFields...) {
+		value, ok := fields[field]
+		delete(fields, field)
+		if !ok {
+			if slices.Contains(payloadOptionalFields, field) {
+				continue
+			}
+			return types.DecodedPayload{}, fmt.Errorf("invalid payload: missing field %q", field)
+		}
+		var str string
+		if len(value) == 0 || value[0] != '"' || json.Unmarshal(value, &str) != nil {
+			return types.DecodedPayload{}, fmt.Errorf("invalid payload: field %q must be a string", field)
+		}
+		values[field] = str
+	}
+
+	return types.DecodedPayload{
+		Type:      values["type"],
+		Platform:  values["platform"],
+		Timestamp: values["timestamp"],
+		Version:   values["version"],
+		Metadata:  metadata,
+		Fields:    fields,
+	}, nil
+}
+
+// maxMetadataFieldLength is the max length of every metadata field, versions and client names are much shorter
+const maxMetadataFieldLength = 128
+
+func validateMetadata(metadata types.Metadata) error {
+	for field, value := range metadata.Fields() {
+		if len(value) > maxMetadataFieldLength {
+			return fmt.Errorf("metadata field %q is longer than %d characters", field, maxMetadataFieldLength)
+		}
+	}
+	return nil
+}
+
+// DecodePayload decodes and parses a payload already stored, without the checks of a new request such as its age
+// or its proof type being accepted
+func DecodePayload(payload string) (types.DecodedPayload, error) {
+	decodedBytes, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return types.DecodedPayload{}, errors.New("invalid base64 encoding")
+	}
+	return parsePayload(decodedBytes)
+}
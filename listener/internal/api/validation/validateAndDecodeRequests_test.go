@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
 )
 
@@ -18,6 +19,9 @@ func repeatString(s string, count int) string {
 	return result
 }
 
+// testProofRegistry accepts only the default proof type, as the listener does by default
+var testProofRegistry, _ = proofs.NewRegistry(proofs.DefaultTypes)
+
 func TestValidateAndDecodeRequests(t *testing.T) {
 	// Setup current time for timestamp tests
 	currentTime := time.Now()
@@ -88,9 +92,164 @@ func TestValidateAndDecodeRequests(t *testing.T) {
 
 	// Run tests. We expect the number of valid requests to match the expected results
 	for i, req := range requests {
-		decodedRequests, _ := ValidateAndDecodeRequests([]types.SignatureRequest{req})
+		decodedRequests, _ := ValidateAndDecodeRequests([]types.SignatureRequest{req}, types.Mainnet, types.NewTagRegistry(types.DefaultTags), testProofRegistry)
 		if len(decodedRequests) != expectedResults[i].expectedLen {
 			t.Errorf("Test %d failed, expected %d valid requests, got %d", i+1, expectedResults[i].expectedLen, len(decodedRequests))
 		}
 	}
 }
+
+func TestValidateAndDecodeRequestsNormalizesPubkey(t *testing.T) {
+	validEncodedPayload := base64.StdEncoding.EncodeToString([]byte(`{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"` + strconv.FormatInt(time.Now().Unix(), 10) + `"}`))
+	normalizedPubkey := "0xa06251962339450df57631d128fa54e4d54e2d17015571f1bcccd9b45c6ea971245f209cc9be087d5440bec19495a99a"
+
+	for _, pubkey := range []string{
+		normalizedPubkey,
+		"0xA06251962339450DF57631D128FA54E4D54E2D17015571F1BCCCD9B45C6EA971245F209CC9BE087D5440BEC19495A99A",
+		"a06251962339450df57631d128fa54e4d54e2d17015571f1bcccd9b45c6ea971245f209cc9be087d5440bec19495a99a",
+	} {
+		request := types.SignatureRequest{Payload: validEncodedPayload, Pubkey: pubkey, Signature: "0x" + repeatString("a", 192), Tag: types.Solo}
+		decodedRequests, _ := ValidateAndDecodeRequests([]types.SignatureRequest{request}, types.Mainnet, types.NewTagRegistry(types.DefaultTags), testProofRegistry)
+		if len(decodedRequests) != 1 || decodedRequests[0].Pubkey != normalizedPubkey {
+			t.Errorf("Expected %s to be accepted as %s, got %+v", pubkey, normalizedPubkey, decodedRequests)
+		}
+	}
+}
+
+func TestValidateAndDecodeRequestsTagRegistry(t *testing.T) {
+	validEncodedPayload := base64.StdEncoding.EncodeToString([]byte(`{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"` + strconv.FormatInt(time.Now().Unix(), 10) + `"}`))
+	newRequest := func(tag types.Tag) types.SignatureRequest {
+		return types.SignatureRequest{
+			Payload:   validEncodedPayload,
+			Pubkey:    "0xa06251962339450df57631d128fa54e4d54e2d17015571f1bcccd9b45c6ea971245f209cc9be087d5440bec19495a99a",
+			Signature: "0x" + repeatString("a", 192),
+			Tag:       tag,
+		}
+	}
+
+	tags := types.NewTagRegistry([]types.TagSpec{
+		{Name: "lido", DisplayName: "Lido", Networks: []types.Network{types.Mainnet, types.Holesky}},
+		{Name: types.Solo},
+	})
+
+	testCases := []struct {
+		description string
+		tag         types.Tag
+		network     types.Network
+		expectedLen int
+	}{
+		{"Configured tag", "lido", types.Mainnet, 1},
+		{"Configured tag without network restriction", types.Solo, types.Gnosis, 1},
+		{"Configured tag not allowed in network", "lido", types.Gnosis, 0},
+		{"Tag not in registry", types.Obol, types.Mainnet, 0},
+	}
+
+	for _, tc := range testCases {
+		decodedRequests, _ := ValidateAndDecodeRequests([]types.SignatureRequest{newRequest(tc.tag)}, tc.network, tags, testProofRegistry)
+		if len(decodedRequests) != tc.expectedLen {
+			t.Errorf("%s: expected %d valid requests, got %d", tc.description, tc.expectedLen, len(decodedRequests))
+		}
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	payload, err := parsePayload([]byte(` {"platform":"dappnode", "timestamp":"1711983600","type":"PROOF_OF_VALIDATION","extra":{"a":1}} `))
+	if err != nil {
+		t.Fatalf("Expected a valid payload, got: %v", err)
+	}
+	if payload.Type != "PROOF_OF_VALIDATION" || payload.Platform != "dappnode" || payload.Timestamp != "1711983600" {
+		t.Errorf("Unexpected decoded payload: %+v", payload)
+	}
+
+	invalidPayloads := map[string]string{
+		"not an object":     `["PROOF_OF_VALIDATION"]`,
+		"duplicated field":  `{"type":"PROOF_OF_VALIDATION","platform":"dappnode","platform":"other","timestamp":"1711983600"}`,
+		"missing field":     `{"type":"PROOF_OF_VALIDATION","platform":"dappnode"}`,
+		"non string field":  `{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":1711983600}`,
+		"null field":        `{"type":"PROOF_OF_VALIDATION","platform":null,"timestamp":"1711983600"}`,
+		"trailing data":     `{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"1711983600"}{}`,
+		"truncated payload": `{"type":"PROOF_OF_VALIDATION","platform":"dappnode"`,
+	}
+	for name, invalidPayload := range invalidPayloads {
+		if _, err := parsePayload([]byte(invalidPayload)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestValidateAndDecodeRequestsProofTypes(t *testing.T) {
+	registry, err := proofs.NewRegistry([]string{proofs.ProofOfValidationType, proofs.ClientVersionType, proofs.MevBoostRelaysType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := proofs.NewRegistry([]string{"UNKNOWN_TYPE"}); err == nil {
+		t.Errorf("Expected an error for an unknown proof type")
+	}
+
+	envelope := `"platform":"dappnode","timestamp":"` + strconv.FormatInt(time.Now().Unix(), 10) + `"`
+	testCases := []struct {
+		name     string
+		payload  string
+		registry proofs.Registry
+		valid    bool
+	}{
+		{"proof of validation", `{"type":"PROOF_OF_VALIDATION",` + envelope + `}`, registry, true},
+		{"client version", `{"type":"CLIENT_VERSION",` + envelope + `,"consensusClient":"lighthouse/v5.1.3","executionClient":"geth/v1.14.0"}`, registry, true},
+		{"client version missing field", `{"type":"CLIENT_VERSION",` + envelope + `,"consensusClient":"lighthouse/v5.1.3"}`, registry, false},
+		{"mev boost relays", `{"type":"MEV_BOOST_RELAYS",` + envelope + `,"relays":["https://relay.example.org"]}`, registry, true},
+		{"mev boost relays not https", `{"type":"MEV_BOOST_RELAYS",` + envelope + `,"relays":["http://relay.example.org"]}`, registry, false},
+		{"type not in the registry", `{"type":"CLIENT_VERSION",` + envelope + `,"consensusClient":"lighthouse/v5.1.3","executionClient":"geth/v1.14.0"}`, testProofRegistry, false},
+	}
+
+	for _, tc := range testCases {
+		req := types.SignatureRequest{
+			Payload:   base64.StdEncoding.EncodeToString([]byte(tc.payload)),
+			Pubkey:    "0xa06251962339450df57631d128fa54e4d54e2d17015571f1bcccd9b45c6ea971245f209cc9be087d5440bec19495a99a",
+			Signature: "0x" + repeatString("a", 192),
+			Tag:       "solo",
+		}
+		decodedRequests, _ := ValidateAndDecodeRequests([]types.SignatureRequest{req}, types.Mainnet, types.NewTagRegistry(types.DefaultTags), tc.registry)
+		if valid := len(decodedRequests) == 1; valid != tc.valid {
+			t.Errorf("%s: expected valid=%t, got %t", tc.name, tc.valid, valid)
+		}
+	}
+}
+
+func TestParsePayloadMetadata(t *testing.T) {
+	payload, err := parsePayload([]byte(`{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"1711983600","metadata":{"brainVersion":"0.1.20","consensusClient":"lighthouse"}}`))
+	if err != nil {
+		t.Fatalf("Expected a valid payload, got: %v", err)
+	}
+	if payload.Metadata.BrainVersion != "0.1.20" || payload.Metadata.ConsensusClient != "lighthouse" {
+		t.Errorf("Unexpected metadata: %+v", payload.Metadata)
+	}
+	if _, ok := payload.Fields["metadata"]; ok {
+		t.Errorf("Expected metadata not to be a proof type field")
+	}
+
+	// the signed metadata wins over the one of the request
+	merged := payload.Metadata.Merge(&types.Metadata{ConsensusClient: "teku", ExecutionClient: "geth"})
+	if merged.ConsensusClient != "lighthouse" || merged.ExecutionClient != "geth" {
+		t.Errorf("Unexpected merged metadata: %+v", merged)
+	}
+
+	// unknown fields, including known ones in another case, are ignored whatever their value
+	payload, err = parsePayload([]byte(`{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"1711983600","metadata":{"brainVersion":"0.1.20","consensusclient":"lighthouse","os":{"name":"linux"}}}`))
+	if err != nil {
+		t.Fatalf("Expected unknown metadata fields to be ignored, got: %v", err)
+	}
+	if payload.Metadata != (types.Metadata{BrainVersion: "0.1.20"}) {
+		t.Errorf("Expected only the known metadata fields, got %+v", payload.Metadata)
+	}
+
+	invalidPayloads := map[string]string{
+		"metadata not an object": `{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"1711983600","metadata":"lighthouse"}`,
+		"non string metadata":    `{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"1711983600","metadata":{"brainVersion":20}}`,
+		"too long metadata":      `{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"1711983600","metadata":{"web3signer":"` + repeatString("a", 200) + `"}}`,
+	}
+	for name, invalidPayload := range invalidPayloads {
+		if _, err := parsePayload([]byte(invalidPayload)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+type validatorsStatusCacheKey struct {
+	network types.Network
+	pubkey  string
+}
+
+type validatorsStatusCacheEntry struct {
+	status    types.Status
+	expiresAt time.Time
+}
+
+// ValidatorsStatusCache keeps the status of the validators returned by the beacon nodes for a while, so repeated
+// submissions from the same validators do not query the beacon node every time. Unknown statuses are not cached,
+// they mean the beacon nodes were down and must be queried again.
+type ValidatorsStatusCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	// beaconNodeTimeout is how long every beacon node has to answer
+	beaconNodeTimeout time.Duration
+	entries           map[validatorsStatusCacheKey]validatorsStatusCacheEntry
+	lastPrune         time.Time
+	// getValidatorsStatus queries the beacon nodes, replaced in tests
+	getValidatorsStatus func(ctx context.Context, pubkeys []string, beaconNodeUrls []string, timeout time.Duration) (map[string]types.Status, error)
+}
+
+// NewValidatorsStatusCache creates a cache that keeps the statuses for ttl. A ttl of 0 disables the cache.
+// beaconNodeTimeout is how long every beacon node has to answer
+func NewValidatorsStatusCache(ttl time.Duration, beaconNodeTimeout time.Duration) *ValidatorsStatusCache {
+	return &ValidatorsStatusCache{
+		ttl:                 ttl,
+		beaconNodeTimeout:   beaconNodeTimeout,
+		entries:             make(map[validatorsStatusCacheKey]validatorsStatusCacheEntry),
+		lastPrune:           time.Now(),
+		getValidatorsStatus: GetValidatorsStatus,
+	}
+}
+
+// GetValidatorsStatus returns the status of the validators, querying the beacon nodes only for the ones that are
+// not cached
+func (c *ValidatorsStatusCache) GetValidatorsStatus(ctx context.Context, network types.Network, pubkeys []string, beaconNodeUrls []string) (map[string]types.Status, error) {
+	if c.ttl <= 0 {
+		return c.getValidatorsStatus(ctx, pubkeys, beaconNodeUrls, c.beaconNodeTimeout)
+	}
+
+	statusMap := make(map[string]types.Status)
+	missing := []string{}
+	now := time.Now()
+
+	c.mu.Lock()
+	for _, pubkey := range pubkeys {
+		entry, ok := c.entries[validatorsStatusCacheKey{network, pubkey}]
+		if ok && now.Before(entry.expiresAt) {
+			statusMap[pubkey] = entry.status
+		} else {
+			missing = append(missing, pubkey)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return statusMap, nil
+	}
+
+	// the lock is not held while querying the beacon nodes, concurrent requests may query the same validators
+	beaconStatusMap, err := c.getValidatorsStatus(ctx, missing, beaconNodeUrls, c.beaconNodeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := time.Now().Add(c.ttl)
+	for pubkey, status := range beaconStatusMap {
+		statusMap[pubkey] = status
+		if status != types.Unknown {
+			c.entries[validatorsStatusCacheKey{network, pubkey}] = validatorsStatusCacheEntry{status: status, expiresAt: expiresAt}
+		}
+	}
+	c.pruneExpired(now)
+
+	return statusMap, nil
+}
+
+// pruneExpired removes the expired entries, at most once per ttl. Must be called with the lock held
+func (c *ValidatorsStatusCache) pruneExpired(now time.Time) {
+	if now.Sub(c.lastPrune) < c.ttl {
+		return
+	}
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastPrune = now
+}
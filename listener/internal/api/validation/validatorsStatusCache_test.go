@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+func TestValidatorsStatusCache(t *testing.T) {
+	cache := NewValidatorsStatusCache(time.Minute, time.Second)
+	var queried [][]string
+	beaconStatus := types.Active
+	cache.getValidatorsStatus = func(ctx context.Context, pubkeys []string, beaconNodeUrls []string, timeout time.Duration) (map[string]types.Status, error) {
+		queried = append(queried, pubkeys)
+		statusMap := make(map[string]types.Status)
+		for _, pubkey := range pubkeys {
+			statusMap[pubkey] = beaconStatus
+		}
+		return statusMap, nil
+	}
+
+	if _, err := cache.GetValidatorsStatus(context.Background(), types.Holesky, []string{"0xa", "0xb"}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// only the validators not cached for the network are queried
+	statusMap, err := cache.GetValidatorsStatus(context.Background(), types.Holesky, []string{"0xa", "0xc"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(queried) != 2 || len(queried[1]) != 1 || queried[1][0] != "0xc" {
+		t.Errorf("Expected only 0xc to be queried, got %v", queried)
+	}
+	if statusMap["0xa"] != types.Active || statusMap["0xc"] != types.Active {
+		t.Errorf("Expected both validators active, got %v", statusMap)
+	}
+
+	if _, err := cache.GetValidatorsStatus(context.Background(), types.Mainnet, []string{"0xa"}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(queried) != 3 {
+		t.Errorf("Expected the validator to be queried again in another network, got %v", queried)
+	}
+
+	// unknown statuses are not cached, the beacon nodes were down
+	beaconStatus = types.Unknown
+	cache.GetValidatorsStatus(context.Background(), types.Holesky, []string{"0xd"}, nil)
+	cache.GetValidatorsStatus(context.Background(), types.Holesky, []string{"0xd"}, nil)
+	if len(queried) != 5 {
+		t.Errorf("Expected unknown statuses not to be cached, got %v", queried)
+	}
+}
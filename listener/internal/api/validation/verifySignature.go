@@ -1,8 +1,9 @@
 package validation
 
 import (
+	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
@@ -40,10 +41,9 @@ func VerifySignature(req types.SignatureRequestDecodedWithStatus) (bool, error)
 		return false, err
 	}
 
-	// Serialize payload to string (assuming it's what was signed)
-	payloadBytes, err := json.Marshal(req.DecodedPayload)
+	payloadBytes, err := getSignedMessage(req.SignatureRequestDecoded)
 	if err != nil {
-		logger.Error("Failed to serialize payload to string: " + err.Error())
+		logger.Error("Failed to get signed message from payload: " + err.Error())
 		return false, err
 	}
 	// Verify the signature
@@ -54,3 +54,19 @@ func VerifySignature(req types.SignatureRequestDecodedWithStatus) (bool, error)
 
 	return true, nil
 }
+
+// getSignedMessage returns the message the validator signed for the request, depending on the payload version: the
+// exact payload bytes. Never a re-serialization of the decoded payload, which would depend on the key order and
+// whitespace of the JSON
+func getSignedMessage(req types.SignatureRequestDecoded) ([]byte, error) {
+	payloadBytes, err := base64.StdEncoding.DecodeString(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	switch req.DecodedPayload.Version {
+	case "", types.PayloadVersionRaw:
+		return payloadBytes, nil
+	default:
+		return nil, fmt.Errorf("unsupported payload version %q", req.DecodedPayload.Version)
+	}
+}
@@ -103,3 +103,40 @@ func TestVerifySignatureError(t *testing.T) {
 		t.Errorf("Expected isValid to be false for invalid data, got true")
 	}
 }
+
+// TestVerifySignatureExactPayload tests the signature is verified over the signed bytes, whatever their key order,
+// whitespace or extra fields
+func TestVerifySignatureExactPayload(t *testing.T) {
+	if err := bls.Init(bls.BLS12_381); err != nil {
+		t.Fatalf("Failed to initialize BLS: %v", err)
+	}
+
+	var secretKey bls.SecretKey
+	secretKey.SetByCSPRNG()
+
+	messageBytes := []byte(`{ "timestamp": "1711983600", "platform": "dappnode", "type": "PROOF_OF_VALIDATION", "extra": 1 }`)
+	decodedPayload, err := parsePayload(messageBytes)
+	if err != nil {
+		t.Fatalf("Failed to parse payload: %v", err)
+	}
+
+	req := types.SignatureRequestDecodedWithStatus{
+		SignatureRequestDecoded: types.SignatureRequestDecoded{
+			DecodedPayload: decodedPayload,
+			SignatureRequest: types.SignatureRequest{
+				Pubkey:    secretKey.GetPublicKey().SerializeToHexStr(),
+				Payload:   base64.StdEncoding.EncodeToString(messageBytes),
+				Signature: secretKey.SignByte(messageBytes).SerializeToHexStr(),
+				Tag:       "solo"},
+		},
+		Status: types.Active,
+	}
+
+	isValid, err := VerifySignature(req)
+	if err != nil {
+		t.Errorf("VerifySignature returned an error: %v", err)
+	}
+	if !isValid {
+		t.Errorf("VerifySignature returned false for a payload not serialized by Go, expected true")
+	}
+}
@@ -0,0 +1,173 @@
+package validation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// minBatchSize is the smallest number of signatures verified together. Smaller batches are not worth
+// running in their own goroutine
+const minBatchSize = 8
+
+type signatureToVerify struct {
+	index   int
+	pubkey  bls.PublicKey
+	sig     bls.Sign
+	message []byte
+}
+
+// VerifySignatures verifies the signatures of a batch of requests and returns, for each request, whether its
+// signature is valid. The batch is split in chunks verified in parallel, one per CPU. Each chunk is verified with a
+// single multi-pairing check, and only if it fails its signatures are verified one by one to find the invalid ones.
+func VerifySignatures(reqs []types.SignatureRequestDecoded) []bool {
+	results := make([]bool, len(reqs))
+
+	items := make([]signatureToVerify, 0, len(reqs))
+	for i, req := range reqs {
+		item, err := decodeSignatureToVerify(req)
+		if err != nil {
+			logger.Debug("Failed to decode signature " + req.Signature + ": " + err.Error())
+			continue
+		}
+		item.index = i
+		items = append(items, item)
+	}
+
+	var wg sync.WaitGroup
+	for _, chunk := range splitInChunks(items, runtime.NumCPU(), minBatchSize) {
+		wg.Add(1)
+		go func(chunk []signatureToVerify) {
+			defer wg.Done()
+			if batchVerify(chunk) {
+				for _, item := range chunk {
+					results[item.index] = true
+				}
+				return
+			}
+			// at least one signature of the chunk is invalid, fall back to verify them one by one
+			for _, item := range chunk {
+				results[item.index] = item.sig.VerifyByte(&item.pubkey, item.message)
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func decodeSignatureToVerify(req types.SignatureRequestDecoded) (signatureToVerify, error) {
+	var item signatureToVerify
+
+	pubkeyBytes, err := hex.DecodeString(strings.TrimSpace(strings.TrimPrefix(req.Pubkey, "0x")))
+	if err != nil {
+		return item, err
+	}
+	if err := item.pubkey.Deserialize(pubkeyBytes); err != nil {
+		return item, err
+	}
+	// the point at infinity would make any batch containing it pass, it is never a valid validator pubkey
+	if item.pubkey.IsZero() {
+		return item, errors.New("public key is the point at infinity")
+	}
+
+	sigBytes, err := hex.DecodeString(strings.TrimSpace(strings.TrimPrefix(req.Signature, "0x")))
+	if err != nil {
+		return item, err
+	}
+	if err := item.sig.Deserialize(sigBytes); err != nil {
+		return item, err
+	}
+
+	item.message, err = getSignedMessage(req)
+	if err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// batchVerify checks all the signatures at once with random linear combination:
+// e(g1, sum(r_i * sig_i)) == prod(e(r_i * pubkey_i, H(message_i)))
+// The random scalars r_i make it infeasible to craft invalid signatures that cancel each other out.
+func batchVerify(items []signatureToVerify) bool {
+	n := len(items)
+	if n == 0 {
+		return true
+	}
+	if n == 1 {
+		return items[0].sig.VerifyByte(&items[0].pubkey, items[0].message)
+	}
+
+	scalars := make([]bls.Fr, n)
+	sigs := make([]bls.G2, n)
+	// index 0 holds the generator side of the equation, the rest one pair per signature
+	g1Points := make([]bls.G1, n+1)
+	g2Points := make([]bls.G2, n+1)
+
+	randomBytes := make([]byte, 8*n)
+	if _, err := rand.Read(randomBytes); err != nil {
+		logger.Error("Failed to generate random scalars for batch verification: " + err.Error())
+		return false
+	}
+
+	for i, item := range items {
+		scalarBytes := randomBytes[8*i : 8*(i+1)]
+		scalarBytes[0] |= 1 // never zero
+		if err := scalars[i].SetLittleEndian(scalarBytes); err != nil {
+			return false
+		}
+		sigs[i] = *bls.CastFromSign(&item.sig)
+		bls.G1Mul(&g1Points[i+1], bls.CastFromPublicKey(&item.pubkey), &scalars[i])
+		messageHash := bls.HashAndMapToSignature(item.message)
+		if messageHash == nil {
+			return false
+		}
+		g2Points[i+1] = *bls.CastFromSign(messageHash)
+	}
+
+	var generator bls.PublicKey
+	bls.GetGeneratorOfPublicKey(&generator)
+	bls.G1Neg(&g1Points[0], bls.CastFromPublicKey(&generator))
+	bls.G2MulVec(&g2Points[0], sigs, scalars)
+
+	// e(-g1, sum(r_i * sig_i)) * prod(e(r_i * pubkey_i, H(message_i))) == 1
+	var e bls.GT
+	bls.MillerLoopVec(&e, g1Points, g2Points)
+	bls.FinalExp(&e, &e)
+	return e.IsOne()
+}
+
+// splitInChunks splits the items in up to maxChunks chunks of similar size, each with at least minChunkSize items
+func splitInChunks(items []signatureToVerify, maxChunks int, minChunkSize int) [][]signatureToVerify {
+	if len(items) == 0 {
+		return nil
+	}
+	numChunks := (len(items) + minChunkSize - 1) / minChunkSize
+	if numChunks > maxChunks {
+		numChunks = maxChunks
+	}
+	if numChunks < 1 {
+		numChunks = 1
+	}
+
+	chunks := make([][]signatureToVerify, 0, numChunks)
+	chunkSize := len(items) / numChunks
+	remainder := len(items) % numChunks
+	start := 0
+	for i := 0; i < numChunks; i++ {
+		end := start + chunkSize
+		if i < remainder {
+			end++
+		}
+		chunks = append(chunks, items[start:end])
+		start = end
+	}
+	return chunks
+}
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// newSignedRequests generates n requests signed by different validators, as the brain would send them
+func newSignedRequests(t testing.TB, n int) []types.SignatureRequestDecoded {
+	if err := bls.Init(bls.BLS12_381); err != nil {
+		t.Fatalf("Failed to initialize BLS: %v", err)
+	}
+	if err := bls.SetETHmode(bls.EthModeDraft07); err != nil {
+		t.Fatalf("Failed to set BLS ETH mode: %v", err)
+	}
+
+	requests := make([]types.SignatureRequestDecoded, n)
+	for i := range requests {
+		var secretKey bls.SecretKey
+		secretKey.SetByCSPRNG()
+
+		decodedPayload := types.DecodedPayload{
+			Type:      "PROOF_OF_VALIDATION",
+			Platform:  "dappnode",
+			Timestamp: fmt.Sprintf("%d", 1711983600000+i),
+		}
+		messageBytes, err := json.Marshal(decodedPayload)
+		if err != nil {
+			t.Fatalf("Failed to marshal payload: %v", err)
+		}
+
+		requests[i] = types.SignatureRequestDecoded{
+			DecodedPayload: decodedPayload,
+			SignatureRequest: types.SignatureRequest{
+				Pubkey:    "0x" + secretKey.GetPublicKey().SerializeToHexStr(),
+				Payload:   base64.StdEncoding.EncodeToString(messageBytes),
+				Signature: "0x" + secretKey.SignByte(messageBytes).SerializeToHexStr(),
+				Tag:       "solo",
+			},
+		}
+	}
+	return requests
+}
+
+func TestVerifySignatures(t *testing.T) {
+	requests := newSignedRequests(t, 50)
+
+	for i, valid := range VerifySignatures(requests) {
+		if !valid {
+			t.Errorf("Signature %d returned invalid, expected valid", i)
+		}
+	}
+
+	// A signature of another validator, a malformed signature and a tampered payload must be detected
+	// without rejecting the rest of the batch
+	invalid := map[int]bool{3: true, 17: true, 42: true}
+	requests[3].Signature = requests[4].Signature
+	requests[17].Signature = "0x1234"
+	requests[42].Payload = requests[43].Payload
+
+	results := VerifySignatures(requests)
+	if len(results) != len(requests) {
+		t.Fatalf("Expected %d results, got %d", len(requests), len(results))
+	}
+	for i, valid := range results {
+		if valid == invalid[i] {
+			t.Errorf("Signature %d returned valid=%t, expected %t", i, valid, !invalid[i])
+		}
+	}
+}
+
+func BenchmarkVerifySignatures(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		requests := newSignedRequests(b, n)
+
+		b.Run(fmt.Sprintf("sequential/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, req := range requests {
+					if valid, _ := VerifySignature(types.SignatureRequestDecodedWithStatus{SignatureRequestDecoded: req}); !valid {
+						b.Fatal("Expected a valid signature")
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("batch/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, valid := range VerifySignatures(requests) {
+					if !valid {
+						b.Fatal("Expected a valid signature")
+					}
+				}
+			}
+		})
+	}
+}
@@ -2,104 +2,413 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 )
 
-// Config is the struct that holds the configuration of the application
+// Config is the struct that holds the configuration of the application. It is loaded from the optional config file
+// (CONFIG_FILE) and environment variables, which take precedence over the file.
 type Config struct {
 	// Port is the port where the server will listen
-	Port string
+	Port string `yaml:"port"`
+	// Database is where the signatures are stored, store.DatabaseMongoDB or store.DatabasePostgres
+	Database string `yaml:"database"`
 	// MongoDBURI is the URI of the MongoDB server. It includes the authentication credentials
-	MongoDBURI string
+	MongoDBURI string `yaml:"mongoDbUri"`
+	// MongoDBDatabase and MongoDBCollection are where the signatures are stored. The migrations and the time-series
+	// entries are stored in other collections of the same database
+	MongoDBDatabase   string `yaml:"mongoDbDatabase"`
+	MongoDBCollection string `yaml:"mongoDbCollection"`
+	// Connection options of MongoDB, see mongodb.ClientConfig
+	MongoDBConnectTimeoutSeconds         int `yaml:"mongoDbConnectTimeoutSeconds"`
+	MongoDBServerSelectionTimeoutSeconds int `yaml:"mongoDbServerSelectionTimeoutSeconds"`
+	MongoDBMinPoolSize                   int `yaml:"mongoDbMinPoolSize"`
+	MongoDBMaxPoolSize                   int `yaml:"mongoDbMaxPoolSize"`
+	// MongoDBReadConcern is a read concern level, empty to keep the default of the server
+	MongoDBReadConcern string `yaml:"mongoDbReadConcern"`
+	// MongoDBWriteConcern is majority or a number of nodes, empty to keep the default of the server
+	MongoDBWriteConcern string `yaml:"mongoDbWriteConcern"`
+	// MongoDBConnectAttempts is how many times the connection is tried at startup, with an exponential backoff from
+	// MongoDBRetryInitialBackoffSeconds up to MongoDBRetryMaxBackoffSeconds between attempts
+	MongoDBConnectAttempts            int `yaml:"mongoDbConnectAttempts"`
+	MongoDBRetryInitialBackoffSeconds int `yaml:"mongoDbRetryInitialBackoffSeconds"`
+	MongoDBRetryMaxBackoffSeconds     int `yaml:"mongoDbRetryMaxBackoffSeconds"`
+	// PostgresURL is the URL of the Postgres database. It includes the authentication credentials
+	PostgresURL string `yaml:"postgresUrl"`
 	// LogLevel is the level of logging
-	LogLevel string
-	// BeaconNodeURLs is the URLs of the beacon nodes for different networks
-	BeaconNodeURLs map[types.Network]string
-	// Max number of entries allowed per BSON document
-	MaxEntriesPerBson int
-	JWTUsersFilePath  string
+	LogLevel string `yaml:"logLevel"`
+	// Networks are the networks the listener serves
+	Networks []NetworkConfig `yaml:"networks"`
+	// BeaconNodeURLs is the URLs of the beacon nodes of every enabled network, in order of preference
+	BeaconNodeURLs map[types.Network][]string `yaml:"-"`
+	// NetworkSpecs is the chain metadata of every enabled network
+	NetworkSpecs map[types.Network]types.NetworkSpec `yaml:"-"`
+	// TagList are the tags accepted by the listener, types.DefaultTags if not set
+	TagList []types.TagSpec `yaml:"tags"`
+	// Tags is the registry built from TagList
+	Tags types.TagRegistry `yaml:"-"`
+	// ProofTypes are the payload types accepted by the listener, proofs.DefaultTypes if not set
+	ProofTypes []string `yaml:"proofTypes"`
+	// ProofRegistry is the registry built from ProofTypes
+	ProofRegistry proofs.Registry `yaml:"-"`
+	// StorageMode is how the signatures are stored in MongoDB, store.ModeEmbedded or store.ModeTimeSeries. Not used by Postgres
+	StorageMode string `yaml:"storageMode"`
+	// Max number of entries allowed per BSON document, only used by the embedded storage mode
+	MaxEntriesPerBson int `yaml:"maxEntriesPerBson"`
+	// SignatureRetentionHours is how long the signatures are kept
+	SignatureRetentionHours int `yaml:"signatureRetentionHours"`
+	// JWTDir is the directory where the users, revocation and submitters files are looked up when their paths are relative
+	JWTDir           string `yaml:"jwtDir"`
+	JWTUsersFilePath string `yaml:"jwtUsersFile"`
+	// JWTRevocationFilePath is the path of the revoked tokens file. Empty means revocation is disabled
+	JWTRevocationFilePath string `yaml:"jwtRevocationFile"`
+	// SubmittersFilePath is the path of the submitters file. Empty means POST /signatures is open to anyone
+	SubmittersFilePath string `yaml:"submittersFile"`
+	// MaxBodyBytes is the max size of a POST /signatures request body
+	MaxBodyBytes int64 `yaml:"maxBodyBytes"`
+	// MaxSignaturesPerRequest is the max number of signatures in a POST /signatures request
+	MaxSignaturesPerRequest int `yaml:"maxSignaturesPerRequest"`
+	// RateLimitRPS is the number of requests per second allowed per client IP, and per submitter. 0 disables rate
+	// limiting
+	RateLimitRPS float64 `yaml:"rateLimitRps"`
+	// RateLimitBurst is the number of requests a client can make at once before being limited
+	RateLimitBurst int `yaml:"rateLimitBurst"`
+	// TrustedProxies are the IPs or CIDRs of the reverse proxies in front of the listener. The IP of the client of a
+	// request from one of them is taken from X-Forwarded-For
+	TrustedProxies []string `yaml:"trustedProxies"`
+	// ValidatorStatusCacheTTLSeconds is how long the status of a validator returned by the beacon node is reused. 0 disables the cache
+	ValidatorStatusCacheTTLSeconds int `yaml:"validatorStatusCacheTtlSeconds"`
+	// BeaconNodeTimeoutSeconds is how long every beacon node has to answer before the next one is tried
+	BeaconNodeTimeoutSeconds int `yaml:"beaconNodeTimeoutSeconds"`
+	// DatabaseTimeoutSeconds is the deadline of every database operation of the API and the crons
+	DatabaseTimeoutSeconds int `yaml:"databaseTimeoutSeconds"`
+	// Timeouts of the HTTP server, see http.Server. The write timeout is also the deadline of the work of a request
+	HTTPReadHeaderTimeoutSeconds int `yaml:"httpReadHeaderTimeoutSeconds"`
+	HTTPReadTimeoutSeconds       int `yaml:"httpReadTimeoutSeconds"`
+	HTTPWriteTimeoutSeconds      int `yaml:"httpWriteTimeoutSeconds"`
+	HTTPIdleTimeoutSeconds       int `yaml:"httpIdleTimeoutSeconds"`
+	// CronLeaseTTLSeconds is how long the lease of the replica that runs the cron jobs lasts without being renewed,
+	// so how long the cron jobs stop when that replica dies
+	CronLeaseTTLSeconds int `yaml:"cronLeaseTtlSeconds"`
+	// Crons are the schedules of the cron jobs
+	Crons CronsConfig `yaml:"crons"`
+	// TLSCertFile and TLSKeyFile enable HTTPS. Both are reloaded from disk when they change
+	TLSCertFile string `yaml:"tlsCertFile"`
+	TLSKeyFile  string `yaml:"tlsKeyFile"`
+	// TLSClientCAFile enables mTLS on the read endpoints. Requires TLS to be enabled
+	TLSClientCAFile string `yaml:"tlsClientCaFile"`
 }
 
-func GetConfig() (*Config, error) {
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logger.Info("LOG_LEVEL is not set, using default INFO")
-		logLevel = "INFO"
+// CronsConfig are the cron jobs of the listener. The retention of removeOldSignatures is SignatureRetentionHours, also
+// used by the time-series storage mode
+type CronsConfig struct {
+	RemoveOldSignatures    CronJobConfig `yaml:"removeOldSignatures"`
+	UpdateSignaturesStatus CronJobConfig `yaml:"updateSignaturesStatus"`
+}
+
+// CronJobConfig is when a cron job runs
+type CronJobConfig struct {
+	// Schedule is a cron expression or a descriptor such as @daily or @every 1m, see
+	// https://pkg.go.dev/github.com/robfig/cron/v3
+	Schedule string `yaml:"schedule"`
+	// Enabled defaults to true. A disabled job can still be run from the admin API
+	Enabled *bool `yaml:"enabled"`
+	// TimeoutSeconds cancels a run taking longer. 0 means no limit
+	TimeoutSeconds int `yaml:"timeoutSeconds"`
+}
+
+// IsEnabled returns whether the job is scheduled. Jobs are enabled unless explicitly disabled
+func (c CronJobConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Timeout returns the max duration of a run, 0 if not limited
+func (c CronJobConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// NetworkConfig is a network served by the listener
+type NetworkConfig struct {
+	Name types.Network `yaml:"name"`
+	// BeaconURLs are tried in order, the next one is used when a beacon node is down
+	BeaconURLs []string `yaml:"beaconUrls"`
+	// Enabled defaults to true. A disabled network is kept in the file but not served
+	Enabled *bool `yaml:"enabled"`
+	// Chain metadata. It is only required for networks unknown to the listener (see types.DefaultNetworkSpecs),
+	// for known networks the values set here override the defaults
+	GenesisTime           uint64 `yaml:"genesisTime"`
+	SecondsPerSlot        uint64 `yaml:"secondsPerSlot"`
+	SlotsPerEpoch         uint64 `yaml:"slotsPerEpoch"`
+	GenesisValidatorsRoot string `yaml:"genesisValidatorsRoot"`
+	GenesisForkVersion    string `yaml:"genesisForkVersion"`
+}
+
+// IsEnabled returns whether the network is served. Networks are enabled unless explicitly disabled
+func (n NetworkConfig) IsEnabled() bool {
+	return n.Enabled == nil || *n.Enabled
+}
+
+// Spec returns the chain metadata of the network, the default one of the registry overridden by the config values
+func (n NetworkConfig) Spec() types.NetworkSpec {
+	spec := types.DefaultNetworkSpecs[n.Name]
+	spec.Name = n.Name
+	if n.GenesisTime != 0 {
+		spec.GenesisTime = n.GenesisTime
+	}
+	if n.SecondsPerSlot != 0 {
+		spec.SecondsPerSlot = n.SecondsPerSlot
 	}
+	if n.SlotsPerEpoch != 0 {
+		spec.SlotsPerEpoch = n.SlotsPerEpoch
+	}
+	if n.GenesisValidatorsRoot != "" {
+		spec.GenesisValidatorsRoot = strings.ToLower(n.GenesisValidatorsRoot)
+	}
+	if n.GenesisForkVersion != "" {
+		spec.GenesisForkVersion = strings.ToLower(n.GenesisForkVersion)
+	}
+	return spec
+}
 
-	apiPort := os.Getenv("API_PORT")
-	if apiPort == "" {
-		logger.Info("API_PORT is not set, using default 8080")
-		apiPort = "8080"
+// defaultConfig returns the config used for every setting not set in the config file nor in the environment
+func defaultConfig() *Config {
+	return &Config{
+		Port:     "8080",
+		LogLevel: "INFO",
+		Database: store.DatabaseMongoDB,
+		// the names used before they were configurable
+		MongoDBDatabase:                      "validatorMonitoring",
+		MongoDBCollection:                    "signatures",
+		MongoDBConnectTimeoutSeconds:         10,
+		MongoDBServerSelectionTimeoutSeconds: 5,
+		// the default of the driver
+		MongoDBMaxPoolSize:                100,
+		MongoDBConnectAttempts:            5,
+		MongoDBRetryInitialBackoffSeconds: 1,
+		MongoDBRetryMaxBackoffSeconds:     30,
+		StorageMode:                       store.ModeEmbedded,
+		// 30 entries per document keeps it far from the 16MB mongo limit
+		MaxEntriesPerBson: 30,
+		// there are 24 * 30 = 720 hours in 30 days, the max age of a payload timestamp
+		SignatureRetentionHours: 720,
+		// we are defaulting to /app/jwt inside the container. This is because docker-compose has a bind mount hardcoded
+		// to that same path (./jwt:/app/jwt). Any changes here should be reflected in docker-compose.yml
+		JWTDir:                  "/app/jwt",
+		TagList:                 types.DefaultTags,
+		ProofTypes:              proofs.DefaultTypes,
+		MaxBodyBytes:            1048576,
+		MaxSignaturesPerRequest: 1000,
+		// rate limiting is off by default, behind a reverse proxy it needs TrustedProxies to tell clients apart
+		RateLimitRPS:   0,
+		RateLimitBurst: 20,
+		// one epoch, validators rarely change status faster than that
+		ValidatorStatusCacheTTLSeconds: 384,
+		BeaconNodeTimeoutSeconds:       10,
+		DatabaseTimeoutSeconds:         10,
+		HTTPReadHeaderTimeoutSeconds:   10,
+		HTTPReadTimeoutSeconds:         30,
+		// a request with the max signatures takes a beacon node call, the BLS verifications and one write per signature
+		HTTPWriteTimeoutSeconds: 60,
+		HTTPIdleTimeoutSeconds:  120,
+		CronLeaseTTLSeconds:     30,
+		Crons: CronsConfig{
+			RemoveOldSignatures:    CronJobConfig{Schedule: "@daily"},
+			UpdateSignaturesStatus: CronJobConfig{Schedule: "@every 1m"},
+		},
 	}
+}
 
-	mongoDBURI := os.Getenv("MONGO_DB_URI")
-	if mongoDBURI == "" {
-		return nil, fmt.Errorf("MONGO_DB_URI is not set")
+// Scope is a group of settings. A command only validates the scopes it uses, so a one-shot command does not need the
+// whole config of serve
+type Scope int
+
+const (
+	// ScopeDatabase is the connection to the database and how the signatures are stored
+	ScopeDatabase Scope = 1 << iota
+	// ScopeNetworks is the enabled networks, their beacon nodes and chain metadata
+	ScopeNetworks
+	// ScopeUsers is the JWT users file and the tags and networks it refers to
+	ScopeUsers
+	// ScopeServe is the rest of the settings of the API and the crons
+	ScopeServe
+
+	// ScopeAll is every setting, the ones serve uses
+	ScopeAll = ScopeDatabase | ScopeNetworks | ScopeUsers | ScopeServe
+)
+
+// GetConfig loads the config and validates the settings of the scopes. Malformed environment variables are reported
+// whatever the scopes
+func GetConfig(scopes Scope) (*Config, error) {
+	config := defaultConfig()
+
+	configFilePath := os.Getenv("CONFIG_FILE")
+	if configFilePath == "" {
+		logger.Info("CONFIG_FILE is not set, loading config from environment variables only")
+	} else if err := loadConfigFile(configFilePath, config); err != nil {
+		return nil, err
 	}
 
-	// beacon node urls per network
-	beaconMainnet := os.Getenv("BEACON_NODE_URL_MAINNET")
-	if beaconMainnet == "" {
-		return nil, fmt.Errorf("BEACON_NODE_URL_MAINNET is not set")
+	// env and validation errors are reported together, so every problem can be fixed at once
+	errs := applyEnvOverrides(config)
+	errs = append(errs, validateConfig(config, scopes)...)
+	if len(errs) > 0 {
+		return nil, formatConfigErrors(errs)
 	}
-	beaconHolesky := os.Getenv("BEACON_NODE_URL_HOLESKY")
-	if beaconHolesky == "" {
-		logger.Fatal("BEACON_NODE_URL_HOLESKY is not set")
+
+	config.BeaconNodeURLs = make(map[types.Network][]string)
+	config.NetworkSpecs = make(map[types.Network]types.NetworkSpec)
+	for _, network := range config.Networks {
+		if network.IsEnabled() {
+			config.BeaconNodeURLs[network.Name] = network.BeaconURLs
+			config.NetworkSpecs[network.Name] = network.Spec()
+		}
 	}
-	beaconGnosis := os.Getenv("BEACON_NODE_URL_GNOSIS")
-	if beaconGnosis == "" {
-		return nil, fmt.Errorf("BEACON_NODE_URL_GNOSIS is not set")
+
+	config.Tags = types.NewTagRegistry(config.TagList)
+	// proof types are validated in the serve scope, the only one that uses them
+	config.ProofRegistry, _ = proofs.NewRegistry(config.ProofTypes)
+
+	config.JWTUsersFilePath = resolveJWTPath(config.JWTDir, config.JWTUsersFilePath)
+	config.JWTRevocationFilePath = resolveJWTPath(config.JWTDir, config.JWTRevocationFilePath)
+	config.SubmittersFilePath = resolveJWTPath(config.JWTDir, config.SubmittersFilePath)
+
+	logConfig(config)
+
+	return config, nil
+}
+
+// KnownNetworks returns the networks defined in the config, disabled ones included
+func (c *Config) KnownNetworks() map[types.Network]bool {
+	networks := make(map[types.Network]bool)
+	for _, network := range c.Networks {
+		networks[network.Name] = true
 	}
-	beaconLukso := os.Getenv("BEACON_NODE_URL_LUKSO")
-	if beaconLukso == "" {
-		return nil, fmt.Errorf("BEACON_NODE_URL_LUKSO is not set")
+	return networks
+}
+
+// MongoDBClientConfig returns the connection options of MongoDB
+func (c *Config) MongoDBClientConfig() mongodb.ClientConfig {
+	return mongodb.ClientConfig{
+		URI:                    c.MongoDBURI,
+		ConnectTimeout:         time.Duration(c.MongoDBConnectTimeoutSeconds) * time.Second,
+		ServerSelectionTimeout: time.Duration(c.MongoDBServerSelectionTimeoutSeconds) * time.Second,
+		MinPoolSize:            uint64(c.MongoDBMinPoolSize),
+		MaxPoolSize:            uint64(c.MongoDBMaxPoolSize),
+		ReadConcern:            c.MongoDBReadConcern,
+		WriteConcern:           c.MongoDBWriteConcern,
+		ConnectAttempts:        c.MongoDBConnectAttempts,
+		InitialBackoff:         time.Duration(c.MongoDBRetryInitialBackoffSeconds) * time.Second,
+		MaxBackoff:             time.Duration(c.MongoDBRetryMaxBackoffSeconds) * time.Second,
+	}
+}
+
+// BeaconNodeTimeout is how long every beacon node has to answer
+func (c *Config) BeaconNodeTimeout() time.Duration {
+	return time.Duration(c.BeaconNodeTimeoutSeconds) * time.Second
+}
+
+// TrustedProxyPrefixes returns the trusted proxies as prefixes, a single IP is a prefix of its full length
+func (c *Config) TrustedProxyPrefixes() []netip.Prefix {
+	prefixes := []netip.Prefix{}
+	for _, proxy := range c.TrustedProxies {
+		// trusted proxies are already validated
+		prefix, _ := parseTrustedProxy(proxy)
+		prefixes = append(prefixes, prefix)
 	}
-	maxEntriesPerBsonStr := os.Getenv("MAX_ENTRIES_PER_BSON")
-	if maxEntriesPerBsonStr == "" {
-		logger.Info("MAX_ENTRIES_PER_BSON is not set, using default 30")
-		maxEntriesPerBsonStr = "30"
+	return prefixes
+}
+
+func parseTrustedProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		prefix, err := netip.ParsePrefix(proxy)
+		return prefix.Masked(), err
 	}
-	MaxEntriesPerBson, err := strconv.Atoi(maxEntriesPerBsonStr)
+	addr, err := netip.ParseAddr(proxy)
 	if err != nil {
-		return nil, fmt.Errorf("MAX_ENTRIES_PER_BSON is not a valid integer")
+		return netip.Prefix{}, err
 	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
 
-	jwtUsersFileName := os.Getenv("JWT_USERS_FILE")
-	if jwtUsersFileName == "" {
-		return nil, fmt.Errorf("JWT_USERS_FILE is not set")
+// resolveJWTPath returns the path relative to the jwt directory. Absolute paths and empty values are returned as is
+func resolveJWTPath(jwtDir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
 	}
-	// we are hardcoding /app/jwt inside the container. This is because docker-compose has a bind mount hardcoded
-	// to that same path (./jwt:/app/jwt). Any changes here should be reflected in docker-compose.yml
-	jwtUsersFilePath := "/app/jwt/" + jwtUsersFileName
+	return filepath.Join(jwtDir, path)
+}
 
-	logger.Info("LOG_LEVEL: " + logLevel)
-	logger.Info("API_PORT: " + apiPort)
-	logger.Info("MONGO_DB_URI: " + mongoDBURI)
-	logger.Info("BEACON_NODE_URL_MAINNET: " + beaconMainnet)
-	logger.Info("BEACON_NODE_URL_HOLESKY: " + beaconHolesky)
-	logger.Info("BEACON_NODE_URL_GNOSIS: " + beaconGnosis)
-	logger.Info("BEACON_NODE_URL_LUKSO: " + beaconLukso)
-	logger.Info("MAX_ENTRIES_PER_BSON: " + maxEntriesPerBsonStr)
-	logger.Info("JWT_USERS_FILE_PATH: " + jwtUsersFilePath)
+// formatConfigErrors joins all the config errors in a single readable error
+func formatConfigErrors(errs []string) error {
+	return fmt.Errorf("invalid config:\n  - %s", strings.Join(errs, "\n  - "))
+}
 
-	beaconNodeURLs := map[types.Network]string{
-		types.Mainnet: beaconMainnet,
-		types.Holesky: beaconHolesky,
-		types.Gnosis:  beaconGnosis,
-		types.Lukso:   beaconLukso,
+// redactURL hides the password of a URL so it can be logged. A URL that can not be parsed is not logged at all, as it
+// may still contain one
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
 	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return parsedURL.Redacted()
+}
 
-	return &Config{
-		Port:              apiPort,
-		MongoDBURI:        mongoDBURI,
-		LogLevel:          logLevel,
-		BeaconNodeURLs:    beaconNodeURLs,
-		MaxEntriesPerBson: MaxEntriesPerBson,
-		JWTUsersFilePath:  jwtUsersFilePath,
-	}, nil
+func logConfig(config *Config) {
+	logger.Info("LOG_LEVEL: " + config.LogLevel)
+	logger.Info("API_PORT: " + config.Port)
+	logger.Info("DATABASE: " + config.Database)
+	logger.Info("MONGO_DB_URI: " + config.MongoDBURI)
+	logger.Info(fmt.Sprintf("MONGO_DB_DATABASE: %s, MONGO_DB_COLLECTION: %s", config.MongoDBDatabase, config.MongoDBCollection))
+	logger.Info(fmt.Sprintf("MONGO_DB_CONNECT_TIMEOUT_SECONDS: %d, MONGO_DB_SERVER_SELECTION_TIMEOUT_SECONDS: %d", config.MongoDBConnectTimeoutSeconds, config.MongoDBServerSelectionTimeoutSeconds))
+	logger.Info(fmt.Sprintf("MONGO_DB_MIN_POOL_SIZE: %d, MONGO_DB_MAX_POOL_SIZE: %d", config.MongoDBMinPoolSize, config.MongoDBMaxPoolSize))
+	logger.Info(fmt.Sprintf("MONGO_DB_READ_CONCERN: %s, MONGO_DB_WRITE_CONCERN: %s", config.MongoDBReadConcern, config.MongoDBWriteConcern))
+	logger.Info(fmt.Sprintf("MONGO_DB_CONNECT_ATTEMPTS: %d, MONGO_DB_RETRY_INITIAL_BACKOFF_SECONDS: %d, MONGO_DB_RETRY_MAX_BACKOFF_SECONDS: %d", config.MongoDBConnectAttempts, config.MongoDBRetryInitialBackoffSeconds, config.MongoDBRetryMaxBackoffSeconds))
+	logger.Info("POSTGRES_URL: " + redactURL(config.PostgresURL))
+	for _, network := range config.Networks {
+		if !network.IsEnabled() {
+			logger.Info(fmt.Sprintf("Network %s: disabled", network.Name))
+			continue
+		}
+		spec := network.Spec()
+		logger.Info(fmt.Sprintf("Network %s: beacon node URLs %s, genesis validators root %s", network.Name, strings.Join(network.BeaconURLs, ", "), spec.GenesisValidatorsRoot))
+	}
+	tagNames := make([]string, len(config.TagList))
+	for i, tag := range config.TagList {
+		tagNames[i] = string(tag.Name)
+	}
+	logger.Info("TAGS: " + strings.Join(tagNames, ", "))
+	logger.Info("PROOF_TYPES: " + strings.Join(config.ProofTypes, ", "))
+	logger.Info("STORAGE_MODE: " + config.StorageMode)
+	logger.Info(fmt.Sprintf("MAX_ENTRIES_PER_BSON: %d", config.MaxEntriesPerBson))
+	logger.Info(fmt.Sprintf("SIGNATURE_RETENTION_HOURS: %d", config.SignatureRetentionHours))
+	logger.Info("JWT_USERS_FILE_PATH: " + config.JWTUsersFilePath)
+	logger.Info("JWT_REVOCATION_FILE_PATH: " + config.JWTRevocationFilePath)
+	logger.Info("SUBMITTERS_FILE_PATH: " + config.SubmittersFilePath)
+	logger.Info(fmt.Sprintf("MAX_BODY_BYTES: %d", config.MaxBodyBytes))
+	logger.Info(fmt.Sprintf("MAX_SIGNATURES_PER_REQUEST: %d", config.MaxSignaturesPerRequest))
+	logger.Info(fmt.Sprintf("RATE_LIMIT_RPS: %g", config.RateLimitRPS))
+	logger.Info(fmt.Sprintf("RATE_LIMIT_BURST: %d", config.RateLimitBurst))
+	logger.Info("TRUSTED_PROXIES: " + strings.Join(config.TrustedProxies, ", "))
+	logger.Info(fmt.Sprintf("VALIDATOR_STATUS_CACHE_TTL_SECONDS: %d", config.ValidatorStatusCacheTTLSeconds))
+	logger.Info(fmt.Sprintf("BEACON_NODE_TIMEOUT_SECONDS: %d, DATABASE_TIMEOUT_SECONDS: %d", config.BeaconNodeTimeoutSeconds, config.DatabaseTimeoutSeconds))
+	logger.Info(fmt.Sprintf("CRON_LEASE_TTL_SECONDS: %d", config.CronLeaseTTLSeconds))
+	logger.Info(fmt.Sprintf("CRON_REMOVE_OLD_SIGNATURES: schedule %s, enabled %t, timeout %ds", config.Crons.RemoveOldSignatures.Schedule, config.Crons.RemoveOldSignatures.IsEnabled(), config.Crons.RemoveOldSignatures.TimeoutSeconds))
+	logger.Info(fmt.Sprintf("CRON_UPDATE_SIGNATURES_STATUS: schedule %s, enabled %t, timeout %ds", config.Crons.UpdateSignaturesStatus.Schedule, config.Crons.UpdateSignaturesStatus.IsEnabled(), config.Crons.UpdateSignaturesStatus.TimeoutSeconds))
+	logger.Info(fmt.Sprintf("HTTP_READ_HEADER_TIMEOUT_SECONDS: %d, HTTP_READ_TIMEOUT_SECONDS: %d, HTTP_WRITE_TIMEOUT_SECONDS: %d, HTTP_IDLE_TIMEOUT_SECONDS: %d", config.HTTPReadHeaderTimeoutSeconds, config.HTTPReadTimeoutSeconds, config.HTTPWriteTimeoutSeconds, config.HTTPIdleTimeoutSeconds))
+	logger.Info("TLS_CERT_FILE: " + config.TLSCertFile)
+	logger.Info("TLS_KEY_FILE: " + config.TLSKeyFile)
+	logger.Info("TLS_CLIENT_CA_FILE: " + config.TLSClientCAFile)
 }
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile reads the YAML config file on top of the defaults. Unknown keys are rejected so typos
+// are not silently ignored
+func loadConfigFile(filePath string, config *Config) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", filePath, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// beaconNodeUrlEnvPrefix is the prefix of the env vars with the beacon node URLs of a network, e.g. BEACON_NODE_URL_MAINNET.
+// The value is a comma separated list of URLs
+const beaconNodeUrlEnvPrefix = "BEACON_NODE_URL_"
+
+// applyEnvOverrides overrides the config with the environment variables that are set. Empty variables are
+// considered not set, since docker compose passes every variable of the service even if it is empty
+func applyEnvOverrides(config *Config) []string {
+	var errs []string
+
+	overrideString(&config.LogLevel, "LOG_LEVEL")
+	overrideString(&config.Port, "API_PORT")
+	overrideString(&config.Database, "DATABASE")
+	overrideString(&config.MongoDBURI, "MONGO_DB_URI")
+	overrideString(&config.MongoDBDatabase, "MONGO_DB_DATABASE")
+	overrideString(&config.MongoDBCollection, "MONGO_DB_COLLECTION")
+	overrideString(&config.MongoDBReadConcern, "MONGO_DB_READ_CONCERN")
+	overrideString(&config.MongoDBWriteConcern, "MONGO_DB_WRITE_CONCERN")
+	overrideString(&config.PostgresURL, "POSTGRES_URL")
+	overrideString(&config.StorageMode, "STORAGE_MODE")
+	overrideString(&config.JWTDir, "JWT_DIR")
+	overrideString(&config.JWTUsersFilePath, "JWT_USERS_FILE")
+	overrideString(&config.JWTRevocationFilePath, "JWT_REVOCATION_FILE")
+	overrideString(&config.SubmittersFilePath, "SUBMITTERS_FILE")
+	overrideString(&config.TLSCertFile, "TLS_CERT_FILE")
+	overrideString(&config.TLSKeyFile, "TLS_KEY_FILE")
+	overrideString(&config.TLSClientCAFile, "TLS_CLIENT_CA_FILE")
+	overrideInt(&config.MongoDBConnectTimeoutSeconds, "MONGO_DB_CONNECT_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.MongoDBServerSelectionTimeoutSeconds, "MONGO_DB_SERVER_SELECTION_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.MongoDBMinPoolSize, "MONGO_DB_MIN_POOL_SIZE", &errs)
+	overrideInt(&config.MongoDBMaxPoolSize, "MONGO_DB_MAX_POOL_SIZE", &errs)
+	overrideInt(&config.MongoDBConnectAttempts, "MONGO_DB_CONNECT_ATTEMPTS", &errs)
+	overrideInt(&config.MongoDBRetryInitialBackoffSeconds, "MONGO_DB_RETRY_INITIAL_BACKOFF_SECONDS", &errs)
+	overrideInt(&config.MongoDBRetryMaxBackoffSeconds, "MONGO_DB_RETRY_MAX_BACKOFF_SECONDS", &errs)
+	overrideInt(&config.MaxEntriesPerBson, "MAX_ENTRIES_PER_BSON", &errs)
+	overrideInt(&config.SignatureRetentionHours, "SIGNATURE_RETENTION_HOURS", &errs)
+	overrideInt64(&config.MaxBodyBytes, "MAX_BODY_BYTES", &errs)
+	overrideInt(&config.MaxSignaturesPerRequest, "MAX_SIGNATURES_PER_REQUEST", &errs)
+	overrideFloat(&config.RateLimitRPS, "RATE_LIMIT_RPS", &errs)
+	overrideInt(&config.RateLimitBurst, "RATE_LIMIT_BURST", &errs)
+	overrideInt(&config.ValidatorStatusCacheTTLSeconds, "VALIDATOR_STATUS_CACHE_TTL_SECONDS", &errs)
+	overrideInt(&config.BeaconNodeTimeoutSeconds, "BEACON_NODE_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.DatabaseTimeoutSeconds, "DATABASE_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.HTTPReadHeaderTimeoutSeconds, "HTTP_READ_HEADER_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.HTTPReadTimeoutSeconds, "HTTP_READ_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.HTTPWriteTimeoutSeconds, "HTTP_WRITE_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.HTTPIdleTimeoutSeconds, "HTTP_IDLE_TIMEOUT_SECONDS", &errs)
+	overrideInt(&config.CronLeaseTTLSeconds, "CRON_LEASE_TTL_SECONDS", &errs)
+	overrideCronJob(&config.Crons.RemoveOldSignatures, "CRON_REMOVE_OLD_SIGNATURES", &errs)
+	overrideCronJob(&config.Crons.UpdateSignaturesStatus, "CRON_UPDATE_SIGNATURES_STATUS", &errs)
+
+	// ALLOWED_TAGS replaces the tags with a comma separated list of names, keeping the metadata of the tags
+	// that were already defined
+	if value := os.Getenv("ALLOWED_TAGS"); value != "" {
+		existingTags := types.NewTagRegistry(config.TagList)
+		config.TagList = []types.TagSpec{}
+		for _, name := range splitList(value) {
+			tag, ok := existingTags[types.Tag(name)]
+			if !ok {
+				tag = types.TagSpec{Name: types.Tag(name), DisplayName: name}
+			}
+			config.TagList = append(config.TagList, tag)
+		}
+	}
+
+	// TRUSTED_PROXIES replaces the trusted proxies with a comma separated list of IPs or CIDRs
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		config.TrustedProxies = splitList(value)
+	}
+
+	// PROOF_TYPES replaces the accepted proof types with a comma separated list
+	if value := os.Getenv("PROOF_TYPES"); value != "" {
+		config.ProofTypes = splitList(value)
+	}
+
+	// BEACON_NODE_URL_<NETWORK> overrides the URLs of the network, adding it if it is not in the config file
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, beaconNodeUrlEnvPrefix) || value == "" {
+			continue
+		}
+		network := types.Network(strings.ToLower(strings.TrimPrefix(name, beaconNodeUrlEnvPrefix)))
+		urls := splitList(value)
+
+		found := false
+		for i := range config.Networks {
+			if config.Networks[i].Name == network {
+				config.Networks[i].BeaconURLs = urls
+				found = true
+			}
+		}
+		if !found {
+			config.Networks = append(config.Networks, NetworkConfig{Name: network, BeaconURLs: urls})
+		}
+	}
+
+	return errs
+}
+
+// overrideCronJob overrides a cron job with the env vars <prefix>_SCHEDULE, <prefix>_ENABLED and <prefix>_TIMEOUT_SECONDS
+func overrideCronJob(job *CronJobConfig, prefix string, errs *[]string) {
+	overrideString(&job.Schedule, prefix+"_SCHEDULE")
+	overrideInt(&job.TimeoutSeconds, prefix+"_TIMEOUT_SECONDS", errs)
+	if value := os.Getenv(prefix + "_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s_ENABLED must be true or false, got %q", prefix, value))
+			return
+		}
+		job.Enabled = &enabled
+	}
+}
+
+func overrideString(target *string, envName string) {
+	if value := os.Getenv(envName); value != "" {
+		*target = value
+	}
+}
+
+func overrideInt(target *int, envName string, errs *[]string) {
+	value := os.Getenv(envName)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s is not a valid integer: %q", envName, value))
+		return
+	}
+	*target = parsed
+}
+
+func overrideInt64(target *int64, envName string, errs *[]string) {
+	value := os.Getenv(envName)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s is not a valid integer: %q", envName, value))
+		return
+	}
+	*target = parsed
+}
+
+func overrideFloat(target *float64, envName string, errs *[]string) {
+	value := os.Getenv(envName)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s is not a valid number: %q", envName, value))
+		return
+	}
+	*target = parsed
+}
+
+// splitList splits a comma separated list, trimming spaces and dropping empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
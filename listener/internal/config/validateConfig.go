@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/proofs"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+	"github.com/dappnode/validator-monitoring/listener/internal/migrations"
+	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"github.com/robfig/cron/v3"
+)
+
+// validateConfig checks the settings of the scopes and returns all the problems found, not only the first one. The log
+// level is checked for every scope
+func validateConfig(config *Config, scopes Scope) []string {
+	var errs []string
+
+	switch config.LogLevel {
+	case "DEBUG", "INFO", "WARN", "ERROR", "FATAL":
+	default:
+		errs = append(errs, fmt.Sprintf("logLevel (LOG_LEVEL) must be one of DEBUG, INFO, WARN, ERROR, FATAL, got %q", config.LogLevel))
+	}
+
+	if scopes&ScopeDatabase != 0 {
+		errs = append(errs, validateDatabase(config)...)
+	}
+	if scopes&ScopeNetworks != 0 {
+		errs = append(errs, validateNetworks(config.Networks)...)
+		if config.BeaconNodeTimeoutSeconds <= 0 {
+			errs = append(errs, fmt.Sprintf("beaconNodeTimeoutSeconds (BEACON_NODE_TIMEOUT_SECONDS) must be a positive integer, got %d", config.BeaconNodeTimeoutSeconds))
+		}
+	}
+	if scopes&ScopeUsers != 0 {
+		errs = append(errs, validateTags(config.TagList, config.Networks)...)
+		if config.JWTUsersFilePath == "" {
+			errs = append(errs, "jwtUsersFile (JWT_USERS_FILE) is not set")
+		}
+	}
+	if scopes&ScopeServe != 0 {
+		errs = append(errs, validateServe(config)...)
+	}
+	return errs
+}
+
+// validateDatabase checks the connection to the database and how the signatures are stored
+func validateDatabase(config *Config) []string {
+	var errs []string
+	switch config.Database {
+	case store.DatabaseMongoDB:
+		if config.MongoDBURI == "" {
+			errs = append(errs, "mongoDbUri (MONGO_DB_URI) is not set")
+		} else if !strings.HasPrefix(config.MongoDBURI, "mongodb://") && !strings.HasPrefix(config.MongoDBURI, "mongodb+srv://") {
+			errs = append(errs, "mongoDbUri (MONGO_DB_URI) must start with mongodb:// or mongodb+srv://")
+		}
+		errs = append(errs, validateMongoDB(config)...)
+	case store.DatabasePostgres:
+		if config.PostgresURL == "" {
+			errs = append(errs, "postgresUrl (POSTGRES_URL) is not set")
+		} else if !strings.HasPrefix(config.PostgresURL, "postgres://") && !strings.HasPrefix(config.PostgresURL, "postgresql://") {
+			errs = append(errs, "postgresUrl (POSTGRES_URL) must start with postgres:// or postgresql://")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("database (DATABASE) must be %s or %s, got %q", store.DatabaseMongoDB, store.DatabasePostgres, config.Database))
+	}
+
+	switch config.StorageMode {
+	case store.ModeEmbedded, store.ModeTimeSeries:
+	default:
+		errs = append(errs, fmt.Sprintf("storageMode (STORAGE_MODE) must be %s or %s, got %q", store.ModeEmbedded, store.ModeTimeSeries, config.StorageMode))
+	}
+
+	if config.MaxEntriesPerBson <= 0 {
+		errs = append(errs, fmt.Sprintf("maxEntriesPerBson (MAX_ENTRIES_PER_BSON) must be a positive integer, got %d", config.MaxEntriesPerBson))
+	}
+
+	if config.SignatureRetentionHours <= 0 {
+		errs = append(errs, fmt.Sprintf("signatureRetentionHours (SIGNATURE_RETENTION_HOURS) must be a positive integer, got %d", config.SignatureRetentionHours))
+	}
+
+	if config.DatabaseTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Sprintf("databaseTimeoutSeconds (DATABASE_TIMEOUT_SECONDS) must be a positive integer, got %d", config.DatabaseTimeoutSeconds))
+	}
+	return errs
+}
+
+// validateServe checks the settings of the API and the crons
+func validateServe(config *Config) []string {
+	var errs []string
+	if port, err := strconv.Atoi(config.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Sprintf("port (API_PORT) must be a number between 1 and 65535, got %q", config.Port))
+	}
+
+	if len(config.ProofTypes) == 0 {
+		errs = append(errs, "no proof types are accepted, set proofTypes in the config file or PROOF_TYPES")
+	} else if _, err := proofs.NewRegistry(config.ProofTypes); err != nil {
+		errs = append(errs, fmt.Sprintf("proofTypes (PROOF_TYPES): %v", err))
+	}
+
+	if config.MaxBodyBytes <= 0 {
+		errs = append(errs, fmt.Sprintf("maxBodyBytes (MAX_BODY_BYTES) must be a positive integer, got %d", config.MaxBodyBytes))
+	}
+	if config.MaxSignaturesPerRequest <= 0 {
+		errs = append(errs, fmt.Sprintf("maxSignaturesPerRequest (MAX_SIGNATURES_PER_REQUEST) must be a positive integer, got %d", config.MaxSignaturesPerRequest))
+	}
+	if config.RateLimitRPS < 0 {
+		errs = append(errs, fmt.Sprintf("rateLimitRps (RATE_LIMIT_RPS) must not be negative, got %g", config.RateLimitRPS))
+	}
+	if config.RateLimitRPS > 0 && config.RateLimitBurst <= 0 {
+		errs = append(errs, fmt.Sprintf("rateLimitBurst (RATE_LIMIT_BURST) must be a positive integer, got %d", config.RateLimitBurst))
+	}
+	for _, proxy := range config.TrustedProxies {
+		if _, err := parseTrustedProxy(proxy); err != nil {
+			errs = append(errs, fmt.Sprintf("trustedProxies (TRUSTED_PROXIES) must be IPs or CIDRs, got %q", proxy))
+		}
+	}
+	if config.ValidatorStatusCacheTTLSeconds < 0 {
+		errs = append(errs, fmt.Sprintf("validatorStatusCacheTtlSeconds (VALIDATOR_STATUS_CACHE_TTL_SECONDS) must not be negative, got %d", config.ValidatorStatusCacheTTLSeconds))
+	}
+	for _, timeout := range []struct {
+		name  string
+		value int
+	}{
+		{"httpReadHeaderTimeoutSeconds (HTTP_READ_HEADER_TIMEOUT_SECONDS)", config.HTTPReadHeaderTimeoutSeconds},
+		{"httpReadTimeoutSeconds (HTTP_READ_TIMEOUT_SECONDS)", config.HTTPReadTimeoutSeconds},
+		{"httpWriteTimeoutSeconds (HTTP_WRITE_TIMEOUT_SECONDS)", config.HTTPWriteTimeoutSeconds},
+		{"httpIdleTimeoutSeconds (HTTP_IDLE_TIMEOUT_SECONDS)", config.HTTPIdleTimeoutSeconds},
+		{"cronLeaseTtlSeconds (CRON_LEASE_TTL_SECONDS)", config.CronLeaseTTLSeconds},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Sprintf("%s must be a positive integer, got %d", timeout.name, timeout.value))
+		}
+	}
+
+	errs = append(errs, validateCronJob("removeOldSignatures", config.Crons.RemoveOldSignatures)...)
+	errs = append(errs, validateCronJob("updateSignaturesStatus", config.Crons.UpdateSignaturesStatus)...)
+
+	// TLS is optional, it can also be terminated by a reverse proxy in front of the listener
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		errs = append(errs, "tlsCertFile (TLS_CERT_FILE) and tlsKeyFile (TLS_KEY_FILE) must be set together")
+	}
+	if config.TLSClientCAFile != "" && config.TLSCertFile == "" {
+		errs = append(errs, "tlsClientCaFile (TLS_CLIENT_CA_FILE) requires tlsCertFile (TLS_CERT_FILE) and tlsKeyFile (TLS_KEY_FILE) to be set")
+	}
+
+	return errs
+}
+
+// validateCronJob checks the schedule even if the job is disabled, so enabling it later does not break the startup
+func validateCronJob(name string, job CronJobConfig) []string {
+	var errs []string
+	if _, err := cron.ParseStandard(job.Schedule); err != nil {
+		errs = append(errs, fmt.Sprintf("crons.%s.schedule is not a valid cron schedule %q: %v", name, job.Schedule, err))
+	}
+	if job.TimeoutSeconds < 0 {
+		errs = append(errs, fmt.Sprintf("crons.%s.timeoutSeconds must not be negative, got %d", name, job.TimeoutSeconds))
+	}
+	return errs
+}
+
+// mongoDBReadConcerns are the read concern levels of MongoDB
+var mongoDBReadConcerns = []string{"local", "available", "majority", "linearizable", "snapshot"}
+
+func validateMongoDB(config *Config) []string {
+	var errs []string
+	if config.MongoDBDatabase == "" {
+		errs = append(errs, "mongoDbDatabase (MONGO_DB_DATABASE) is not set")
+	}
+	switch config.MongoDBCollection {
+	case "":
+		errs = append(errs, "mongoDbCollection (MONGO_DB_COLLECTION) is not set")
+	case migrations.CollectionName, store.EntriesCollectionName, leader.MongoCollectionName, leader.MongoRunsCollectionName:
+		errs = append(errs, fmt.Sprintf("mongoDbCollection (MONGO_DB_COLLECTION) can not be %s, the listener uses it for other data", config.MongoDBCollection))
+	}
+
+	if config.MongoDBConnectTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Sprintf("mongoDbConnectTimeoutSeconds (MONGO_DB_CONNECT_TIMEOUT_SECONDS) must be a positive integer, got %d", config.MongoDBConnectTimeoutSeconds))
+	}
+	if config.MongoDBServerSelectionTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Sprintf("mongoDbServerSelectionTimeoutSeconds (MONGO_DB_SERVER_SELECTION_TIMEOUT_SECONDS) must be a positive integer, got %d", config.MongoDBServerSelectionTimeoutSeconds))
+	}
+	if config.MongoDBMaxPoolSize <= 0 {
+		errs = append(errs, fmt.Sprintf("mongoDbMaxPoolSize (MONGO_DB_MAX_POOL_SIZE) must be a positive integer, got %d", config.MongoDBMaxPoolSize))
+	}
+	if config.MongoDBMinPoolSize < 0 || config.MongoDBMinPoolSize > config.MongoDBMaxPoolSize {
+		errs = append(errs, fmt.Sprintf("mongoDbMinPoolSize (MONGO_DB_MIN_POOL_SIZE) must be between 0 and mongoDbMaxPoolSize, got %d", config.MongoDBMinPoolSize))
+	}
+
+	if config.MongoDBReadConcern != "" && !slices.Contains(mongoDBReadConcerns, config.MongoDBReadConcern) {
+		errs = append(errs, fmt.Sprintf("mongoDbReadConcern (MONGO_DB_READ_CONCERN) must be one of %s, got %q", strings.Join(mongoDBReadConcerns, ", "), config.MongoDBReadConcern))
+	}
+	if config.MongoDBWriteConcern != "" {
+		if _, err := mongodb.ParseWriteConcern(config.MongoDBWriteConcern); err != nil {
+			errs = append(errs, fmt.Sprintf("mongoDbWriteConcern (MONGO_DB_WRITE_CONCERN): %v", err))
+		}
+	}
+
+	if config.MongoDBConnectAttempts <= 0 {
+		errs = append(errs, fmt.Sprintf("mongoDbConnectAttempts (MONGO_DB_CONNECT_ATTEMPTS) must be a positive integer, got %d", config.MongoDBConnectAttempts))
+	}
+	if config.MongoDBRetryInitialBackoffSeconds <= 0 {
+		errs = append(errs, fmt.Sprintf("mongoDbRetryInitialBackoffSeconds (MONGO_DB_RETRY_INITIAL_BACKOFF_SECONDS) must be a positive integer, got %d", config.MongoDBRetryInitialBackoffSeconds))
+	}
+	if config.MongoDBRetryMaxBackoffSeconds < config.MongoDBRetryInitialBackoffSeconds {
+		errs = append(errs, fmt.Sprintf("mongoDbRetryMaxBackoffSeconds (MONGO_DB_RETRY_MAX_BACKOFF_SECONDS) must not be lower than the initial backoff, got %d", config.MongoDBRetryMaxBackoffSeconds))
+	}
+	return errs
+}
+
+func validateNetworks(networks []NetworkConfig) []string {
+	var errs []string
+	seen := make(map[types.Network]bool)
+	enabledCount := 0
+
+	for i, network := range networks {
+		if network.Name == "" {
+			errs = append(errs, fmt.Sprintf("networks[%d] has no name", i))
+			continue
+		}
+		if seen[network.Name] {
+			errs = append(errs, fmt.Sprintf("network %s is defined more than once", network.Name))
+		}
+		seen[network.Name] = true
+
+		if !network.IsEnabled() {
+			continue
+		}
+		enabledCount++
+
+		if len(network.BeaconURLs) == 0 {
+			errs = append(errs, fmt.Sprintf("network %s is enabled but has no beacon node URLs (beaconUrls or BEACON_NODE_URL_%s)", network.Name, strings.ToUpper(string(network.Name))))
+		}
+		for _, beaconURL := range network.BeaconURLs {
+			parsed, err := url.Parse(beaconURL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				errs = append(errs, fmt.Sprintf("network %s has an invalid beacon node URL %q, it must be an http(s) URL", network.Name, beaconURL))
+			}
+		}
+		errs = append(errs, validateNetworkSpec(network.Spec())...)
+	}
+
+	if enabledCount == 0 {
+		errs = append(errs, "no network is enabled, set at least one network in the config file or a BEACON_NODE_URL_<NETWORK> variable")
+	}
+	return errs
+}
+
+// validateNetworkSpec checks the chain metadata of a network is complete. Networks unknown to the listener
+// must define it in the config file
+func validateNetworkSpec(spec types.NetworkSpec) []string {
+	var errs []string
+	if _, known := types.DefaultNetworkSpecs[spec.Name]; !known && spec.GenesisValidatorsRoot == "" {
+		errs = append(errs, fmt.Sprintf("network %s is unknown, its chain metadata (genesisTime, secondsPerSlot, slotsPerEpoch, genesisValidatorsRoot, genesisForkVersion) must be set in the config file", spec.Name))
+		return errs
+	}
+	if spec.GenesisTime == 0 {
+		errs = append(errs, fmt.Sprintf("network %s has no genesisTime", spec.Name))
+	}
+	if spec.SecondsPerSlot == 0 {
+		errs = append(errs, fmt.Sprintf("network %s has no secondsPerSlot", spec.Name))
+	}
+	if spec.SlotsPerEpoch == 0 {
+		errs = append(errs, fmt.Sprintf("network %s has no slotsPerEpoch", spec.Name))
+	}
+	if !isHexOfLength(spec.GenesisValidatorsRoot, 32) {
+		errs = append(errs, fmt.Sprintf("network %s genesisValidatorsRoot must be a 0x prefixed 32 bytes hex string, got %q", spec.Name, spec.GenesisValidatorsRoot))
+	}
+	if !isHexOfLength(spec.GenesisForkVersion, 4) {
+		errs = append(errs, fmt.Sprintf("network %s genesisForkVersion must be a 0x prefixed 4 bytes hex string, got %q", spec.Name, spec.GenesisForkVersion))
+	}
+	return errs
+}
+
+func isHexOfLength(value string, length int) bool {
+	if !strings.HasPrefix(value, "0x") {
+		return false
+	}
+	decoded, err := hex.DecodeString(value[2:])
+	return err == nil && len(decoded) == length
+}
+
+func validateTags(tags []types.TagSpec, networks []NetworkConfig) []string {
+	var errs []string
+	if len(tags) == 0 {
+		errs = append(errs, "no tags are allowed, set tags in the config file or ALLOWED_TAGS")
+	}
+
+	knownNetworks := make(map[types.Network]bool)
+	for _, network := range networks {
+		knownNetworks[network.Name] = true
+	}
+
+	seen := make(map[types.Tag]bool)
+	for i, tag := range tags {
+		if tag.Name == "" {
+			errs = append(errs, fmt.Sprintf("tags[%d] has no name", i))
+			continue
+		}
+		if seen[tag.Name] {
+			errs = append(errs, fmt.Sprintf("tag %s is defined more than once", tag.Name))
+		}
+		seen[tag.Name] = true
+		for _, network := range tag.Networks {
+			if !knownNetworks[network] {
+				errs = append(errs, fmt.Sprintf("tag %s is allowed in network %s, which is not defined", tag.Name, network))
+			}
+		}
+	}
+	return errs
+}
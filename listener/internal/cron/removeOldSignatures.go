@@ -6,25 +6,18 @@ import (
 	"time"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 )
 
-// RemoveOldSignatures deletes signatures older than a specified number of hours from the MongoDB collection
-func RemoveOldSignatures(collection *mongo.Collection, hours int) {
+// RemoveOldSignatures deletes signatures older than a specified number of hours from the store.
+// Returns the number of validators deleted. The deletion is aborted if ctx is cancelled
+func RemoveOldSignatures(ctx context.Context, signatureStore store.SignatureStore, hours int) (int64, error) {
 	logger.Debug(fmt.Sprintf("Removing signatures older than %d hours", hours))
-	targetTimeMillis := time.Now().Add(time.Duration(-hours) * time.Hour).UnixMilli() // Calculate time in the past based on hours
-	filter := bson.M{
-		"entries.decodedPayload.timestamp": bson.M{
-			"$lt": fmt.Sprintf("%d", targetTimeMillis), // Compare timestamps as strings
-		},
-	}
-	// DeleteMany returns the number of documents deleted, it is useless for us since we're never
-	// deleting a document, but an entry on its "entries" array
-	_, err := collection.DeleteMany(context.Background(), filter)
+	deleted, err := signatureStore.RemoveOldSignatures(ctx, time.Duration(hours)*time.Hour)
 	if err != nil {
 		logger.Error("Failed to delete old signatures: " + err.Error())
-	} else {
-		logger.Debug("Deleted old signatures")
+		return 0, err
 	}
+	logger.Debug(fmt.Sprintf("Deleted %d documents with old signatures", deleted))
+	return deleted, nil
 }
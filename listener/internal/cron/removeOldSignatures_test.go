@@ -0,0 +1,34 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// retentionStore records the retention RemoveOldSignatures is called with
+type retentionStore struct {
+	store.SignatureStore
+	retention time.Duration
+}
+
+func (s *retentionStore) RemoveOldSignatures(ctx context.Context, retention time.Duration) (int64, error) {
+	s.retention = retention
+	return 3, nil
+}
+
+func TestRemoveOldSignatures(t *testing.T) {
+	signatureStore := &retentionStore{}
+	deleted, err := RemoveOldSignatures(context.Background(), signatureStore, 48)
+	if err != nil {
+		t.Fatalf("RemoveOldSignatures returned an error: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("Expected 3 validators removed, got %d", deleted)
+	}
+	if signatureStore.retention != 48*time.Hour {
+		t.Errorf("Expected a retention of 48h, got %s", signatureStore.retention)
+	}
+}
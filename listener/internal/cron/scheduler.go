@@ -0,0 +1,231 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	robfigCron "github.com/robfig/cron/v3"
+)
+
+var (
+	ErrJobNotFound = errors.New("cron job not found")
+	ErrJobRunning  = errors.New("cron job already running")
+	ErrStopped     = errors.New("cron jobs are stopped, the listener is shutting down")
+	ErrNotLeader   = errors.New("this replica is not the leader, another replica runs the cron jobs")
+)
+
+// Job is a cron job. Run returns the counts of what the run did, e.g. the number of validators removed
+type Job struct {
+	Name     string
+	Schedule string
+	Enabled  bool
+	// Timeout cancels a run taking longer, 0 means no limit
+	Timeout time.Duration
+	Run     func(ctx context.Context) (map[string]int64, error)
+}
+
+// runSaveTimeout is how long saving the result of a run has, the context of the run may already be done
+const runSaveTimeout = 5 * time.Second
+
+// JobStatus is the last run of a job, by any replica
+type JobStatus struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Enabled  bool   `json:"enabled"`
+	// Running is whether the job runs on this replica
+	Running bool `json:"running"`
+	// Trigger is schedule or manual
+	Trigger string `json:"trigger,omitempty"`
+	// RanBy is the replica that ran the job
+	RanBy         string           `json:"ranBy,omitempty"`
+	LastRunAt     *time.Time       `json:"lastRunAt,omitempty"`
+	LastDuration  string           `json:"lastDuration,omitempty"`
+	LastCounts    map[string]int64 `json:"lastCounts,omitempty"`
+	LastError     string           `json:"lastError,omitempty"`
+	LastSuccessAt *time.Time       `json:"lastSuccessAt,omitempty"`
+}
+
+type scheduledJob struct {
+	job     Job
+	running bool
+}
+
+// Scheduler runs the enabled jobs on their schedule and any job on demand, only on the leader replica. A job never
+// runs twice at once. The last run of every job is saved in the database, so every replica reports it
+type Scheduler struct {
+	ctx     context.Context
+	cron    *robfigCron.Cron
+	elector *leader.Elector
+	runs    leader.RunStore
+
+	mu      sync.Mutex
+	jobs    []*scheduledJob
+	stopped bool
+	// manual are the runs started on demand, waited for on Stop
+	manual sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler whose runs are cancelled with ctx
+func NewScheduler(ctx context.Context, elector *leader.Elector, runs leader.RunStore) *Scheduler {
+	return &Scheduler{ctx: ctx, cron: robfigCron.New(), elector: elector, runs: runs}
+}
+
+// Add adds a job. Disabled jobs are not scheduled but can still be run on demand
+func (s *Scheduler) Add(job Job) error {
+	scheduled := &scheduledJob{job: job}
+	if job.Enabled {
+		_, err := s.cron.AddFunc(job.Schedule, func() {
+			s.elector.RunIfLeader(s.ctx, job.Name, func(ctx context.Context) {
+				s.mu.Lock()
+				started := s.tryStart(scheduled)
+				s.mu.Unlock()
+				if !started {
+					logger.Warn(fmt.Sprintf("Skipping %s, the previous run has not finished", job.Name))
+					return
+				}
+				s.run(ctx, scheduled, "schedule")
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("invalid schedule of cron job %s: %w", job.Name, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, scheduled)
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling jobs. The returned context is done once the running jobs, scheduled or manual, have returned
+func (s *Scheduler) Stop() context.Context {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	cronDone := s.cron.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-cronDone.Done()
+		s.manual.Wait()
+		cancel()
+	}()
+	return ctx
+}
+
+// Trigger runs the job now in the background, even if it is disabled. Only the leader runs it, same as the scheduled
+// runs, so a manual run never overlaps a scheduled run of another replica
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return ErrStopped
+	}
+	for _, scheduled := range s.jobs {
+		if scheduled.job.Name != name {
+			continue
+		}
+		if !s.elector.IsLeader() {
+			return ErrNotLeader
+		}
+		if !s.tryStart(scheduled) {
+			return ErrJobRunning
+		}
+		s.manual.Add(1)
+		go func() {
+			defer s.manual.Done()
+			ran := false
+			s.elector.RunIfLeader(s.ctx, name, func(ctx context.Context) {
+				ran = true
+				s.run(ctx, scheduled, "manual")
+			})
+			if !ran {
+				// the leadership was lost right after the check
+				s.mu.Lock()
+				scheduled.running = false
+				s.mu.Unlock()
+			}
+		}()
+		return nil
+	}
+	return ErrJobNotFound
+}
+
+// IsLeader returns whether this replica runs the scheduled jobs
+func (s *Scheduler) IsLeader() bool {
+	return s.elector.IsLeader()
+}
+
+// Statuses returns the status of every job, in the order they were added, with its last run from the database
+func (s *Scheduler) Statuses(ctx context.Context) ([]JobStatus, error) {
+	runs, err := s.runs.GetRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cron runs: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, scheduled := range s.jobs {
+		status := JobStatus{Name: scheduled.job.Name, Schedule: scheduled.job.Schedule, Enabled: scheduled.job.Enabled, Running: scheduled.running}
+		if run, ok := runs[scheduled.job.Name]; ok {
+			startedAt := run.StartedAt
+			status.Trigger = run.Trigger
+			status.RanBy = run.Holder
+			status.LastRunAt = &startedAt
+			status.LastDuration = run.Duration.String()
+			status.LastCounts = run.Counts
+			status.LastError = run.Error
+			status.LastSuccessAt = run.LastSuccessAt
+		}
+		statuses[i] = status
+	}
+	return statuses, nil
+}
+
+// tryStart marks the job running, unless it already is. Must be called with the lock held
+func (s *Scheduler) tryStart(scheduled *scheduledJob) bool {
+	if scheduled.running {
+		return false
+	}
+	scheduled.running = true
+	return true
+}
+
+// run runs a job marked running by tryStart and saves its result
+func (s *Scheduler) run(ctx context.Context, scheduled *scheduledJob, trigger string) {
+	defer func() {
+		s.mu.Lock()
+		scheduled.running = false
+		s.mu.Unlock()
+	}()
+
+	runCtx := ctx
+	if scheduled.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, scheduled.job.Timeout)
+		defer cancel()
+	}
+	startedAt := time.Now()
+	counts, err := scheduled.job.Run(runCtx)
+	run := leader.Run{Name: scheduled.job.Name, Trigger: trigger, Holder: s.elector.Holder(), StartedAt: startedAt, Duration: time.Since(startedAt), Counts: counts}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Cron job %s failed after %s: %v", run.Name, run.Duration, err))
+		run.Error = err.Error()
+	} else {
+		logger.Debug(fmt.Sprintf("Cron job %s finished in %s", run.Name, run.Duration))
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runSaveTimeout)
+	defer cancel()
+	if err := s.runs.SaveRun(saveCtx, run); err != nil {
+		logger.Error(fmt.Sprintf("Failed to save the run of cron job %s: %v", run.Name, err))
+	}
+}
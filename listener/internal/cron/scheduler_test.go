@@ -0,0 +1,133 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/leader"
+)
+
+// singleLeaseStore gives the lease to every holder while free is true, as if each one was the only replica
+type singleLeaseStore struct {
+	free bool
+}
+
+func (s *singleLeaseStore) TryAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	return s.free, nil
+}
+
+func (s *singleLeaseStore) Release(ctx context.Context, name string, holder string) error {
+	return nil
+}
+
+// memoryRunStore is a RunStore in memory, shared by the schedulers of a test as if they were replicas
+type memoryRunStore struct {
+	mu   sync.Mutex
+	runs map[string]leader.Run
+}
+
+func (s *memoryRunStore) SaveRun(ctx context.Context, run leader.Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.Error == "" {
+		run.LastSuccessAt = &run.StartedAt
+	} else if previous, ok := s.runs[run.Name]; ok {
+		run.LastSuccessAt = previous.LastSuccessAt
+	}
+	s.runs[run.Name] = run
+	return nil
+}
+
+func (s *memoryRunStore) GetRuns(ctx context.Context) (map[string]leader.Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.runs), nil
+}
+
+// newElector returns an elector that has run its first election
+func newElector(t *testing.T, leases *singleLeaseStore) *leader.Elector {
+	elector := leader.NewElector(leases, "crons", time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go elector.Run(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for elector.IsLeader() != leases.free && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return elector
+}
+
+func TestSchedulerTriggerNotLeader(t *testing.T) {
+	scheduler := NewScheduler(context.Background(), newElector(t, &singleLeaseStore{free: false}), &memoryRunStore{runs: make(map[string]leader.Run)})
+	err := scheduler.Add(Job{Name: "test", Schedule: "@daily", Enabled: true, Run: func(ctx context.Context) (map[string]int64, error) {
+		t.Errorf("Expected the job not to run on a replica that is not the leader")
+		return nil, nil
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := scheduler.Trigger("test"); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader, got: %v", err)
+	}
+	<-scheduler.Stop().Done()
+}
+
+func TestSchedulerTrigger(t *testing.T) {
+	runs := &memoryRunStore{runs: make(map[string]leader.Run)}
+	scheduler := NewScheduler(context.Background(), newElector(t, &singleLeaseStore{free: true}), runs)
+	release := make(chan struct{})
+	err := scheduler.Add(Job{
+		Name:     "test",
+		Schedule: "@daily",
+		// disabled jobs can still be triggered
+		Enabled: false,
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			<-release
+			return map[string]int64{"removed": 2}, errors.New("beacon node down")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := scheduler.Trigger("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got: %v", err)
+	}
+	if err := scheduler.Trigger("test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := scheduler.Trigger("test"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning while the job runs, got: %v", err)
+	}
+
+	close(release)
+	<-scheduler.Stop().Done()
+	statuses, err := scheduler.Statuses(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	status := statuses[0]
+	if status.Running || status.Trigger != "manual" || status.LastRunAt == nil || status.LastCounts["removed"] != 2 || status.LastError != "beacon node down" || status.LastSuccessAt != nil {
+		t.Errorf("Unexpected status after the run: %+v", status)
+	}
+
+	// another replica reports the run saved in the database
+	other := NewScheduler(context.Background(), newElector(t, &singleLeaseStore{free: false}), runs)
+	if err := other.Add(Job{Name: "test", Schedule: "@daily"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	statuses, err = other.Statuses(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status := statuses[0]; status.Running || status.RanBy == "" || status.LastCounts["removed"] != 2 || status.LastError != "beacon node down" {
+		t.Errorf("Expected the other replica to report the saved run, got %+v", status)
+	}
+	if err := scheduler.Trigger("test"); !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected ErrStopped after Stop, got: %v", err)
+	}
+}
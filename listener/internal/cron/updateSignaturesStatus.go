@@ -2,57 +2,44 @@ package cron
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
 	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
 )
 
-func UpdateSignaturesStatus(collection *mongo.Collection, beaconNodeUrls map[types.Network]string) {
+// UpdateSignaturesStatusResult counts the validators with status unknown, and the ones set active or removed
+type UpdateSignaturesStatusResult struct {
+	Unknown   int
+	Activated int
+	Removed   int
+}
+
+// UpdateSignaturesStatus sets the validators with status unknown to active, or removes them if inactive. If ctx is
+// cancelled it stops before the next validator, every update is done on its own so none is left half-done.
+// beaconNodeTimeout is how long every beacon node has to answer. A network whose beacon nodes are all down, or a
+// validator that could not be updated, does not stop the others but makes the run fail with the errors of every network
+// and the first error of the store
+func UpdateSignaturesStatus(ctx context.Context, signatureStore store.SignatureStore, beaconNodeUrls map[types.Network][]string, beaconNodeTimeout time.Duration) (UpdateSignaturesStatusResult, error) {
 	logger.Debug("Updating statuses and removing inactive signatures")
+	var result UpdateSignaturesStatusResult
 
-	// Step 1: Query the MongoDB collection to retrieve all documents with status "unknown"
-	filter := bson.M{"status": types.Unknown}
-	projection := bson.M{
-		"pubkey":  1,
-		"tag":     1,
-		"network": 1,
-	}
-	cursor, err := collection.Find(context.Background(), filter, options.Find().SetProjection(projection))
+	// Step 1: Query the store to retrieve all validators with status "unknown"
+	pubkeyTagNetworkPairs, err := signatureStore.GetValidatorKeysByStatus(ctx, types.Unknown)
 	if err != nil {
-		logger.Error("Failed to query MongoDB collection: " + err.Error())
-		return
-	}
-	defer cursor.Close(context.Background())
-
-	// Step 2: Extract pubkeys, tags, and networks from the documents
-	type PubkeyTagNetwork struct {
-		Pubkey  string
-		Tag     types.Tag
-		Network types.Network
-	}
-	var pubkeyTagNetworkPairs []PubkeyTagNetwork
-	for cursor.Next(context.Background()) {
-		var signature PubkeyTagNetwork
-		if err := cursor.Decode(&signature); err != nil {
-			logger.Error("Failed to decode MongoDB document: " + err.Error())
-			continue
-
-		}
-		pubkeyTagNetworkPairs = append(pubkeyTagNetworkPairs, signature)
+		logger.Error("Failed to query unknown validators: " + err.Error())
+		return result, err
 	}
+	result.Unknown = len(pubkeyTagNetworkPairs)
 
-	if err := cursor.Err(); err != nil {
-		logger.Error("Failed to iterate over MongoDB cursor: " + err.Error())
-		return
-	}
-
-	// Step 3: Query GetValidatorsStatus using these pubkeys and the corresponding beacon node URL
+	// Step 2: Query GetValidatorsStatus using these pubkeys and the corresponding beacon node URL
+	var errs []error
 	pubkeyStatusMap := make(map[string]types.Status)
-	for network, url := range beaconNodeUrls {
+	for network, urls := range beaconNodeUrls {
 		var networkPubkeys []string
 		for _, pair := range pubkeyTagNetworkPairs {
 			if pair.Network == network {
@@ -60,9 +47,15 @@ func UpdateSignaturesStatus(collection *mongo.Collection, beaconNodeUrls map[typ
 			}
 		}
 		if len(networkPubkeys) > 0 {
-			statusMap, err := validation.GetValidatorsStatus(networkPubkeys, url)
+			statusMap, err := validation.GetValidatorsStatus(ctx, networkPubkeys, urls, beaconNodeTimeout)
 			if err != nil {
 				logger.Error("Failed to get active validators: " + err.Error())
+				errs = append(errs, fmt.Errorf("failed to get the status of the %s validators: %w", network, err))
+				continue
+			}
+			// the beacon nodes answer active or inactive, every status is unknown only if all of them are down
+			if allUnknown(statusMap) {
+				errs = append(errs, fmt.Errorf("every beacon node of %s is down", network))
 				continue
 			}
 			for pubkey, status := range statusMap {
@@ -71,8 +64,21 @@ func UpdateSignaturesStatus(collection *mongo.Collection, beaconNodeUrls map[typ
 		}
 	}
 
-	// Step 4: Update or remove documents based on the validator status
+	// Step 3: Update or remove documents based on the validator status. A store that is down would fail every
+	// validator, so only the first error is kept
+	failed := 0
+	var firstStoreErr error
+	storeFailed := func(err error) {
+		failed++
+		if firstStoreErr == nil {
+			firstStoreErr = err
+		}
+	}
 	for _, pair := range pubkeyTagNetworkPairs {
+		if ctx.Err() != nil {
+			logger.Warn("Stopped updating signatures status: " + ctx.Err().Error())
+			return result, ctx.Err()
+		}
 		status, exists := pubkeyStatusMap[pair.Pubkey]
 		if !exists {
 			continue
@@ -80,23 +86,35 @@ func UpdateSignaturesStatus(collection *mongo.Collection, beaconNodeUrls map[typ
 
 		if status == types.Active {
 			// Update the status to "active"
-			update := bson.M{
-				"$set": bson.M{"status": types.Active},
-			}
-			_, err := collection.UpdateOne(context.Background(), bson.M{"pubkey": pair.Pubkey, "tag": pair.Tag, "network": pair.Network, "status": types.Unknown}, update)
-			if err != nil {
+			if err := signatureStore.UpdateStatus(ctx, pair, types.Active, types.Unknown); err != nil {
 				logger.Error("Failed to update signature: " + err.Error())
+				storeFailed(fmt.Errorf("failed to set validator %s of %s active: %w", pair.Pubkey, pair.Network, err))
 				continue
 			}
+			result.Activated++
 			logger.Debug("Updated signature with pubkey " + pair.Pubkey + " to active")
 		} else if status == types.Inactive {
 			// Remove the signature
-			_, err := collection.DeleteOne(context.Background(), bson.M{"pubkey": pair.Pubkey, "tag": pair.Tag, "network": pair.Network, "status": types.Unknown})
-			if err != nil {
+			if err := signatureStore.DeleteValidator(ctx, pair, types.Unknown); err != nil {
 				logger.Error("Failed to remove signature: " + err.Error())
+				storeFailed(fmt.Errorf("failed to remove validator %s of %s: %w", pair.Pubkey, pair.Network, err))
 				continue
 			}
+			result.Removed++
 			logger.Debug("Removed signature with pubkey " + pair.Pubkey + " due to inactive validator status")
 		}
 	}
+	if failed > 0 {
+		errs = append(errs, fmt.Errorf("%d validators could not be updated, the first one: %w", failed, firstStoreErr))
+	}
+	return result, errors.Join(errs...)
+}
+
+func allUnknown(statusMap map[string]types.Status) bool {
+	for _, status := range statusMap {
+		if status != types.Unknown {
+			return false
+		}
+	}
+	return true
 }
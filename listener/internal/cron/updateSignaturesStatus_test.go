@@ -0,0 +1,95 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// statusStore has unknown validators and records the ones updated or removed. Updates of the pubkeys in failing fail
+type statusStore struct {
+	store.SignatureStore
+	unknown   []store.ValidatorKey
+	failing   map[string]bool
+	activated []string
+	removed   []string
+}
+
+func (s *statusStore) GetValidatorKeysByStatus(ctx context.Context, status types.Status) ([]store.ValidatorKey, error) {
+	return s.unknown, nil
+}
+
+func (s *statusStore) UpdateStatus(ctx context.Context, key store.ValidatorKey, status types.Status, onlyIf types.Status) error {
+	if s.failing[key.Pubkey] {
+		return errors.New("write conflict")
+	}
+	s.activated = append(s.activated, key.Pubkey)
+	return nil
+}
+
+func (s *statusStore) DeleteValidator(ctx context.Context, key store.ValidatorKey, onlyIf types.Status) error {
+	if s.failing[key.Pubkey] {
+		return errors.New("write conflict")
+	}
+	s.removed = append(s.removed, key.Pubkey)
+	return nil
+}
+
+// newValidatorsBeaconNode answers the given pubkeys as active, every other one is inactive
+func newValidatorsBeaconNode(t *testing.T, activePubkeys ...string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := []string{}
+		for _, pubkey := range activePubkeys {
+			data = append(data, `{"validator":{"pubkey":"`+pubkey+`"}}`)
+		}
+		w.Write([]byte(`{"data":[` + strings.Join(data, ",") + `]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestUpdateSignaturesStatus(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	signatureStore := &statusStore{
+		unknown: []store.ValidatorKey{
+			{Network: types.Mainnet, Pubkey: "0xactive", Tag: types.Solo},
+			{Network: types.Mainnet, Pubkey: "0xinactive", Tag: types.Solo},
+			{Network: types.Mainnet, Pubkey: "0xfailing", Tag: types.Solo},
+			{Network: types.Holesky, Pubkey: "0xholesky", Tag: types.Solo},
+		},
+		failing: map[string]bool{"0xfailing": true},
+	}
+	beaconNodeUrls := map[types.Network][]string{
+		types.Mainnet: {down.URL, newValidatorsBeaconNode(t, "0xactive", "0xfailing")},
+		types.Holesky: {down.URL},
+	}
+
+	result, err := UpdateSignaturesStatus(context.Background(), signatureStore, beaconNodeUrls, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "every beacon node of holesky is down") || !strings.Contains(err.Error(), "1 validators could not be updated") {
+		t.Errorf("Expected the errors of holesky and of the failed update, got: %v", err)
+	}
+	// the other validators are still updated
+	if result.Unknown != 4 || result.Activated != 1 || result.Removed != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(signatureStore.activated) != 1 || signatureStore.activated[0] != "0xactive" || len(signatureStore.removed) != 1 || signatureStore.removed[0] != "0xinactive" {
+		t.Errorf("Unexpected updates, activated %v and removed %v", signatureStore.activated, signatureStore.removed)
+	}
+
+	// nothing fails once the beacon nodes are back
+	signatureStore = &statusStore{unknown: signatureStore.unknown[:2]}
+	if _, err := UpdateSignaturesStatus(context.Background(), signatureStore, beaconNodeUrls, time.Second); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
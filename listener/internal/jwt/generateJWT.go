@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 	"time"
 
@@ -8,7 +10,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateJWT(kid, privateKeyPath, subject, expiration string) (string, error) {
+// GenerateJWT generates a signed JWT. If jti is empty a random one is generated, it can be used later on
+// to revoke this single token.
+func GenerateJWT(kid, privateKeyPath, subject, expiration, jti string) (string, error) {
 	logger.Info("Starting JWT generation")
 
 	privateKeyData, err := os.ReadFile(privateKeyPath)
@@ -22,7 +26,19 @@ func GenerateJWT(kid, privateKeyPath, subject, expiration string) (string, error
 		return "", err
 	}
 
-	claims := jwt.MapClaims{}
+	if jti == "" {
+		jti, err = generateJti()
+		if err != nil {
+			logger.Error("Failed to generate jti: " + err.Error())
+			return "", err
+		}
+	}
+
+	claims := jwt.MapClaims{
+		"jti": jti,
+		"iat": time.Now().Unix(),
+	}
+	logger.Info("JWT ID claim set: " + jti)
 	if subject != "" {
 		claims["sub"] = subject
 		logger.Info("Subject claim set: " + subject)
@@ -50,3 +66,12 @@ func GenerateJWT(kid, privateKeyPath, subject, expiration string) (string, error
 
 	return tokenString, nil
 }
+
+// generateJti returns a random 128 bits hex encoded token id
+func generateJti() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,116 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RevokedToken is a single token revoked by its jti claim
+type RevokedToken struct {
+	RevokedAt int64  `json:"revokedAt"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// RevokedKid revokes every token of a kid issued up to a given time, in seconds like iat, so a token issued in the
+// same second is revoked too. Tokens issued after IssuedBefore (e.g. a new token handed to the user after a leak)
+// are still accepted.
+type RevokedKid struct {
+	IssuedBefore int64  `json:"issuedBefore"`
+	RevokedAt    int64  `json:"revokedAt"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// RevocationList is the content of the JWT revocation file
+type RevocationList struct {
+	Jtis map[string]RevokedToken `json:"jtis"`
+	Kids map[string]RevokedKid   `json:"kids"`
+}
+
+// LoadRevocationList reads the revocation file. A missing file is not an error, it means nothing has been revoked yet.
+func LoadRevocationList(filePath string) (*RevocationList, error) {
+	list := &RevocationList{
+		Jtis: map[string]RevokedToken{},
+		Kids: map[string]RevokedKid{},
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return list, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("invalid revocation file %s: %w", filePath, err)
+	}
+	if list.Jtis == nil {
+		list.Jtis = map[string]RevokedToken{}
+	}
+	if list.Kids == nil {
+		list.Kids = map[string]RevokedKid{}
+	}
+	return list, nil
+}
+
+// SaveRevocationList writes the revocation file. It writes to a temporary file first and renames it,
+// so the listener never reads a half written file.
+func SaveRevocationList(filePath string, list *RevocationList) error {
+	data, err := json.MarshalIndent(list, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".revocations-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+// RevokeJti revokes a single token by its jti claim
+func (l *RevocationList) RevokeJti(jti, reason string) {
+	l.Jtis[jti] = RevokedToken{
+		RevokedAt: time.Now().Unix(),
+		Reason:    reason,
+	}
+}
+
+// RevokeKid revokes all the tokens of a kid issued before issuedBefore, or in the same second
+func (l *RevocationList) RevokeKid(kid string, issuedBefore time.Time, reason string) {
+	l.Kids[kid] = RevokedKid{
+		IssuedBefore: issuedBefore.Unix(),
+		RevokedAt:    time.Now().Unix(),
+		Reason:       reason,
+	}
+}
+
+// IsRevoked checks a token against the revocation list. issuedAt is the iat claim of the token, tokens
+// without iat are considered issued at the unix epoch, so they are always revoked when their kid is revoked.
+func (l *RevocationList) IsRevoked(kid, jti string, issuedAt time.Time) bool {
+	if jti != "" {
+		if _, revoked := l.Jtis[jti]; revoked {
+			return true
+		}
+	}
+	if entry, revoked := l.Kids[kid]; revoked {
+		return issuedAt.Unix() <= entry.IssuedBefore
+	}
+	return false
+}
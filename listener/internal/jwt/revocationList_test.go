@@ -0,0 +1,54 @@
+package jwt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevocationList(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "revocations.json")
+
+	// A missing file means nothing is revoked
+	list, err := LoadRevocationList(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load missing revocation file: %v", err)
+	}
+	if list.IsRevoked("stader", "abc", time.Now()) {
+		t.Errorf("Expected no token to be revoked with an empty list")
+	}
+
+	revocationTime := time.Now()
+	list.RevokeJti("leaked-jti", "leaked in logs")
+	list.RevokeKid("stader", revocationTime, "")
+
+	if err := SaveRevocationList(filePath, list); err != nil {
+		t.Fatalf("Failed to save revocation file: %v", err)
+	}
+	list, err = LoadRevocationList(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load revocation file: %v", err)
+	}
+
+	testCases := []struct {
+		description string
+		kid         string
+		jti         string
+		issuedAt    time.Time
+		revoked     bool
+	}{
+		{"Revoked jti", "other", "leaked-jti", time.Now(), true},
+		{"Kid token issued before revocation", "stader", "abc", revocationTime.Add(-time.Hour), true},
+		{"Kid token issued in the same second as the revocation", "stader", "abc", revocationTime.Truncate(time.Second).Add(999 * time.Millisecond), true},
+		{"Kid token without iat", "stader", "", time.Unix(0, 0), true},
+		{"Kid token issued after revocation", "stader", "abc", revocationTime.Add(time.Hour), false},
+		{"Kid token issued the second after revocation", "stader", "abc", revocationTime.Truncate(time.Second).Add(time.Second), false},
+		{"Not revoked kid and jti", "other", "abc", time.Now(), false},
+	}
+
+	for _, tc := range testCases {
+		if revoked := list.IsRevoked(tc.kid, tc.jti, tc.issuedAt); revoked != tc.revoked {
+			t.Errorf("%s: expected revoked to be %v, got %v", tc.description, tc.revoked, revoked)
+		}
+	}
+}
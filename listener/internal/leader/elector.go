@@ -0,0 +1,127 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+)
+
+// Elector elects a single leader among the replicas of the listener, the holder of a lease. The leader renews its
+// lease every third of the ttl, so another replica takes it over within the ttl if the leader stops renewing it
+type Elector struct {
+	leases LeaseStore
+	name   string
+	holder string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// leading is cancelled when the leadership is lost, nil while not leading
+	leading       context.Context
+	cancelLeading context.CancelFunc
+}
+
+// NewElector returns an elector of the lease name. Every replica has its own holder id
+func NewElector(leases LeaseStore, name string, ttl time.Duration) *Elector {
+	hostname, _ := os.Hostname()
+	return &Elector{
+		leases: leases,
+		name:   name,
+		holder: fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		ttl:    ttl,
+	}
+}
+
+// Run tries to take or renew the lease until ctx is cancelled, then releases it if this replica is the leader
+func (e *Elector) Run(ctx context.Context) {
+	e.renew(ctx)
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+			e.renew(ctx)
+		}
+	}
+}
+
+// Holder returns the id of this replica in the leases
+func (e *Elector) Holder() string {
+	return e.holder
+}
+
+// IsLeader returns whether this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading != nil
+}
+
+// RunIfLeader runs job only if this replica is the leader. The context of the job is also cancelled if the leadership
+// is lost while it runs, since another replica may start the same job
+func (e *Elector) RunIfLeader(ctx context.Context, name string, job func(ctx context.Context)) {
+	e.mu.Lock()
+	leading := e.leading
+	e.mu.Unlock()
+	if leading == nil {
+		logger.Debug(fmt.Sprintf("Skipping %s, another replica is the leader", name))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(leading, cancel)
+	defer stop()
+	job(ctx)
+}
+
+func (e *Elector) renew(ctx context.Context) {
+	// the renewal must finish before the lease expires
+	renewCtx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+	acquired, err := e.leases.TryAcquire(renewCtx, e.name, e.holder, e.ttl)
+	if err != nil {
+		// the lease may expire before it can be renewed, so the jobs are stopped right away
+		logger.Error("Failed to renew the cron lease: " + err.Error())
+		acquired = false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if acquired && e.leading == nil {
+		logger.Info("This replica is now the leader, it runs the cron jobs")
+		e.leading, e.cancelLeading = context.WithCancel(context.Background())
+	} else if !acquired && e.leading != nil {
+		logger.Warn("This replica is no longer the leader, cancelling its cron jobs")
+		e.stepDown()
+	}
+}
+
+func (e *Elector) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leading == nil {
+		return
+	}
+	e.stepDown()
+	// ctx of Run is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.leases.Release(ctx, e.name, e.holder); err != nil {
+		logger.Error("Failed to release the cron lease: " + err.Error())
+		return
+	}
+	logger.Info("Released the cron lease")
+}
+
+// stepDown cancels the jobs running as leader. Must be called with the lock held
+func (e *Elector) stepDown() {
+	e.cancelLeading()
+	e.leading, e.cancelLeading = nil, nil
+}
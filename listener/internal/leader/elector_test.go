@@ -0,0 +1,101 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryLeaseStore is a LeaseStore in memory. Holders in down get an error, as if they lost the database
+type memoryLeaseStore struct {
+	mu        sync.Mutex
+	holder    string
+	expiresAt time.Time
+	down      map[string]bool
+}
+
+func (s *memoryLeaseStore) TryAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down[holder] {
+		return false, errors.New("database unreachable")
+	}
+	if s.holder != holder && time.Now().Before(s.expiresAt) {
+		return false, nil
+	}
+	s.holder, s.expiresAt = holder, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (s *memoryLeaseStore) Release(ctx context.Context, name string, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder == holder {
+		s.holder = ""
+	}
+	return nil
+}
+
+func (s *memoryLeaseStore) setDown(holder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down[holder] = true
+}
+
+func isLeader(e *Elector) bool {
+	ran := false
+	e.RunIfLeader(context.Background(), "test", func(ctx context.Context) { ran = true })
+	return ran
+}
+
+func waitLeader(t *testing.T, e *Elector, expected bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for isLeader(e) != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected leader %v", expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestElector(t *testing.T) {
+	leases := &memoryLeaseStore{down: make(map[string]bool)}
+	ttl := 150 * time.Millisecond
+
+	first := NewElector(leases, "crons", ttl)
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	defer stopFirst()
+	go first.Run(firstCtx)
+	waitLeader(t, first, true)
+
+	second := NewElector(leases, "crons", ttl)
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx)
+	time.Sleep(ttl)
+	if isLeader(second) {
+		t.Fatalf("Expected a single leader")
+	}
+
+	// the leader loses the database: it steps down and the other replica takes over once the lease expires
+	leases.setDown(first.holder)
+	waitLeader(t, first, false)
+	waitLeader(t, second, true)
+
+	// a job running as leader is cancelled when the leadership is lost
+	jobCancelled := make(chan struct{})
+	go second.RunIfLeader(context.Background(), "test", func(ctx context.Context) {
+		<-ctx.Done()
+		close(jobCancelled)
+	})
+	time.Sleep(10 * time.Millisecond)
+	leases.setDown(second.holder)
+	select {
+	case <-jobCancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the job to be cancelled when the leadership is lost")
+	}
+}
@@ -0,0 +1,16 @@
+package leader
+
+import (
+	"context"
+	"time"
+)
+
+// LeaseStore keeps the leases in the database shared by every replica of the listener. A lease belongs to a single
+// holder until it expires
+type LeaseStore interface {
+	// TryAcquire takes the lease if it is free or expired, or extends it if holder already has it. Returns whether
+	// holder has the lease for ttl
+	TryAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	// Release frees the lease if holder has it, so another replica can take it without waiting for it to expire
+	Release(ctx context.Context, name string, holder string) error
+}
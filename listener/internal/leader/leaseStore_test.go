@@ -0,0 +1,137 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase is an empty database of a backend: the signatures collection of MongoDB, or a migrated Postgres schema
+type testDatabase struct {
+	signatures *mongo.Collection
+	pool       *pgxpool.Pool
+}
+
+// testDatabases returns an empty database of every backend with a test server configured, MONGO_TEST_URI or
+// POSTGRES_TEST_URL, by name. The test is skipped without any
+func testDatabases(t *testing.T) map[string]testDatabase {
+	ctx := context.Background()
+	databases := make(map[string]testDatabase)
+
+	if uri := os.Getenv("MONGO_TEST_URI"); uri != "" {
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			t.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		t.Cleanup(func() { client.Disconnect(ctx) })
+		db := client.Database(fmt.Sprintf("leaderTest_%d", time.Now().UnixNano()))
+		t.Cleanup(func() { db.Drop(ctx) })
+		databases["mongodb"] = testDatabase{signatures: db.Collection("signatures")}
+	}
+
+	if url := os.Getenv("POSTGRES_TEST_URL"); url != "" {
+		// a schema of its own, so the tests of other packages running at once do not interfere
+		admin, err := pgxpool.New(ctx, url)
+		if err != nil {
+			t.Fatalf("Failed to connect to Postgres: %v", err)
+		}
+		schema := fmt.Sprintf("leader_test_%d", time.Now().UnixNano())
+		if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+			t.Fatalf("Failed to create schema %s: %v", schema, err)
+		}
+		t.Cleanup(func() {
+			admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+			admin.Close()
+		})
+		poolConfig, err := pgxpool.ParseConfig(url)
+		if err != nil {
+			t.Fatalf("Invalid Postgres URL: %v", err)
+		}
+		poolConfig.ConnConfig.RuntimeParams["search_path"] = schema
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			t.Fatalf("Failed to connect to Postgres: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		if err := migrations.CheckPostgresSchema(ctx, pool); err != nil {
+			t.Fatalf("Failed to migrate Postgres: %v", err)
+		}
+		databases["postgres"] = testDatabase{pool: pool}
+	}
+
+	if len(databases) == 0 {
+		t.Skip("No test database, set MONGO_TEST_URI or POSTGRES_TEST_URL")
+	}
+	return databases
+}
+
+func (d testDatabase) leaseStore() LeaseStore {
+	if d.pool != nil {
+		return NewPostgresLeaseStore(d.pool)
+	}
+	return NewMongoLeaseStore(d.signatures)
+}
+
+func TestLeaseStore(t *testing.T) {
+	ctx := context.Background()
+	for name, database := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			leases := database.leaseStore()
+			tryAcquire := func(holder string, ttl time.Duration) bool {
+				t.Helper()
+				acquired, err := leases.TryAcquire(ctx, "crons", holder, ttl)
+				if err != nil {
+					t.Fatalf("TryAcquire returned an error: %v", err)
+				}
+				return acquired
+			}
+
+			if !tryAcquire("a", time.Minute) {
+				t.Fatalf("Expected a free lease to be acquired")
+			}
+			if tryAcquire("b", time.Minute) {
+				t.Errorf("Expected the lease to be refused while the lease of another holder is valid")
+			}
+			if !tryAcquire("a", time.Minute) {
+				t.Errorf("Expected the holder to renew its lease")
+			}
+			// other leases are independent
+			if acquired, err := leases.TryAcquire(ctx, "other", "b", time.Minute); err != nil || !acquired {
+				t.Errorf("Expected another lease to be acquired, got %t: %v", acquired, err)
+			}
+
+			// only the holder releases the lease
+			if err := leases.Release(ctx, "crons", "b"); err != nil {
+				t.Fatalf("Release returned an error: %v", err)
+			}
+			if tryAcquire("b", time.Minute) {
+				t.Errorf("Expected the lease to be kept after a release by another holder")
+			}
+			if err := leases.Release(ctx, "crons", "a"); err != nil {
+				t.Fatalf("Release returned an error: %v", err)
+			}
+			if !tryAcquire("b", 200*time.Millisecond) {
+				t.Fatalf("Expected the released lease to be acquired")
+			}
+
+			// the lease is taken over once it expires
+			if tryAcquire("a", time.Minute) {
+				t.Errorf("Expected the lease to be refused before it expires")
+			}
+			time.Sleep(300 * time.Millisecond)
+			if !tryAcquire("a", time.Minute) {
+				t.Errorf("Expected the expired lease to be taken over")
+			}
+			if tryAcquire("b", time.Minute) {
+				t.Errorf("Expected the previous holder to lose the lease")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoCollectionName is the collection of the leases, in the database of the signatures
+const MongoCollectionName = "cron_leases"
+
+type mongoLease struct {
+	Name      string    `bson:"_id"`
+	Holder    string    `bson:"holder"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type mongoLeaseStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoLeaseStore returns the lease store of the database of the signatures collection. The expiry is computed with
+// the clock of every replica, so their clocks must be in sync to well under the ttl
+func NewMongoLeaseStore(signatures *mongo.Collection) LeaseStore {
+	return &mongoLeaseStore{collection: signatures.Database().Collection(MongoCollectionName)}
+}
+
+func (s *mongoLeaseStore) TryAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	// matches the lease only if it can be taken, otherwise the upsert tries to insert a second lease with the same
+	// name and fails with a duplicate key error
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": holder, "expiresAt": now.Add(ttl)}}
+	_, err := s.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *mongoLeaseStore) Release(ctx context.Context, name string, holder string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+	return err
+}
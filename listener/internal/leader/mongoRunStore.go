@@ -0,0 +1,74 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRunsCollectionName is the collection of the last runs of the cron jobs, in the database of the signatures
+const MongoRunsCollectionName = "cron_runs"
+
+type mongoRun struct {
+	Name          string           `bson:"_id"`
+	Trigger       string           `bson:"trigger"`
+	Holder        string           `bson:"holder"`
+	StartedAt     time.Time        `bson:"startedAt"`
+	DurationMs    int64            `bson:"durationMs"`
+	Counts        map[string]int64 `bson:"counts"`
+	Error         string           `bson:"error"`
+	LastSuccessAt *time.Time       `bson:"lastSuccessAt,omitempty"`
+}
+
+type mongoRunStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRunStore returns the run store of the database of the signatures collection
+func NewMongoRunStore(signatures *mongo.Collection) RunStore {
+	return &mongoRunStore{collection: signatures.Database().Collection(MongoRunsCollectionName)}
+}
+
+func (s *mongoRunStore) SaveRun(ctx context.Context, run Run) error {
+	fields := bson.M{
+		"trigger":    run.Trigger,
+		"holder":     run.Holder,
+		"startedAt":  run.StartedAt,
+		"durationMs": run.Duration.Milliseconds(),
+		"counts":     run.Counts,
+		"error":      run.Error,
+	}
+	if run.Error == "" {
+		fields["lastSuccessAt"] = run.StartedAt
+	}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": run.Name}, bson.M{"$set": fields}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *mongoRunStore) GetRuns(ctx context.Context) (map[string]Run, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var documents []mongoRun
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	runs := make(map[string]Run, len(documents))
+	for _, document := range documents {
+		runs[document.Name] = Run{
+			Name:          document.Name,
+			Trigger:       document.Trigger,
+			Holder:        document.Holder,
+			StartedAt:     document.StartedAt,
+			Duration:      time.Duration(document.DurationMs) * time.Millisecond,
+			Counts:        document.Counts,
+			Error:         document.Error,
+			LastSuccessAt: document.LastSuccessAt,
+		}
+	}
+	return runs, nil
+}
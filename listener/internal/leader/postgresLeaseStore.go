@@ -0,0 +1,42 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresLeaseStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresLeaseStore returns the lease store of the cron_leases table, created by the Postgres migrations. The
+// expiry is computed with the clock of the database, so the clocks of the replicas do not matter
+func NewPostgresLeaseStore(pool *pgxpool.Pool) LeaseStore {
+	return &postgresLeaseStore{pool: pool}
+}
+
+func (s *postgresLeaseStore) TryAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	// the conflicting row is only updated if the lease can be taken, otherwise nothing is returned
+	var current string
+	err := s.pool.QueryRow(ctx, `
+INSERT INTO cron_leases (name, holder, expires_at) VALUES ($1, $2, now() + make_interval(secs => $3))
+ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+WHERE cron_leases.holder = EXCLUDED.holder OR cron_leases.expires_at < now()
+RETURNING holder`, name, holder, ttl.Seconds()).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *postgresLeaseStore) Release(ctx context.Context, name string, holder string) error {
+	_, err := s.pool.Exec(ctx, "DELETE FROM cron_leases WHERE name = $1 AND holder = $2", name, holder)
+	return err
+}
@@ -0,0 +1,55 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresRunStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresRunStore returns the run store of the cron_runs table, created by the Postgres migrations
+func NewPostgresRunStore(pool *pgxpool.Pool) RunStore {
+	return &postgresRunStore{pool: pool}
+}
+
+func (s *postgresRunStore) SaveRun(ctx context.Context, run Run) error {
+	var lastSuccessAt *time.Time
+	if run.Error == "" {
+		lastSuccessAt = &run.StartedAt
+	}
+	counts := run.Counts
+	if counts == nil {
+		counts = map[string]int64{}
+	}
+	_, err := s.pool.Exec(ctx, `
+INSERT INTO cron_runs (name, trigger, holder, started_at, duration_ms, counts, error, last_success_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (name) DO UPDATE SET trigger = EXCLUDED.trigger, holder = EXCLUDED.holder, started_at = EXCLUDED.started_at,
+	duration_ms = EXCLUDED.duration_ms, counts = EXCLUDED.counts, error = EXCLUDED.error,
+	last_success_at = COALESCE(EXCLUDED.last_success_at, cron_runs.last_success_at)`,
+		run.Name, run.Trigger, run.Holder, run.StartedAt, run.Duration.Milliseconds(), counts, run.Error, lastSuccessAt)
+	return err
+}
+
+func (s *postgresRunStore) GetRuns(ctx context.Context) (map[string]Run, error) {
+	rows, err := s.pool.Query(ctx, "SELECT name, trigger, holder, started_at, duration_ms, counts, error, last_success_at FROM cron_runs")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := make(map[string]Run)
+	for rows.Next() {
+		var run Run
+		var durationMs int64
+		if err := rows.Scan(&run.Name, &run.Trigger, &run.Holder, &run.StartedAt, &durationMs, &run.Counts, &run.Error, &run.LastSuccessAt); err != nil {
+			return nil, err
+		}
+		run.Duration = time.Duration(durationMs) * time.Millisecond
+		runs[run.Name] = run
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,30 @@
+package leader
+
+import (
+	"context"
+	"time"
+)
+
+// Run is the last run of a cron job, shared by every replica since any of them may be the leader that ran it
+type Run struct {
+	Name string
+	// Trigger is schedule or manual
+	Trigger string
+	// Holder is the replica that ran the job, see Elector.Holder
+	Holder    string
+	StartedAt time.Time
+	Duration  time.Duration
+	Counts    map[string]int64
+	// Error is empty if the run succeeded
+	Error string
+	// LastSuccessAt is when the last successful run started, nil if none. Set by the store, ignored by SaveRun
+	LastSuccessAt *time.Time
+}
+
+// RunStore keeps the last run of every cron job in the database of the leases
+type RunStore interface {
+	// SaveRun replaces the last run of the job. The last successful run is kept if this one failed
+	SaveRun(ctx context.Context, run Run) error
+	// GetRuns returns the last run of every job that has run, by name
+	GetRuns(ctx context.Context) (map[string]Run, error)
+}
@@ -0,0 +1,69 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func (d testDatabase) runStore() RunStore {
+	if d.pool != nil {
+		return NewPostgresRunStore(d.pool)
+	}
+	return NewMongoRunStore(d.signatures)
+}
+
+func TestRunStore(t *testing.T) {
+	ctx := context.Background()
+	for name, database := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			runs := database.runStore()
+			if saved, err := runs.GetRuns(ctx); err != nil || len(saved) != 0 {
+				t.Fatalf("Expected no runs, got %v: %v", saved, err)
+			}
+
+			succeeded := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+			if err := runs.SaveRun(ctx, Run{Name: "removeOldSignatures", Trigger: "schedule", Holder: "a", StartedAt: succeeded, Duration: 1500 * time.Millisecond, Counts: map[string]int64{"removed": 3}}); err != nil {
+				t.Fatalf("SaveRun returned an error: %v", err)
+			}
+			failed := succeeded.Add(30 * time.Minute)
+			if err := runs.SaveRun(ctx, Run{Name: "removeOldSignatures", Trigger: "manual", Holder: "b", StartedAt: failed, Duration: time.Second, Error: "database timeout"}); err != nil {
+				t.Fatalf("SaveRun returned an error: %v", err)
+			}
+			if err := runs.SaveRun(ctx, Run{Name: "updateSignaturesStatus", Trigger: "schedule", Holder: "a", StartedAt: failed, Error: "every beacon node of holesky is down"}); err != nil {
+				t.Fatalf("SaveRun returned an error: %v", err)
+			}
+
+			saved, err := runs.GetRuns(ctx)
+			if err != nil {
+				t.Fatalf("GetRuns returned an error: %v", err)
+			}
+			if len(saved) != 2 {
+				t.Fatalf("Expected the last run of 2 jobs, got %v", saved)
+			}
+			// the last run replaces the previous one, except when it last succeeded
+			run := saved["removeOldSignatures"]
+			if run.Trigger != "manual" || run.Holder != "b" || !run.StartedAt.Equal(failed) || run.Duration != time.Second || len(run.Counts) != 0 || run.Error != "database timeout" {
+				t.Errorf("Unexpected last run: %+v", run)
+			}
+			if run.LastSuccessAt == nil || !run.LastSuccessAt.Equal(succeeded) {
+				t.Errorf("Expected the last success to be kept from the previous run, got %v", run.LastSuccessAt)
+			}
+			if run := saved["updateSignaturesStatus"]; run.LastSuccessAt != nil || run.Error == "" {
+				t.Errorf("Expected a job that never succeeded to have no last success, got %+v", run)
+			}
+
+			// a successful run moves the last success
+			if err := runs.SaveRun(ctx, Run{Name: "updateSignaturesStatus", Trigger: "schedule", Holder: "a", StartedAt: failed.Add(time.Minute), Counts: map[string]int64{"activated": 2}}); err != nil {
+				t.Fatalf("SaveRun returned an error: %v", err)
+			}
+			saved, err = runs.GetRuns(ctx)
+			if err != nil {
+				t.Fatalf("GetRuns returned an error: %v", err)
+			}
+			if run := saved["updateSignaturesStatus"]; run.Error != "" || run.Counts["activated"] != 2 || run.LastSuccessAt == nil || !run.LastSuccessAt.Equal(failed.Add(time.Minute)) {
+				t.Errorf("Unexpected run after a success: %+v", run)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package maintenance
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ExportSignatures writes the validators matching the filter as JSON lines, one canonical extended JSON document per
+// line, so they can be imported back without losing any BSON type. The format is the same whatever the storage mode,
+// so an export can be imported in another mode. Returns the number of documents exported
+func ExportSignatures(ctx context.Context, signatureStore store.SignatureStore, filter store.Filter, w io.Writer) (int, error) {
+	writer := bufio.NewWriter(w)
+	count := 0
+	err := signatureStore.ForEachValidator(ctx, filter, func(validator store.Validator) error {
+		line, err := bson.MarshalExtJSON(validator, true, false)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, writer.Flush()
+}
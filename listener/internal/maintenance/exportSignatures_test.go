@@ -0,0 +1,69 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+func TestExportImportSignatures(t *testing.T) {
+	ctx := context.Background()
+	source := &memoryStore{validators: []store.Validator{
+		{
+			ValidatorKey: store.ValidatorKey{Network: types.Mainnet, Pubkey: "0xaa", Tag: types.Solo},
+			Status:       types.Active,
+			Entries: []store.Entry{{
+				Payload:        "cGF5bG9hZA==",
+				Signature:      "0x01",
+				DecodedPayload: map[string]interface{}{"type": "PROOF_OF_VALIDATION", "timestamp": "1711983600000"},
+				Submitter:      "brain-1",
+				Metadata:       &types.Metadata{ExecutionClient: "geth"},
+			}},
+		},
+		{
+			ValidatorKey: store.ValidatorKey{Network: types.Gnosis, Pubkey: "0xbb", Tag: types.Obol},
+			Status:       types.Unknown,
+			Entries:      []store.Entry{{Payload: "cGF5bG9hZA==", Signature: "0x02", DecodedPayload: map[string]interface{}{}}},
+		},
+	}}
+
+	var exported bytes.Buffer
+	count, err := ExportSignatures(ctx, source, store.Filter{Networks: []string{string(types.Mainnet)}}, &exported)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 document of mainnet exported, got %d: %v", count, err)
+	}
+	if lines := strings.Count(exported.String(), "\n"); lines != 1 {
+		t.Fatalf("Expected 1 JSON line, got %d", lines)
+	}
+
+	// The import replaces the document with the same key and keeps the rest
+	target := &memoryStore{validators: []store.Validator{
+		{ValidatorKey: source.validators[0].ValidatorKey, Status: types.Unknown},
+		source.validators[1],
+	}}
+	count, err = ImportSignatures(ctx, target, &exported)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 document imported, got %d: %v", count, err)
+	}
+	if !reflect.DeepEqual(target.validators, source.validators) {
+		t.Errorf("Expected the imported documents to match the exported ones\ngot:  %+v\nwant: %+v", target.validators, source.validators)
+	}
+}
+
+func TestImportSignaturesInvalidLine(t *testing.T) {
+	target := &memoryStore{}
+	input := `{"network":"mainnet","pubkey":"0xaa","tag":"solo","status":"active","entries":[]}` + "\n\n" + `{"network":"mainnet","pubkey":"0xbb"}` + "\n"
+
+	count, err := ImportSignatures(context.Background(), target, strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("Expected an error on line 3, got: %v", err)
+	}
+	if count != 1 || len(target.validators) != 1 {
+		t.Errorf("Expected the documents before the invalid line to be imported, got %d", count)
+	}
+}
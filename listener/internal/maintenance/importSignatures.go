@@ -0,0 +1,42 @@
+package maintenance
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxLineBytes fits the largest document mongo can store, 16MB, as extended JSON
+const maxLineBytes = 32 * 1024 * 1024
+
+// ImportSignatures reads the JSON lines written by ExportSignatures. Every document replaces the one of the same
+// network, pubkey and tag, or is inserted if there is none. Returns the number of documents imported
+func ImportSignatures(ctx context.Context, signatureStore store.SignatureStore, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	count := 0
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var validator store.Validator
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &validator); err != nil {
+			return count, fmt.Errorf("invalid document on line %d: %w", lineNumber, err)
+		}
+		if validator.Network == "" || validator.Pubkey == "" || validator.Tag == "" {
+			return count, fmt.Errorf("document on line %d has no network, pubkey or tag", lineNumber)
+		}
+		if err := signatureStore.ImportValidator(ctx, validator); err != nil {
+			return count, fmt.Errorf("failed to import document on line %d: %w", lineNumber, err)
+		}
+		count++
+	}
+	return count, scanner.Err()
+}
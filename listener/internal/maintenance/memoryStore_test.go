@@ -0,0 +1,69 @@
+package maintenance
+
+import (
+	"context"
+	"slices"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// memoryStore keeps the validators in memory, in insertion order. It implements the operations used by the
+// maintenance commands, the embedded interface panics on any other one
+type memoryStore struct {
+	store.SignatureStore
+	validators []store.Validator
+}
+
+func (s *memoryStore) find(key store.ValidatorKey) int {
+	return slices.IndexFunc(s.validators, func(validator store.Validator) bool { return validator.ValidatorKey == key })
+}
+
+func (s *memoryStore) ForEachValidator(ctx context.Context, filter store.Filter, fn func(store.Validator) error) error {
+	for _, validator := range slices.Clone(s.validators) {
+		if filter.Networks != nil && !slices.Contains(filter.Networks, string(validator.Network)) {
+			continue
+		}
+		if filter.Tags != nil && !slices.Contains(filter.Tags, string(validator.Tag)) {
+			continue
+		}
+		validator.Entries = slices.Clone(validator.Entries)
+		if err := fn(validator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) ImportValidator(ctx context.Context, validator store.Validator) error {
+	validator.ID = nil
+	if i := s.find(validator.ValidatorKey); i >= 0 {
+		s.validators[i] = validator
+		return nil
+	}
+	s.validators = append(s.validators, validator)
+	return nil
+}
+
+func (s *memoryStore) UpdateStatus(ctx context.Context, key store.ValidatorKey, status types.Status, onlyIf types.Status) error {
+	if i := s.find(key); i >= 0 && (onlyIf == "" || s.validators[i].Status == onlyIf) {
+		s.validators[i].Status = status
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteValidator(ctx context.Context, key store.ValidatorKey, onlyIf types.Status) error {
+	if i := s.find(key); i >= 0 && (onlyIf == "" || s.validators[i].Status == onlyIf) {
+		s.validators = slices.Delete(s.validators, i, i+1)
+	}
+	return nil
+}
+
+func (s *memoryStore) RemoveEntries(ctx context.Context, key store.ValidatorKey, signatures []string) error {
+	if i := s.find(key); i >= 0 {
+		s.validators[i].Entries = slices.DeleteFunc(s.validators[i].Entries, func(entry store.Entry) bool {
+			return slices.Contains(signatures, entry.Signature)
+		})
+	}
+	return nil
+}
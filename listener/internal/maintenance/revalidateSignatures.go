@@ -0,0 +1,150 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/api/validation"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+)
+
+// statusBatchSize is the max number of pubkeys asked to the beacon node at once
+const statusBatchSize = 500
+
+// RevalidateResult counts what RevalidateSignatures found, and removed unless it was a dry run
+type RevalidateResult struct {
+	Documents          int
+	InvalidEntries     int
+	RemovedDocuments   int
+	ActivatedDocuments int
+}
+
+// RevalidateSignatures verifies again the signature of every stored entry of the networks, and refreshes the status of
+// every validator from the beacon nodes. Entries with an invalid signature are removed. As the updateSignaturesStatus
+// cron does, validators with status unknown are set active or removed if inactive. Validators already active are only
+// removed when they became inactive if removeInactive is set. With dryRun nothing is modified, only counted.
+// beaconNodeTimeout is how long every beacon node has to answer.
+func RevalidateSignatures(ctx context.Context, signatureStore store.SignatureStore, beaconNodeUrls map[types.Network][]string, beaconNodeTimeout time.Duration, removeInactive bool, dryRun bool) (RevalidateResult, error) {
+	var result RevalidateResult
+	for network, urls := range beaconNodeUrls {
+		logger.Info(fmt.Sprintf("Revalidating signatures of network %s", network))
+		if err := revalidateNetwork(ctx, signatureStore, network, urls, beaconNodeTimeout, removeInactive, dryRun, &result); err != nil {
+			return result, fmt.Errorf("failed to revalidate network %s: %w", network, err)
+		}
+	}
+	return result, nil
+}
+
+func revalidateNetwork(ctx context.Context, signatureStore store.SignatureStore, network types.Network, beaconNodeUrls []string, beaconNodeTimeout time.Duration, removeInactive bool, dryRun bool, result *RevalidateResult) error {
+	// validators with valid entries left, their status is refreshed afterwards
+	remaining := []store.Validator{}
+	err := signatureStore.ForEachValidator(ctx, store.Filter{Networks: []string{string(network)}}, func(validator store.Validator) error {
+		result.Documents++
+
+		invalidSignatures := getInvalidSignatures(validator)
+		result.InvalidEntries += len(invalidSignatures)
+		if len(invalidSignatures) == len(validator.Entries) {
+			result.RemovedDocuments++
+			if !dryRun {
+				return signatureStore.DeleteValidator(ctx, validator.ValidatorKey, "")
+			}
+			return nil
+		}
+		if len(invalidSignatures) > 0 && !dryRun {
+			if err := signatureStore.RemoveEntries(ctx, validator.ValidatorKey, invalidSignatures); err != nil {
+				return err
+			}
+		}
+		// only the key and status are needed from now on
+		validator.Entries = nil
+		remaining = append(remaining, validator)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(remaining); start += statusBatchSize {
+		end := min(start+statusBatchSize, len(remaining))
+		if err := refreshStatuses(ctx, signatureStore, remaining[start:end], beaconNodeUrls, beaconNodeTimeout, removeInactive, dryRun, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getInvalidSignatures returns the signatures of the entries that can not be decoded or verified
+func getInvalidSignatures(validator store.Validator) []string {
+	invalidSignatures := []string{}
+	requests := []types.SignatureRequestDecoded{}
+	for _, entry := range validator.Entries {
+		decodedPayload, err := validation.DecodePayload(entry.Payload)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Invalid payload of pubkey %s: %v", validator.Pubkey, err))
+			invalidSignatures = append(invalidSignatures, entry.Signature)
+			continue
+		}
+		requests = append(requests, types.SignatureRequestDecoded{
+			DecodedPayload: decodedPayload,
+			SignatureRequest: types.SignatureRequest{
+				Payload:   entry.Payload,
+				Pubkey:    validator.Pubkey,
+				Signature: entry.Signature,
+				Tag:       validator.Tag,
+			},
+		})
+	}
+	for i, valid := range validation.VerifySignatures(requests) {
+		if !valid {
+			logger.Warn("Invalid signature of pubkey " + validator.Pubkey + ": " + requests[i].Signature)
+			invalidSignatures = append(invalidSignatures, requests[i].Signature)
+		}
+	}
+	return invalidSignatures
+}
+
+// refreshStatuses asks the beacon nodes the status of the validators. Inactive validators with status unknown are
+// removed, as the updateSignaturesStatus cron does, and the active ones too if removeInactive is set. Validators the
+// beacon nodes return as unknown are kept as they are since the beacon nodes are down
+func refreshStatuses(ctx context.Context, signatureStore store.SignatureStore, validators []store.Validator, beaconNodeUrls []string, beaconNodeTimeout time.Duration, removeInactive bool, dryRun bool, result *RevalidateResult) error {
+	pubkeys := make([]string, len(validators))
+	for i, validator := range validators {
+		pubkeys[i] = validator.Pubkey
+	}
+	statusMap, err := validation.GetValidatorsStatus(ctx, pubkeys, beaconNodeUrls, beaconNodeTimeout)
+	if err != nil {
+		return err
+	}
+
+	for _, validator := range validators {
+		switch statusMap[validator.Pubkey] {
+		case types.Inactive:
+			onlyIf := types.Unknown
+			if removeInactive {
+				onlyIf = ""
+			} else if validator.Status != types.Unknown {
+				continue
+			}
+			result.RemovedDocuments++
+			if !dryRun {
+				if err := signatureStore.DeleteValidator(ctx, validator.ValidatorKey, onlyIf); err != nil {
+					return err
+				}
+			}
+		case types.Active:
+			if validator.Status == types.Active {
+				continue
+			}
+			result.ActivatedDocuments++
+			if !dryRun {
+				if err := signatureStore.UpdateStatus(ctx, validator.ValidatorKey, types.Active, ""); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
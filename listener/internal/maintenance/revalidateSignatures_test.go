@@ -0,0 +1,136 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// newSignedValidator returns a validator of mainnet with an entry signed by a new key for every timestamp
+func newSignedValidator(t *testing.T, status types.Status, timestamps ...int) store.Validator {
+	var secretKey bls.SecretKey
+	secretKey.SetByCSPRNG()
+	validator := store.Validator{
+		ValidatorKey: store.ValidatorKey{Network: types.Mainnet, Pubkey: "0x" + secretKey.GetPublicKey().SerializeToHexStr(), Tag: types.Solo},
+		Status:       status,
+	}
+	for _, timestamp := range timestamps {
+		payload := []byte(fmt.Sprintf(`{"type":"PROOF_OF_VALIDATION","platform":"dappnode","timestamp":"%d"}`, timestamp))
+		validator.Entries = append(validator.Entries, store.Entry{
+			Payload:   base64.StdEncoding.EncodeToString(payload),
+			Signature: "0x" + secretKey.SignByte(payload).SerializeToHexStr(),
+		})
+	}
+	return validator
+}
+
+// newBeaconNode serves the validators endpoint, with the pubkeys given as active
+func newBeaconNode(t *testing.T, activePubkeys ...string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Ids []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		response := map[string][]map[string]interface{}{"data": {}}
+		for _, pubkey := range request.Ids {
+			if slices.Contains(activePubkeys, pubkey) {
+				response["data"] = append(response["data"], map[string]interface{}{"validator": map[string]string{"pubkey": pubkey}})
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRevalidateSignatures(t *testing.T) {
+	if err := bls.Init(bls.BLS12_381); err != nil {
+		t.Fatalf("Failed to initialize BLS: %v", err)
+	}
+	if err := bls.SetETHmode(bls.EthModeDraft07); err != nil {
+		t.Fatalf("Failed to set BLS ETH mode: %v", err)
+	}
+
+	unknownNowActive := newSignedValidator(t, types.Unknown, 1)
+	unknownInactive := newSignedValidator(t, types.Unknown, 1)
+	activeNowInactive := newSignedValidator(t, types.Active, 1)
+	// the second entry has the signature of another validator
+	oneInvalidEntry := newSignedValidator(t, types.Active, 1, 2)
+	oneInvalidEntry.Entries[1].Signature = unknownInactive.Entries[0].Signature
+	allInvalidEntries := newSignedValidator(t, types.Active, 1)
+	allInvalidEntries.Entries[0].Signature = unknownInactive.Entries[0].Signature
+
+	beaconNodeUrls := map[types.Network][]string{types.Mainnet: {newBeaconNode(t, unknownNowActive.Pubkey, oneInvalidEntry.Pubkey, allInvalidEntries.Pubkey)}}
+
+	testCases := []struct {
+		description    string
+		removeInactive bool
+		dryRun         bool
+		expectedResult RevalidateResult
+		expectedKept   []string
+	}{
+		{
+			description:    "Default keeps the active validators that became inactive",
+			expectedResult: RevalidateResult{Documents: 5, InvalidEntries: 2, RemovedDocuments: 2, ActivatedDocuments: 1},
+			expectedKept:   []string{unknownNowActive.Pubkey, activeNowInactive.Pubkey, oneInvalidEntry.Pubkey},
+		},
+		{
+			description:    "Remove inactive",
+			removeInactive: true,
+			expectedResult: RevalidateResult{Documents: 5, InvalidEntries: 2, RemovedDocuments: 3, ActivatedDocuments: 1},
+			expectedKept:   []string{unknownNowActive.Pubkey, oneInvalidEntry.Pubkey},
+		},
+		{
+			description:    "Dry run",
+			removeInactive: true,
+			dryRun:         true,
+			expectedResult: RevalidateResult{Documents: 5, InvalidEntries: 2, RemovedDocuments: 3, ActivatedDocuments: 1},
+			expectedKept:   []string{unknownNowActive.Pubkey, unknownInactive.Pubkey, activeNowInactive.Pubkey, oneInvalidEntry.Pubkey, allInvalidEntries.Pubkey},
+		},
+	}
+	for _, tc := range testCases {
+		signatureStore := &memoryStore{}
+		for _, validator := range []store.Validator{unknownNowActive, unknownInactive, activeNowInactive, oneInvalidEntry, allInvalidEntries} {
+			validator.Entries = slices.Clone(validator.Entries)
+			signatureStore.validators = append(signatureStore.validators, validator)
+		}
+
+		result, err := RevalidateSignatures(context.Background(), signatureStore, beaconNodeUrls, time.Second, tc.removeInactive, tc.dryRun)
+		if err != nil {
+			t.Fatalf("%s: RevalidateSignatures returned an error: %v", tc.description, err)
+		}
+		if result != tc.expectedResult {
+			t.Errorf("%s: expected result %+v, got %+v", tc.description, tc.expectedResult, result)
+		}
+
+		kept := []string{}
+		for _, validator := range signatureStore.validators {
+			kept = append(kept, validator.Pubkey)
+		}
+		if !slices.Equal(kept, tc.expectedKept) {
+			t.Errorf("%s: expected validators %v to be kept, got %v", tc.description, tc.expectedKept, kept)
+		}
+		if tc.dryRun {
+			continue
+		}
+		if i := signatureStore.find(unknownNowActive.ValidatorKey); signatureStore.validators[i].Status != types.Active {
+			t.Errorf("%s: expected the validator to be set active", tc.description)
+		}
+		if i := signatureStore.find(oneInvalidEntry.ValidatorKey); len(signatureStore.validators[i].Entries) != 1 {
+			t.Errorf("%s: expected the invalid entry to be removed, got %d entries", tc.description, len(signatureStore.validators[i].Entries))
+		}
+	}
+}
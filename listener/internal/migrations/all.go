@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All are the migrations in order. Never change or remove an applied migration, add a new one
+var All = []Migration{
+	{
+		Version:     1,
+		Description: "set status unknown on documents stored before the status existed",
+		Up: func(ctx context.Context, signatures *mongo.Collection, settings Settings) error {
+			_, err := signatures.UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"status": types.Unknown}})
+			return err
+		},
+		// the documents that had no status can not be told apart, and every listener understands the status
+		Down: func(ctx context.Context, signatures *mongo.Collection, settings Settings) error { return nil },
+	},
+	{
+		Version:     2,
+		Description: "merge the duplicated documents of the same network, pubkey and tag, required by the unique index",
+		Up:          mergeDuplicatedDocuments,
+		// the merged documents can not be split back, and duplicates were never intended
+		Down: nil,
+	},
+	{
+		Version:     3,
+		Description: "move the entries to the time-series collection",
+		StorageMode: store.ModeTimeSeries,
+		Up: func(ctx context.Context, signatures *mongo.Collection, settings Settings) error {
+			migrated, err := store.MigrateToTimeSeries(ctx, signatures, settings.Retention)
+			logger.Info(fmt.Sprintf("Moved the entries of %d documents to the time-series collection", migrated))
+			return err
+		},
+		Down: func(ctx context.Context, signatures *mongo.Collection, settings Settings) error {
+			migrated, err := store.MigrateToEmbedded(ctx, signatures, settings.MaxEntriesPerBson)
+			logger.Info(fmt.Sprintf("Moved the entries of %d documents back into the documents", migrated))
+			return err
+		},
+	},
+}
+
+// mergeDuplicatedDocuments keeps one document per network, pubkey and tag with the entries of all of them, up to
+// MaxEntriesPerBson keeping the newest. The status is active if any of them was active, since that status is only set
+// after checking the beacon node
+func mergeDuplicatedDocuments(ctx context.Context, signatures *mongo.Collection, settings Settings) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"network": "$network", "pubkey": "$pubkey", "tag": "$tag"},
+			"ids":      bson.M{"$push": "$_id"},
+			"entries":  bson.M{"$push": "$entries"},
+			"statuses": bson.M{"$addToSet": "$status"},
+			"count":    bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+	cursor, err := signatures.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var duplicated struct {
+			Ids      []interface{}  `bson:"ids"`
+			Entries  [][]bson.M     `bson:"entries"`
+			Statuses []types.Status `bson:"statuses"`
+		}
+		if err := cursor.Decode(&duplicated); err != nil {
+			return err
+		}
+
+		entries := []bson.M{}
+		for _, documentEntries := range duplicated.Entries {
+			entries = append(entries, documentEntries...)
+		}
+		entries = newestEntries(entries, settings.MaxEntriesPerBson)
+		status := types.Unknown
+		for _, documentStatus := range duplicated.Statuses {
+			if documentStatus == types.Active {
+				status = types.Active
+			}
+		}
+
+		keep := duplicated.Ids[0]
+		if _, err := signatures.UpdateOne(ctx, bson.M{"_id": keep}, bson.M{"$set": bson.M{"entries": entries, "status": status}}); err != nil {
+			return err
+		}
+		if _, err := signatures.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicated.Ids[1:]}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// newestEntries returns the max newest entries, oldest first as they are pushed. Entries without a valid payload
+// timestamp are the first to go. A max of 0 keeps every entry
+func newestEntries(entries []bson.M, max int) []bson.M {
+	if max <= 0 || len(entries) <= max {
+		return entries
+	}
+	slices.SortStableFunc(entries, func(a, b bson.M) int {
+		return cmp.Compare(entryTimestamp(a), entryTimestamp(b))
+	})
+	return entries[len(entries)-max:]
+}
+
+// entryTimestamp returns the payload timestamp of the entry, 0 if it is not a valid one
+func entryTimestamp(entry bson.M) int64 {
+	decodedPayload, _ := entry["decodedPayload"].(bson.M)
+	timestamp, _ := decodedPayload["timestamp"].(string)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seconds
+}
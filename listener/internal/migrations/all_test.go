@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func newTestEntry(signature string, timestamp string) bson.M {
+	return bson.M{"signature": signature, "decodedPayload": bson.M{"timestamp": timestamp}}
+}
+
+func signaturesOf(entries []bson.M) []string {
+	signatures := []string{}
+	for _, entry := range entries {
+		signatures = append(signatures, entry["signature"].(string))
+	}
+	return signatures
+}
+
+func TestNewestEntries(t *testing.T) {
+	entries := []bson.M{
+		newTestEntry("0x03", "300"),
+		newTestEntry("0x01", "100"),
+		newTestEntry("0xbad", "not-a-timestamp"),
+		newTestEntry("0x04", "400"),
+		newTestEntry("0x02", "200"),
+	}
+	if signatures := fmt.Sprint(signaturesOf(newestEntries(entries, 3))); signatures != "[0x02 0x03 0x04]" {
+		t.Errorf("Expected the 3 newest entries oldest first, got %s", signatures)
+	}
+	if kept := newestEntries(entries[:2], 3); len(kept) != 2 {
+		t.Errorf("Expected every entry under the limit to be kept, got %d", len(kept))
+	}
+}
+
+// testCollection returns the signatures collection of a new database of the MongoDB server of MONGO_TEST_URI, the
+// test is skipped without it
+func testCollection(t *testing.T) *mongo.Collection {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("No test database, set MONGO_TEST_URI")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+	db := client.Database(fmt.Sprintf("migrationsTest_%d", time.Now().UnixNano()))
+	t.Cleanup(func() { db.Drop(ctx) })
+	return db.Collection("signatures")
+}
+
+func TestMergeDuplicatedDocuments(t *testing.T) {
+	ctx := context.Background()
+	signatures := testCollection(t)
+
+	key := bson.M{"network": "mainnet", "pubkey": "0x01", "tag": "solo"}
+	documents := []interface{}{
+		bson.M{"network": "mainnet", "pubkey": "0x01", "tag": "solo", "status": types.Unknown, "entries": []bson.M{newTestEntry("0xa1", "100"), newTestEntry("0xa4", "400")}},
+		bson.M{"network": "mainnet", "pubkey": "0x01", "tag": "solo", "status": types.Active, "entries": []bson.M{newTestEntry("0xa3", "300"), newTestEntry("0xa2", "200")}},
+		bson.M{"network": "mainnet", "pubkey": "0x02", "tag": "solo", "status": types.Unknown, "entries": []bson.M{newTestEntry("0xb1", "100")}},
+	}
+	if _, err := signatures.InsertMany(ctx, documents); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+
+	if err := mergeDuplicatedDocuments(ctx, signatures, Settings{MaxEntriesPerBson: 3}); err != nil {
+		t.Fatalf("mergeDuplicatedDocuments returned an error: %v", err)
+	}
+
+	if count, _ := signatures.CountDocuments(ctx, key); count != 1 {
+		t.Fatalf("Expected 1 document of the duplicated key, got %d", count)
+	}
+	var merged struct {
+		Status  types.Status `bson:"status"`
+		Entries []bson.M     `bson:"entries"`
+	}
+	if err := signatures.FindOne(ctx, key).Decode(&merged); err != nil {
+		t.Fatalf("Failed to read the merged document: %v", err)
+	}
+	if merged.Status != types.Active {
+		t.Errorf("Expected the merged document to be active, got %s", merged.Status)
+	}
+	if entries := fmt.Sprint(signaturesOf(merged.Entries)); entries != "[0xa2 0xa3 0xa4]" {
+		t.Errorf("Expected the 3 newest entries, got %s", entries)
+	}
+	if count, _ := signatures.CountDocuments(ctx, bson.M{}); count != 2 {
+		t.Errorf("Expected the other documents to be kept, got %d documents", count)
+	}
+}
+
+func TestTimeSeriesMigration(t *testing.T) {
+	ctx := context.Background()
+	signatures := testCollection(t)
+	embedded := Settings{StorageMode: store.ModeEmbedded, MaxEntriesPerBson: 30, Retention: 720 * time.Hour}
+	timeSeries := Settings{StorageMode: store.ModeTimeSeries, MaxEntriesPerBson: 30, Retention: 720 * time.Hour}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	document := bson.M{"network": "mainnet", "pubkey": "0x01", "tag": "solo", "status": types.Active, "entries": []bson.M{newTestEntry("0xa1", now), newTestEntry("0xa2", now)}}
+	if _, err := signatures.InsertOne(ctx, document); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	// an existing embedded database, with the migrations of every mode applied
+	if _, err := Up(ctx, signatures, embedded, 0); err != nil {
+		t.Fatalf("Failed to apply the migrations: %v", err)
+	}
+	if err := CheckSchema(ctx, signatures, embedded); err != nil {
+		t.Errorf("Expected the embedded schema to be up to date, got: %v", err)
+	}
+	if err := CheckSchema(ctx, signatures, timeSeries); err == nil {
+		t.Errorf("Expected the time-series migration to be pending")
+	}
+
+	applied, err := Up(ctx, signatures, timeSeries, 0)
+	if err != nil || fmt.Sprint(applied) != "[3]" {
+		t.Fatalf("Expected the time-series migration to be applied, got %v: %v", applied, err)
+	}
+	if err := CheckSchema(ctx, signatures, embedded); err == nil {
+		t.Errorf("Expected the embedded mode to be refused once the time-series migration is applied")
+	}
+	if count, _ := signatures.CountDocuments(ctx, bson.M{"entries": bson.M{"$exists": true}}); count != 0 {
+		t.Errorf("Expected the entries to be moved out of the documents, %d documents still have them", count)
+	}
+
+	rolledBack, err := Down(ctx, signatures, timeSeries, 2)
+	if err != nil || fmt.Sprint(rolledBack) != "[3]" {
+		t.Fatalf("Expected the time-series migration to be rolled back, got %v: %v", rolledBack, err)
+	}
+	var restored struct {
+		Entries []bson.M `bson:"entries"`
+	}
+	if err := signatures.FindOne(ctx, bson.M{"pubkey": "0x01"}).Decode(&restored); err != nil {
+		t.Fatalf("Failed to read the document: %v", err)
+	}
+	if entries := fmt.Sprint(signaturesOf(restored.Entries)); entries != "[0xa1 0xa2]" {
+		t.Errorf("Expected the entries back in the document, got %s", entries)
+	}
+	if err := CheckSchema(ctx, signatures, embedded); err != nil {
+		t.Errorf("Expected the embedded schema to be up to date after the rollback, got: %v", err)
+	}
+}
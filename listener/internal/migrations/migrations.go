@@ -0,0 +1,318 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName is the collection where the applied migrations are recorded, in the database of the signatures
+const CollectionName = "schema_migrations"
+
+// lockID is the document of CollectionName held while migrating, so two listeners never migrate at once
+const lockID = "lock"
+
+// lockTTL is how long the lock of a crashed listener blocks the others. The holder renews it while migrating
+const lockTTL = time.Minute
+
+// Migration changes the shape of the stored documents. Migrations are applied in order of version and must be
+// idempotent, a migration interrupted before being recorded is run again.
+type Migration struct {
+	Version     int
+	Description string
+	// StorageMode restricts the migration to a storage mode, see Settings.StorageMode. Empty for every mode
+	StorageMode string
+	Up          func(ctx context.Context, signatures *mongo.Collection, settings Settings) error
+	// Down reverts Up. nil means the migration can not be rolled back
+	Down func(ctx context.Context, signatures *mongo.Collection, settings Settings) error
+}
+
+// Settings are the settings of the listener the migrations depend on
+type Settings struct {
+	// StorageMode is the storage mode of the listener, only the migrations of every mode and of this one are applied
+	StorageMode string
+	// MaxEntriesPerBson is the max number of entries of a validator document
+	MaxEntriesPerBson int
+	// Retention is how long entries are kept
+	Retention time.Duration
+}
+
+// appliesTo returns whether the migration is applied in the storage mode of the settings
+func (m Migration) appliesTo(settings Settings) bool {
+	return m.StorageMode == "" || m.StorageMode == settings.StorageMode
+}
+
+// MigrationStatus is a migration and whether it has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type migrationsLock struct {
+	ID        string    `bson:"_id"`
+	Holder    string    `bson:"holder"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// Status returns every migration known by the listener and whether it has been applied
+func Status(ctx context.Context, signatures *mongo.Collection) ([]MigrationStatus, error) {
+	applied, err := getApplied(ctx, signatures)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(All))
+	for i, migration := range All {
+		record, ok := applied[migration.Version]
+		statuses[i] = MigrationStatus{Migration: migration, Applied: ok, AppliedAt: record.AppliedAt}
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations of the storage mode up to the target version, every one if target is 0. Returns the
+// versions applied
+func Up(ctx context.Context, signatures *mongo.Collection, settings Settings, target int) ([]int, error) {
+	unlock, err := lock(ctx, signatures)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	applied, err := getApplied(ctx, signatures)
+	if err != nil {
+		return nil, err
+	}
+	done := []int{}
+	for _, migration := range All {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok || !migration.appliesTo(settings) {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Applying migration %d: %s", migration.Version, migration.Description))
+		if err := migration.Up(ctx, signatures, settings); err != nil {
+			return done, fmt.Errorf("migration %d failed: %w", migration.Version, err)
+		}
+		record := appliedMigration{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now().UTC()}
+		if _, err := migrationsCollection(signatures).InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return done, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// Down rolls back the applied migrations above the target version, newest first, whatever their storage mode. Returns
+// the versions rolled back
+func Down(ctx context.Context, signatures *mongo.Collection, settings Settings, target int) ([]int, error) {
+	unlock, err := lock(ctx, signatures)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	applied, err := getApplied(ctx, signatures)
+	if err != nil {
+		return nil, err
+	}
+	done := []int{}
+	for i := len(All) - 1; i >= 0; i-- {
+		migration := All[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("migration %d (%s) can not be rolled back", migration.Version, migration.Description)
+		}
+		logger.Info(fmt.Sprintf("Rolling back migration %d: %s", migration.Version, migration.Description))
+		if err := migration.Down(ctx, signatures, settings); err != nil {
+			return done, fmt.Errorf("rollback of migration %d failed: %w", migration.Version, err)
+		}
+		if _, err := migrationsCollection(signatures).DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return done, fmt.Errorf("failed to unrecord migration %d: %w", migration.Version, err)
+		}
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// CheckSchema returns an error if any migration of the storage mode is pending, or a migration of another storage mode
+// is applied, the listener must not serve documents it does not understand. A new database, without signatures nor
+// migrations, is already on the latest schema, so every migration of the storage mode is recorded as applied.
+func CheckSchema(ctx context.Context, signatures *mongo.Collection, settings Settings) error {
+	applied, err := getApplied(ctx, signatures)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		count, err := signatures.CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			logger.Info("New database, recording every migration as applied")
+			return baseline(ctx, signatures, settings)
+		}
+	}
+
+	pending := []string{}
+	for _, migration := range All {
+		_, ok := applied[migration.Version]
+		if ok && !migration.appliesTo(settings) {
+			return fmt.Errorf("migration %d (%s) of the %s storage mode is applied, set storageMode (STORAGE_MODE) to %s or run `listener migrate down --to %d`",
+				migration.Version, migration.Description, migration.StorageMode, migration.StorageMode, migration.Version-1)
+		}
+		if !ok && migration.appliesTo(settings) {
+			pending = append(pending, fmt.Sprintf("%d (%s)", migration.Version, migration.Description))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("the database schema is behind, pending migrations: %v. Run `listener migrate up`", pending)
+	}
+
+	// a newer listener may have migrated the database, this one may not understand all the documents
+	latest := All[len(All)-1].Version
+	for version := range applied {
+		if version > latest {
+			logger.Warn(fmt.Sprintf("The database has migration %d applied, newer than the latest known by this listener (%d)", version, latest))
+		}
+	}
+	return nil
+}
+
+func baseline(ctx context.Context, signatures *mongo.Collection, settings Settings) error {
+	for _, migration := range All {
+		if !migration.appliesTo(settings) {
+			continue
+		}
+		record := appliedMigration{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now().UTC()}
+		if _, err := migrationsCollection(signatures).InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func getApplied(ctx context.Context, signatures *mongo.Collection) (map[int]appliedMigration, error) {
+	cursor, err := migrationsCollection(signatures).Find(ctx, bson.M{"_id": bson.M{"$ne": lockID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	applied := make(map[int]appliedMigration)
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// lock takes the migrations lock, or fails if another listener holds it. The lock is renewed in the background until
+// unlock is called
+func lock(ctx context.Context, signatures *mongo.Collection) (unlock func(), err error) {
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+	if err := tryLock(ctx, signatures, holder); err != nil {
+		return nil, err
+	}
+
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				if err := tryLock(renewCtx, signatures, holder); err != nil && renewCtx.Err() == nil {
+					logger.Warn("Failed to renew the migrations lock: " + err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() {
+		stopRenewing()
+		<-renewed
+		// released even if the migration was cancelled, otherwise the next one waits for the lock to expire
+		if _, err := migrationsCollection(signatures).DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": lockID, "holder": holder}); err != nil {
+			logger.Warn("Failed to release the migrations lock: " + err.Error())
+		}
+	}, nil
+}
+
+// tryLock takes or renews the migrations lock for holder, same as the cron leases
+func tryLock(ctx context.Context, signatures *mongo.Collection, holder string) error {
+	now := time.Now()
+	// matches the lock only if it can be taken, otherwise the upsert tries to insert a second lock and fails with a
+	// duplicate key error
+	filter := bson.M{
+		"_id": lockID,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": holder, "expiresAt": now.Add(lockTTL)}}
+	_, err := migrationsCollection(signatures).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		var current migrationsLock
+		if err := migrationsCollection(signatures).FindOne(ctx, bson.M{"_id": lockID}).Decode(&current); err != nil {
+			return errors.New("another listener is migrating the database")
+		}
+		return fmt.Errorf("another listener (%s) is migrating the database, its lock expires at %s unless renewed", current.Holder, current.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to take the migrations lock: %w", err)
+	}
+	return nil
+}
+
+func migrationsCollection(signatures *mongo.Collection) *mongo.Collection {
+	return signatures.Database().Collection(CollectionName)
+}
+
+// validateMigrations checks the versions are positive and strictly increasing
+func validateMigrations(migrations []Migration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return fmt.Errorf("migration %d is not after migration %d", migration.Version, previous)
+		}
+		if migration.Up == nil {
+			return fmt.Errorf("migration %d has no Up", migration.Version)
+		}
+		previous = migration.Version
+	}
+	if len(migrations) == 0 {
+		return errors.New("no migrations")
+	}
+	return nil
+}
+
+func init() {
+	if err := validateMigrations(All); err != nil {
+		panic("invalid migrations: " + err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestValidateMigrations(t *testing.T) {
+	up := func(ctx context.Context, signatures *mongo.Collection, settings Settings) error { return nil }
+
+	if err := validateMigrations(All); err != nil {
+		t.Errorf("Expected the migrations to be valid, got: %v", err)
+	}
+	if err := validateMigrations([]Migration{{Version: 1, Up: up}, {Version: 1, Up: up}}); err == nil {
+		t.Errorf("Expected an error for duplicated versions")
+	}
+	if err := validateMigrations([]Migration{{Version: 2, Up: up}, {Version: 1, Up: up}}); err == nil {
+		t.Errorf("Expected an error for unordered versions")
+	}
+	if err := validateMigrations([]Migration{{Version: 1}}); err == nil {
+		t.Errorf("Expected an error for a migration without Up")
+	}
+}
+
+func TestValidatePostgresMigrations(t *testing.T) {
+	if err := validatePostgresMigrations(PostgresAll); err != nil {
+		t.Errorf("Expected the migrations to be valid, got: %v", err)
+	}
+	if err := validatePostgresMigrations([]PostgresMigration{{Version: 2, Up: "SELECT 1"}, {Version: 1, Up: "SELECT 1"}}); err == nil {
+		t.Errorf("Expected an error for unordered versions")
+	}
+	if err := validatePostgresMigrations([]PostgresMigration{{Version: 1}}); err == nil {
+		t.Errorf("Expected an error for a migration without Up")
+	}
+}
+
+func TestMigrationsLock(t *testing.T) {
+	signatures := testCollection(t)
+	ctx := context.Background()
+	settings := Settings{StorageMode: store.ModeEmbedded, MaxEntriesPerBson: 30, Retention: 720 * time.Hour}
+
+	unlock, err := lock(ctx, signatures)
+	if err != nil {
+		t.Fatalf("Failed to take the migrations lock: %v", err)
+	}
+	// another listener can neither migrate nor roll back while the lock is held
+	if _, err := Up(ctx, signatures, settings, 0); err == nil || !strings.Contains(err.Error(), "another listener") {
+		t.Errorf("Expected up to be refused while the lock is held, got %v", err)
+	}
+	if _, err := Down(ctx, signatures, settings, 0); err == nil || !strings.Contains(err.Error(), "another listener") {
+		t.Errorf("Expected down to be refused while the lock is held, got %v", err)
+	}
+	// the lock is not a migration
+	if statuses, err := Status(ctx, signatures); err != nil || statuses[0].Applied {
+		t.Errorf("Expected no migration to be applied, got %v, %v", statuses, err)
+	}
+
+	// once released, the lock can be taken again
+	unlock()
+	if _, err := Up(ctx, signatures, settings, 0); err != nil {
+		t.Fatalf("Expected up to run once the lock is released, got %v", err)
+	}
+
+	// the lock of a crashed listener is taken over once expired
+	lockDocument := bson.M{"_id": lockID, "holder": "crashed", "expiresAt": time.Now().Add(-time.Second)}
+	if _, err := migrationsCollection(signatures).InsertOne(ctx, lockDocument); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Down(ctx, signatures, settings, 0); err != nil && strings.Contains(err.Error(), "another listener") {
+		t.Errorf("Expected the expired lock to be taken over, got %v", err)
+	}
+	if count, err := migrationsCollection(signatures).CountDocuments(ctx, bson.M{"_id": lockID}); err != nil || count != 0 {
+		t.Errorf("Expected the lock to be released after down, got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,283 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresMigration changes the Postgres schema. Every migration runs in a transaction together with its record, so
+// it is applied completely or not at all
+type PostgresMigration struct {
+	Version     int
+	Description string
+	Up          string
+	// Down reverts Up. Empty means the migration can not be rolled back
+	Down string
+}
+
+// PostgresAll are the Postgres migrations in order. Never change or remove an applied migration, add a new one
+var PostgresAll = []PostgresMigration{
+	{
+		Version:     1,
+		Description: "create the validators and signature_entries tables",
+		Up: `
+CREATE TABLE validators (
+	id BIGSERIAL PRIMARY KEY,
+	network TEXT NOT NULL,
+	pubkey TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	status TEXT NOT NULL,
+	CONSTRAINT validators_network_pubkey_tag_unique UNIQUE (network, pubkey, tag)
+);
+CREATE INDEX validators_status ON validators (status);
+CREATE INDEX validators_tag ON validators (tag);
+
+CREATE TABLE signature_entries (
+	id BIGSERIAL PRIMARY KEY,
+	validator_id BIGINT NOT NULL REFERENCES validators (id) ON DELETE CASCADE,
+	received_at TIMESTAMPTZ NOT NULL,
+	payload TEXT NOT NULL,
+	signature TEXT NOT NULL,
+	decoded_payload JSONB NOT NULL,
+	submitter TEXT,
+	metadata JSONB
+);
+CREATE INDEX signature_entries_validator_received_at ON signature_entries (validator_id, received_at);
+CREATE INDEX signature_entries_received_at ON signature_entries (received_at);
+CREATE INDEX signature_entries_metadata ON signature_entries USING GIN (metadata jsonb_path_ops);
+`,
+		Down: `
+DROP TABLE signature_entries;
+DROP TABLE validators;
+`,
+	},
+	{
+		Version:     2,
+		Description: "create the cron_leases table",
+		Up: `
+CREATE TABLE cron_leases (
+	name TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+`,
+		Down: `
+DROP TABLE cron_leases;
+`,
+	},
+	{
+		Version:     3,
+		Description: "create the cron_runs table",
+		Up: `
+CREATE TABLE cron_runs (
+	name TEXT PRIMARY KEY,
+	trigger TEXT NOT NULL,
+	holder TEXT NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	counts JSONB NOT NULL,
+	error TEXT NOT NULL,
+	last_success_at TIMESTAMPTZ
+);
+`,
+		Down: `
+DROP TABLE cron_runs;
+`,
+	},
+}
+
+// postgresMigrationsLock is the advisory lock held while migrating, so two listeners never migrate at once
+const postgresMigrationsLock = 7305641
+
+// PostgresStatus returns every Postgres migration known by the listener and whether it has been applied
+func PostgresStatus(ctx context.Context, pool *pgxpool.Pool) ([]MigrationStatus, error) {
+	applied, err := getPostgresApplied(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(PostgresAll))
+	for i, migration := range PostgresAll {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = MigrationStatus{
+			Migration: Migration{Version: migration.Version, Description: migration.Description},
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		}
+	}
+	return statuses, nil
+}
+
+// PostgresUp applies the pending migrations up to the target version, every one if target is 0. Returns the versions
+// applied
+func PostgresUp(ctx context.Context, pool *pgxpool.Pool, target int) ([]int, error) {
+	applied, err := getPostgresApplied(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	done := []int{}
+	for _, migration := range PostgresAll {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Applying migration %d: %s", migration.Version, migration.Description))
+		if err := runPostgresMigration(ctx, pool, migration, true); err != nil {
+			return done, fmt.Errorf("migration %d failed: %w", migration.Version, err)
+		}
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// PostgresDown rolls back the applied migrations above the target version, newest first. Returns the versions rolled
+// back
+func PostgresDown(ctx context.Context, pool *pgxpool.Pool, target int) ([]int, error) {
+	applied, err := getPostgresApplied(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	done := []int{}
+	for i := len(PostgresAll) - 1; i >= 0; i-- {
+		migration := PostgresAll[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %d (%s) can not be rolled back", migration.Version, migration.Description)
+		}
+		logger.Info(fmt.Sprintf("Rolling back migration %d: %s", migration.Version, migration.Description))
+		if err := runPostgresMigration(ctx, pool, migration, false); err != nil {
+			return done, fmt.Errorf("rollback of migration %d failed: %w", migration.Version, err)
+		}
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// runPostgresMigration applies (up) or rolls back the migration and records it in a single transaction. A migration
+// applied or rolled back meanwhile by another listener is skipped
+func runPostgresMigration(ctx context.Context, pool *pgxpool.Pool, migration PostgresMigration, up bool) error {
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", postgresMigrationsLock); err != nil {
+			return err
+		}
+		var applied bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version).Scan(&applied); err != nil {
+			return err
+		}
+		if applied == up {
+			return nil
+		}
+
+		if !up {
+			if _, err := tx.Exec(ctx, migration.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			return err
+		}
+		if _, err := tx.Exec(ctx, migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, description, applied_at) VALUES ($1, $2, $3)", migration.Version, migration.Description, time.Now().UTC())
+		return err
+	})
+}
+
+// CheckPostgresSchema returns an error if any migration is pending. A new database, without migrations, gets every
+// migration applied, since they create the tables
+func CheckPostgresSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	applied, err := getPostgresApplied(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		logger.Info("New database, applying every migration")
+		_, err := PostgresUp(ctx, pool, 0)
+		return err
+	}
+
+	pending := []string{}
+	for _, migration := range PostgresAll {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%d (%s)", migration.Version, migration.Description))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("the database schema is behind, pending migrations: %v. Run `listener migrate up`", pending)
+	}
+
+	// a newer listener may have migrated the database, this one may not understand all the rows
+	latest := PostgresAll[len(PostgresAll)-1].Version
+	for version := range applied {
+		if version > latest {
+			logger.Warn(fmt.Sprintf("The database has migration %d applied, newer than the latest known by this listener (%d)", version, latest))
+		}
+	}
+	return nil
+}
+
+// getPostgresApplied returns the applied migrations and when they were applied, creating the table that records them
+// if it does not exist
+func getPostgresApplied(ctx context.Context, pool *pgxpool.Pool) (map[int]time.Time, error) {
+	_, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the schema_migrations table: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// validatePostgresMigrations checks the versions are positive and strictly increasing
+func validatePostgresMigrations(migrations []PostgresMigration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return fmt.Errorf("migration %d is not after migration %d", migration.Version, previous)
+		}
+		if migration.Up == "" {
+			return fmt.Errorf("migration %d has no Up", migration.Version)
+		}
+		previous = migration.Version
+	}
+	if len(migrations) == 0 {
+		return errors.New("no migrations")
+	}
+	return nil
+}
+
+func init() {
+	if err := validatePostgresMigrations(PostgresAll); err != nil {
+		panic("invalid postgres migrations: " + err.Error())
+	}
+}
@@ -0,0 +1,105 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Index is an index the queries of the listener rely on
+type Index struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+}
+
+// EnsureIndexes creates the required indexes that do not exist yet. Creating an index that already exists with the
+// same definition is a no-op
+func EnsureIndexes(ctx context.Context, collection *mongo.Collection, requiredIndexes []Index) error {
+	models := make([]mongo.IndexModel, len(requiredIndexes))
+	for i, index := range requiredIndexes {
+		indexOptions := options.Index().SetName(index.Name)
+		if index.Unique {
+			indexOptions.SetUnique(true)
+		}
+		models[i] = mongo.IndexModel{Keys: index.Keys, Options: indexOptions}
+	}
+	if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("failed to create indexes of %s: %w", collection.Name(), err)
+	}
+	logger.Info("MongoDB indexes of " + collection.Name() + " are in place")
+	return nil
+}
+
+// CheckIndexes returns the differences between the indexes of the collection and the required ones: missing indexes,
+// indexes with other keys or options, and indexes the listener does not know about
+func CheckIndexes(ctx context.Context, collection *mongo.Collection, requiredIndexes []Index) ([]string, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	var existing []struct {
+		Name   string `bson:"name"`
+		Key    bson.D `bson:"key"`
+		Unique bool   `bson:"unique"`
+	}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	drift := []string{}
+	required := make(map[string]bool)
+	for _, index := range requiredIndexes {
+		required[index.Name] = true
+		found := false
+		for _, current := range existing {
+			if current.Name != index.Name {
+				continue
+			}
+			found = true
+			if !sameKeys(current.Key, index.Keys) || current.Unique != index.Unique {
+				drift = append(drift, fmt.Sprintf("index %s of %s has keys %v unique %t, expected keys %v unique %t", index.Name, collection.Name(), current.Key, current.Unique, index.Keys, index.Unique))
+			}
+		}
+		if !found {
+			drift = append(drift, "index "+index.Name+" of "+collection.Name()+" is missing")
+		}
+	}
+	for _, current := range existing {
+		if current.Name != "_id_" && !required[current.Name] {
+			drift = append(drift, "index "+current.Name+" of "+collection.Name()+" is not managed by the listener")
+		}
+	}
+	return drift, nil
+}
+
+// sameKeys compares index keys ignoring the numeric type of the direction, mongo may return 1 as int32 or float64
+func sameKeys(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || !reflect.DeepEqual(toFloat(a[i].Value), toFloat(b[i].Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func toFloat(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,91 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSameKeys(t *testing.T) {
+	required := bson.D{{Key: "network", Value: 1}, {Key: "pubkey", Value: -1}}
+
+	for _, tc := range []struct {
+		description string
+		keys        bson.D
+		expected    bool
+	}{
+		{"Same keys as int32", bson.D{{Key: "network", Value: int32(1)}, {Key: "pubkey", Value: int32(-1)}}, true},
+		{"Same keys as float64", bson.D{{Key: "network", Value: 1.0}, {Key: "pubkey", Value: -1.0}}, true},
+		{"Other direction", bson.D{{Key: "network", Value: 1}, {Key: "pubkey", Value: 1}}, false},
+		{"Other order", bson.D{{Key: "pubkey", Value: -1}, {Key: "network", Value: 1}}, false},
+		{"Missing key", bson.D{{Key: "network", Value: 1}}, false},
+		{"Other index type", bson.D{{Key: "network", Value: "text"}, {Key: "pubkey", Value: -1}}, false},
+	} {
+		if sameKeys(tc.keys, required) != tc.expected {
+			t.Errorf("%s: expected sameKeys to return %t", tc.description, tc.expected)
+		}
+	}
+}
+
+// TestEnsureAndCheckIndexes runs against the MongoDB server of MONGO_TEST_URI, it is skipped without it
+func TestEnsureAndCheckIndexes(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("No test database, set MONGO_TEST_URI")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+	db := client.Database(fmt.Sprintf("indexesTest_%d", time.Now().UnixNano()))
+	t.Cleanup(func() { db.Drop(ctx) })
+	collection := db.Collection("signatures")
+
+	required := []Index{
+		{Name: "network_pubkey_unique", Keys: bson.D{{Key: "network", Value: 1}, {Key: "pubkey", Value: 1}}, Unique: true},
+		{Name: "status", Keys: bson.D{{Key: "status", Value: 1}}},
+	}
+	if err := EnsureIndexes(ctx, collection, required); err != nil {
+		t.Fatalf("EnsureIndexes returned an error: %v", err)
+	}
+	// a second run is a no-op
+	if err := EnsureIndexes(ctx, collection, required); err != nil {
+		t.Fatalf("EnsureIndexes returned an error on the second run: %v", err)
+	}
+	drift, err := CheckIndexes(ctx, collection, required)
+	if err != nil || len(drift) != 0 {
+		t.Fatalf("Expected no drift, got %v: %v", drift, err)
+	}
+
+	// an index dropped, another one changed and one created by hand are reported
+	if _, err := collection.Indexes().DropOne(ctx, "status"); err != nil {
+		t.Fatalf("Failed to drop index: %v", err)
+	}
+	if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "tag", Value: 1}}, Options: options.Index().SetName("tag")}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	changed := append([]Index{}, required...)
+	changed[0].Unique = false
+	drift, err = CheckIndexes(ctx, collection, changed)
+	if err != nil {
+		t.Fatalf("CheckIndexes returned an error: %v", err)
+	}
+	for _, expected := range []string{"network_pubkey_unique", "status of signatures is missing", "tag of signatures is not managed"} {
+		found := false
+		for _, difference := range drift {
+			found = found || strings.Contains(difference, expected)
+		}
+		if !found {
+			t.Errorf("Expected the drift to mention %q, got %v", expected, drift)
+		}
+	}
+}
@@ -3,43 +3,123 @@ package mongodb
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/dappnode/validator-monitoring/listener/internal/logger"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
-func GetMongoDbClient(uri string) (*mongo.Client, error) {
-	// The URI includes the credentials
-	var client *mongo.Client
-	var err error // Declare err here to ensure it's accessible outside the loop
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// ClientConfig is how the listener connects to MongoDB. Zero values keep the defaults of the driver or the URI
+type ClientConfig struct {
+	// URI includes the credentials
+	URI                    string
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	MinPoolSize            uint64
+	MaxPoolSize            uint64
+	// ReadConcern is a read concern level, e.g. majority
+	ReadConcern string
+	// WriteConcern is majority or a number of nodes
+	WriteConcern string
+	// ConnectAttempts is how many times the server is pinged before giving up, waiting between attempts from
+	// InitialBackoff, doubling every attempt, up to MaxBackoff
+	ConnectAttempts int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+}
 
-	for attempt := 1; attempt <= 5; attempt++ {
-		client, err = mongo.Connect(ctx, options.Client().ApplyURI(uri))
-		if err != nil {
-			logger.Warn(fmt.Sprintf("Attempt %d: Failed to initiate connection to MongoDB: %v", attempt, err))
-			time.Sleep(time.Second * 5) // Wait for 5 seconds before retrying
-			continue                    // Proceed to the next iteration of the loop
-		}
+func GetMongoDbClient(cfg ClientConfig) (*mongo.Client, error) {
+	clientOptions, err := getClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Connect only validates the options, the connection is established in the background
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate connection to MongoDB: %w", err)
+	}
 
-		// Attempt to ping the database
-		pingCtx, pingCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	attempts := max(cfg.ConnectAttempts, 1)
+	pingTimeout := cfg.ServerSelectionTimeout
+	if pingTimeout <= 0 {
+		pingTimeout = 30 * time.Second // the default server selection timeout of the driver
+	}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		// every attempt has its own timeout, so a slow attempt does not consume the next ones
+		pingCtx, pingCancel := context.WithTimeout(context.Background(), pingTimeout)
 		err = client.Ping(pingCtx, nil)
 		pingCancel()
-
 		if err == nil {
 			logger.Info("Connected to MongoDB!")
 			return client, nil // Successfully connected and pinged MongoDB
 		}
+		if attempt == attempts {
+			break
+		}
+
+		delay := backoffDelay(attempt, cfg.InitialBackoff, cfg.MaxBackoff)
+		logger.Warn(fmt.Sprintf("Attempt %d: Failed to ping MongoDB, retrying in %s: %v", attempt, delay, err))
+		time.Sleep(delay)
+	}
+
+	client.Disconnect(context.Background())
+	return nil, fmt.Errorf("after %d attempts, failed to connect to MongoDB: %w", attempts, err)
+}
+
+func getClientOptions(cfg ClientConfig) (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+	if cfg.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(cfg.ConnectTimeout)
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(cfg.ServerSelectionTimeout)
+	}
+	if cfg.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(cfg.MinPoolSize)
+	}
+	if cfg.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	if cfg.ReadConcern != "" {
+		clientOptions.SetReadConcern(&readconcern.ReadConcern{Level: cfg.ReadConcern})
+	}
+	if cfg.WriteConcern != "" {
+		writeConcern, err := ParseWriteConcern(cfg.WriteConcern)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetWriteConcern(writeConcern)
+	}
+	if err := clientOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MongoDB options: %w", err)
+	}
+	return clientOptions, nil
+}
 
-		logger.Warn(fmt.Sprintf("Attempt %d: Failed to ping MongoDB: %v", attempt, err))
-		time.Sleep(time.Second * 5) // Wait for 5 seconds before retrying
+// ParseWriteConcern parses a write concern, majority or the number of nodes that must acknowledge the writes
+func ParseWriteConcern(value string) (*writeconcern.WriteConcern, error) {
+	if value == "majority" {
+		return writeconcern.Majority(), nil
+	}
+	nodes, err := strconv.Atoi(value)
+	if err != nil || nodes < 0 {
+		return nil, fmt.Errorf("invalid write concern %q, must be majority or a number of nodes", value)
 	}
+	return &writeconcern.WriteConcern{W: nodes}, nil
+}
 
-	// After exiting the loop, either connection initiation or ping has consistently failed
-	return nil, fmt.Errorf("after several attempts, failed to connect to MongoDB: %v", err) // Keeping fmt for error return
+// backoffDelay returns the delay after the failed attempt, starting at initial and doubling every attempt up to max
+func backoffDelay(attempt int, initial time.Duration, max time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
 }
@@ -0,0 +1,33 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt, expected := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		60: 10 * time.Second,
+	} {
+		if delay := backoffDelay(attempt, time.Second, 10*time.Second); delay != expected {
+			t.Errorf("Attempt %d: expected delay %s, got %s", attempt, expected, delay)
+		}
+	}
+}
+
+func TestParseWriteConcern(t *testing.T) {
+	if writeConcern, err := ParseWriteConcern("majority"); err != nil || writeConcern.W != "majority" {
+		t.Errorf("Expected majority, got %v, %v", writeConcern, err)
+	}
+	if writeConcern, err := ParseWriteConcern("2"); err != nil || writeConcern.W != 2 {
+		t.Errorf("Expected 2 nodes, got %v, %v", writeConcern, err)
+	}
+	if _, err := ParseWriteConcern("all"); err == nil {
+		t.Errorf("Expected an error for an invalid write concern")
+	}
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetPostgresPool connects to Postgres, retrying while the server is starting
+func GetPostgresPool(url string) (*pgxpool.Pool, error) {
+	// The URL includes the credentials
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Postgres URL: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Postgres pool: %w", err)
+	}
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		pingCtx, pingCancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err = pool.Ping(pingCtx)
+		pingCancel()
+		if err == nil {
+			logger.Info("Connected to Postgres!")
+			return pool, nil
+		}
+		logger.Warn(fmt.Sprintf("Attempt %d: Failed to ping Postgres: %v", attempt, err))
+		time.Sleep(time.Second * 5) // Wait for 5 seconds before retrying
+	}
+
+	pool.Close()
+	return nil, fmt.Errorf("after several attempts, failed to connect to Postgres: %w", err)
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateToEmbedded moves the entries of the time-series collection back into their validator documents, keeping the
+// maxEntriesPerBson newest of every validator, and drops the time-series collection. It is the Down of the time-series
+// migration, see migrations.All. It must run while no listener is writing, and can be run again if it is interrupted:
+// the time-series collection is only dropped once every document has its entries. Returns the number of documents
+// migrated
+func MigrateToEmbedded(ctx context.Context, collection *mongo.Collection, maxEntriesPerBson int) (int, error) {
+	entries := collection.Database().Collection(EntriesCollectionName)
+
+	cursor, err := collection.Find(ctx, bson.M{"entries": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var summary timeSeriesSummary
+		if err := cursor.Decode(&summary); err != nil {
+			return count, err
+		}
+		// the newest entries, put back oldest first as they are pushed
+		findOptions := options.Find().SetSort(bson.M{"receivedAt": -1}).SetLimit(int64(maxEntriesPerBson))
+		entriesCursor, err := entries.Find(ctx, keyEntriesFilter(summary.ValidatorKey), findOptions)
+		if err != nil {
+			return count, err
+		}
+		var timeSeriesEntries []timeSeriesEntry
+		if err := entriesCursor.All(ctx, &timeSeriesEntries); err != nil {
+			return count, err
+		}
+		validatorEntries := make([]Entry, len(timeSeriesEntries))
+		for i, entry := range timeSeriesEntries {
+			validatorEntries[len(timeSeriesEntries)-1-i] = entry.Entry
+		}
+
+		update := bson.M{"$set": bson.M{"entries": validatorEntries}, "$unset": bson.M{"lastEntryAt": ""}}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": summary.ID}, update); err != nil {
+			return count, fmt.Errorf("failed to migrate pubkey %s: %w", summary.Pubkey, err)
+		}
+		count++
+		if count%1000 == 0 {
+			logger.Info(fmt.Sprintf("Migrated %d documents", count))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+
+	if err := entries.Drop(ctx); err != nil {
+		return count, fmt.Errorf("failed to drop %s: %w", EntriesCollectionName, err)
+	}
+	// the index of the last entry is not used anymore
+	if _, err := collection.Indexes().DropOne(ctx, "lastEntryAt"); err != nil && !isIndexNotFound(err) {
+		return count, fmt.Errorf("failed to drop index lastEntryAt: %w", err)
+	}
+	return count, mongodb.EnsureIndexes(ctx, collection, embeddedIndexes)
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// embeddedLayoutFilter matches the validator documents that still have their entries embedded
+var embeddedLayoutFilter = bson.M{"entries": bson.M{"$exists": true}}
+
+// MigrateToTimeSeries moves the embedded entries of every validator document to the time-series collection, leaving
+// only the summary fields in the document. It is the Up of the time-series migration, see migrations.All. It must run
+// while no listener is writing, and can be run again if it is interrupted: the entries of a validator are replaced
+// before they are removed from its document. Returns the number of documents migrated
+func MigrateToTimeSeries(ctx context.Context, collection *mongo.Collection, retention time.Duration) (int, error) {
+	s, err := newMongoTimeSeriesStore(ctx, collection, retention)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := collection.Find(ctx, embeddedLayoutFilter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var validator Validator
+		if err := cursor.Decode(&validator); err != nil {
+			return count, err
+		}
+		if err := s.replaceValidator(ctx, validator.ValidatorKey, validator.Status, validator.Entries); err != nil {
+			return count, fmt.Errorf("failed to migrate pubkey %s: %w", validator.Pubkey, err)
+		}
+		count++
+		if count%1000 == 0 {
+			logger.Info(fmt.Sprintf("Migrated %d documents", count))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+
+	// the index of the embedded timestamps is not used anymore
+	if _, err := collection.Indexes().DropOne(ctx, "entries_timestamp"); err != nil && !isIndexNotFound(err) {
+		return count, fmt.Errorf("failed to drop index entries_timestamp: %w", err)
+	}
+	return count, s.EnsureIndexes(ctx)
+}
+
+func isIndexNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Name == "IndexNotFound"
+}
+
+// checkTimeSeriesLayout fails if any validator document still has its entries embedded, the time-series store would
+// ignore them
+func checkTimeSeriesLayout(ctx context.Context, collection *mongo.Collection) error {
+	embedded, err := collection.CountDocuments(ctx, embeddedLayoutFilter)
+	if err != nil {
+		return err
+	}
+	if embedded > 0 {
+		return fmt.Errorf("%d documents still have their entries embedded, stop the listener and run 'listener migrate up' with the time-series storage mode", embedded)
+	}
+	return nil
+}
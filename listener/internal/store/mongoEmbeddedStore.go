@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// embeddedIndexes of the signatures collection. The unique one also prevents concurrent upserts from creating
+// duplicated documents for the same validator
+var embeddedIndexes = []mongodb.Index{
+	{Name: "network_pubkey_tag_unique", Keys: bson.D{{Key: "network", Value: 1}, {Key: "pubkey", Value: 1}, {Key: "tag", Value: 1}}, Unique: true},
+	{Name: "status", Keys: bson.D{{Key: "status", Value: 1}}},
+	{Name: "tag", Keys: bson.D{{Key: "tag", Value: 1}}},
+	{Name: "entries_timestamp", Keys: bson.D{{Key: "entries.decodedPayload.timestamp", Value: 1}}},
+}
+
+// mongoEmbeddedStore keeps one document per validator with its entries in an array
+type mongoEmbeddedStore struct {
+	collection        *mongo.Collection
+	maxEntriesPerBson int
+}
+
+func (s *mongoEmbeddedStore) InsertSignature(ctx context.Context, key ValidatorKey, status types.Status, entry Entry) error {
+	filter := keyFilter(key)
+
+	// Check the number of entries
+	var result struct {
+		Entries []bson.M `bson:"entries"`
+	}
+	err := s.collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"entries": 1})).Decode(&result)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	// mongo DB has a limit of 16MB per document
+	// if this limit is reached the following exception is thrown: `write exception: write errors: [Resulting document after update is larger than 16777216]`
+	if len(result.Entries) >= s.maxEntriesPerBson {
+		return fmt.Errorf("%w for pubkey %s. Max entries per pubkey: %d", ErrMaxEntries, key.Pubkey, s.maxEntriesPerBson)
+	}
+
+	update := bson.M{
+		"$push": bson.M{
+			"entries": entry,
+		},
+	}
+	// Only update status unknown -> active
+	if status == types.Active {
+		update["$set"] = bson.M{"status": status}
+	} else {
+		update["$setOnInsert"] = bson.M{"status": status}
+	}
+
+	upsert := options.Update().SetUpsert(true)
+	_, err = s.collection.UpdateOne(ctx, filter, update, upsert)
+	// two concurrent upserts of a new document can both try to insert it, the unique index rejects one of
+	// them, which must update the document the other one inserted
+	if mongo.IsDuplicateKeyError(err) {
+		_, err = s.collection.UpdateOne(ctx, filter, update, upsert)
+	}
+	return err
+}
+
+func (s *mongoEmbeddedStore) GetSignatures(ctx context.Context, filter Filter) ([]Validator, error) {
+	cursor, err := s.find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var validators []Validator
+	if err := cursor.All(ctx, &validators); err != nil {
+		return nil, err
+	}
+	return validators, nil
+}
+
+func (s *mongoEmbeddedStore) ForEachValidator(ctx context.Context, filter Filter, fn func(Validator) error) error {
+	cursor, err := s.find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var validator Validator
+		if err := cursor.Decode(&validator); err != nil {
+			return err
+		}
+		if err := fn(validator); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// find returns the documents matching the filter, with only the entries matching its metadata
+func (s *mongoEmbeddedStore) find(ctx context.Context, filter Filter) (*mongo.Cursor, error) {
+	if len(filter.Metadata) == 0 {
+		return s.collection.Find(ctx, scopeFilter(filter, ""))
+	}
+	return s.collection.Aggregate(ctx, getMetadataPipeline(scopeFilter(filter, ""), filter.Metadata))
+}
+
+// getMetadataPipeline returns the aggregation that keeps only the entries matching every metadata filter, and only the
+// documents with at least one of them
+func getMetadataPipeline(scope bson.M, metadataFilter map[string]string) mongo.Pipeline {
+	elemMatch := bson.M{}
+	entryConditions := bson.A{}
+	for field, value := range metadataFilter {
+		elemMatch["metadata."+field] = value
+		entryConditions = append(entryConditions, bson.M{"$eq": bson.A{"$$entry.metadata." + field, value}})
+	}
+
+	match := bson.M{"entries": bson.M{"$elemMatch": elemMatch}}
+	for key, value := range scope {
+		match[key] = value
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{
+			"entries": bson.M{"$filter": bson.M{
+				"input": "$entries",
+				"as":    "entry",
+				"cond":  bson.M{"$and": entryConditions},
+			}},
+		}}},
+	}
+}
+
+func (s *mongoEmbeddedStore) ImportValidator(ctx context.Context, validator Validator) error {
+	// the _id of the existing document is kept, it can not be replaced
+	validator.ID = nil
+	_, err := s.collection.ReplaceOne(ctx, keyFilter(validator.ValidatorKey), validator, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *mongoEmbeddedStore) GetValidatorKeysByStatus(ctx context.Context, status types.Status) ([]ValidatorKey, error) {
+	return findValidatorKeys(ctx, s.collection, status)
+}
+
+func (s *mongoEmbeddedStore) UpdateStatus(ctx context.Context, key ValidatorKey, status types.Status, onlyIf types.Status) error {
+	_, err := s.collection.UpdateOne(ctx, statusFilter(key, onlyIf), bson.M{"$set": bson.M{"status": status}})
+	return err
+}
+
+func (s *mongoEmbeddedStore) DeleteValidator(ctx context.Context, key ValidatorKey, onlyIf types.Status) error {
+	_, err := s.collection.DeleteOne(ctx, statusFilter(key, onlyIf))
+	return err
+}
+
+func (s *mongoEmbeddedStore) RemoveEntries(ctx context.Context, key ValidatorKey, signatures []string) error {
+	update := bson.M{"$pull": bson.M{"entries": bson.M{"signature": bson.M{"$in": signatures}}}}
+	_, err := s.collection.UpdateOne(ctx, keyFilter(key), update)
+	return err
+}
+
+// RemoveOldSignatures removes the entries older than the retention, and the documents left without entries. The
+// embedded entries have no receive time, their age is the payload timestamp
+func (s *mongoEmbeddedStore) RemoveOldSignatures(ctx context.Context, retention time.Duration) (int64, error) {
+	// payload timestamps are unix seconds, strings of the same length compare as numbers
+	targetTime := time.Now().Add(-retention).Unix()
+	oldEntry := bson.M{
+		"decodedPayload.timestamp": bson.M{
+			"$lt": fmt.Sprintf("%d", targetTime), // Compare timestamps as strings
+		},
+	}
+	_, err := s.collection.UpdateMany(ctx, bson.M{"entries": bson.M{"$elemMatch": oldEntry}}, bson.M{"$pull": bson.M{"entries": oldEntry}})
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.collection.DeleteMany(ctx, bson.M{"entries": bson.M{"$size": 0}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (s *mongoEmbeddedStore) EnsureIndexes(ctx context.Context) error {
+	return mongodb.EnsureIndexes(ctx, s.collection, embeddedIndexes)
+}
+
+func (s *mongoEmbeddedStore) CheckIndexes(ctx context.Context) ([]string, error) {
+	return mongodb.CheckIndexes(ctx, s.collection, embeddedIndexes)
+}
+
+func (s *mongoEmbeddedStore) Ping(ctx context.Context) error {
+	return s.collection.Database().Client().Ping(ctx, readpref.Primary())
+}
+
+// keyFilter returns the filter of the document of the validator
+func keyFilter(key ValidatorKey) bson.M {
+	return bson.M{"network": key.Network, "pubkey": key.Pubkey, "tag": key.Tag}
+}
+
+// statusFilter returns the filter of the document of the validator if it has the status, whatever its status if empty
+func statusFilter(key ValidatorKey, status types.Status) bson.M {
+	filter := keyFilter(key)
+	if status != "" {
+		filter["status"] = status
+	}
+	return filter
+}
+
+// scopeFilter returns the filter that restricts a query to the tags, networks and pubkeys of the filter. prefix is
+// prepended to the field names, for documents where the key is nested
+func scopeFilter(filter Filter, prefix string) bson.M {
+	scope := bson.M{}
+	if filter.Tags != nil {
+		scope[prefix+"tag"] = bson.M{"$in": filter.Tags}
+	}
+	if filter.Networks != nil {
+		scope[prefix+"network"] = bson.M{"$in": filter.Networks}
+	}
+	if filter.Pubkeys != nil {
+		scope[prefix+"pubkey"] = bson.M{"$in": filter.Pubkeys}
+	}
+	return scope
+}
+
+// findValidatorKeys returns the keys of the validator documents of the collection with the status
+func findValidatorKeys(ctx context.Context, collection *mongo.Collection, status types.Status) ([]ValidatorKey, error) {
+	projection := bson.M{"pubkey": 1, "tag": 1, "network": 1}
+	cursor, err := collection.Find(ctx, bson.M{"status": status}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []ValidatorKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
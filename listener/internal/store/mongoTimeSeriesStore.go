@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// EntriesCollectionName is the time-series collection of the entries, in the database of the signatures
+const EntriesCollectionName = "signature_entries"
+
+// timeSeriesSummaryIndexes of the collection of the validators, as the embedded ones but the entries are not there
+var timeSeriesSummaryIndexes = []mongodb.Index{
+	{Name: "network_pubkey_tag_unique", Keys: bson.D{{Key: "network", Value: 1}, {Key: "pubkey", Value: 1}, {Key: "tag", Value: 1}}, Unique: true},
+	{Name: "status", Keys: bson.D{{Key: "status", Value: 1}}},
+	{Name: "tag", Keys: bson.D{{Key: "tag", Value: 1}}},
+	{Name: "lastEntryAt", Keys: bson.D{{Key: "lastEntryAt", Value: 1}}},
+}
+
+// timeSeriesEntriesIndexes of the entries collection. The first one is created by mongo with the collection
+var timeSeriesEntriesIndexes = []mongodb.Index{
+	{Name: "validator_1_receivedAt_1", Keys: bson.D{{Key: "validator", Value: 1}, {Key: "receivedAt", Value: 1}}},
+	{Name: "validator_network_pubkey_tag", Keys: bson.D{{Key: "validator.network", Value: 1}, {Key: "validator.pubkey", Value: 1}, {Key: "validator.tag", Value: 1}, {Key: "receivedAt", Value: 1}}},
+	{Name: "validator_tag", Keys: bson.D{{Key: "validator.tag", Value: 1}, {Key: "receivedAt", Value: 1}}},
+}
+
+// timeSeriesSummary is the document of a validator in the time-series mode, its entries are in the entries collection
+type timeSeriesSummary struct {
+	ID           interface{} `bson:"_id,omitempty"`
+	ValidatorKey `bson:",inline"`
+	Status       types.Status `bson:"status"`
+	LastEntryAt  time.Time    `bson:"lastEntryAt"`
+}
+
+// timeSeriesEntry is the document of an entry. validator is the meta field, entries of the same validator are stored
+// together
+type timeSeriesEntry struct {
+	ReceivedAt time.Time    `bson:"receivedAt"`
+	Validator  ValidatorKey `bson:"validator"`
+	Entry      `bson:",inline"`
+}
+
+// mongoTimeSeriesStore keeps a summary document per validator and every entry as a document of a time-series
+// collection, which mongo expires after the retention. There is no limit of entries per validator
+type mongoTimeSeriesStore struct {
+	collection *mongo.Collection
+	entries    *mongo.Collection
+}
+
+func newMongoTimeSeriesStore(ctx context.Context, collection *mongo.Collection, retention time.Duration) (*mongoTimeSeriesStore, error) {
+	if err := createEntriesCollection(ctx, collection.Database(), retention); err != nil {
+		return nil, err
+	}
+	return &mongoTimeSeriesStore{
+		collection: collection,
+		entries:    collection.Database().Collection(EntriesCollectionName),
+	}, nil
+}
+
+// createEntriesCollection creates the time-series collection of the entries, or updates its expiration if it exists.
+// Time-series collections must be created explicitly, mongo would create a regular one on the first insert
+func createEntriesCollection(ctx context.Context, db *mongo.Database, retention time.Duration) error {
+	expireAfterSeconds := int64(retention.Seconds())
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": EntriesCollectionName})
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	if len(names) > 0 {
+		command := bson.D{{Key: "collMod", Value: EntriesCollectionName}, {Key: "expireAfterSeconds", Value: expireAfterSeconds}}
+		if err := db.RunCommand(ctx, command).Err(); err != nil {
+			return fmt.Errorf("failed to update the expiration of %s: %w", EntriesCollectionName, err)
+		}
+		return nil
+	}
+
+	timeSeries := options.TimeSeries().SetTimeField("receivedAt").SetMetaField("validator").SetGranularity("hours")
+	createOptions := options.CreateCollection().SetTimeSeriesOptions(timeSeries).SetExpireAfterSeconds(expireAfterSeconds)
+	if err := db.CreateCollection(ctx, EntriesCollectionName, createOptions); err != nil {
+		return fmt.Errorf("failed to create %s: %w", EntriesCollectionName, err)
+	}
+	return nil
+}
+
+func (s *mongoTimeSeriesStore) InsertSignature(ctx context.Context, key ValidatorKey, status types.Status, entry Entry) error {
+	// the time the listener received the entry, the payload timestamp is set by the client
+	receivedAt := time.Now()
+	update := bson.M{"$max": bson.M{"lastEntryAt": receivedAt}}
+	// Only update status unknown -> active
+	if status == types.Active {
+		update["$set"] = bson.M{"status": status}
+	} else {
+		update["$setOnInsert"] = bson.M{"status": status}
+	}
+
+	filter := keyFilter(key)
+	upsert := options.Update().SetUpsert(true)
+	_, err := s.collection.UpdateOne(ctx, filter, update, upsert)
+	// two concurrent upserts of a new document can both try to insert it, the unique index rejects one of
+	// them, which must update the document the other one inserted
+	if mongo.IsDuplicateKeyError(err) {
+		_, err = s.collection.UpdateOne(ctx, filter, update, upsert)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.entries.InsertOne(ctx, timeSeriesEntry{ReceivedAt: receivedAt, Validator: key, Entry: entry})
+	return err
+}
+
+// GetSignatures reads the summaries and the entries with a query each, and joins them in the shape of the embedded
+// documents
+func (s *mongoTimeSeriesStore) GetSignatures(ctx context.Context, filter Filter) ([]Validator, error) {
+	cursor, err := s.collection.Find(ctx, scopeFilter(filter, ""))
+	if err != nil {
+		return nil, err
+	}
+	var summaries []timeSeriesSummary
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+
+	validators := make([]Validator, len(summaries))
+	positions := make(map[ValidatorKey]int, len(summaries))
+	for i, summary := range summaries {
+		validators[i] = summary.toValidator()
+		positions[summary.ValidatorKey] = i
+	}
+
+	entriesCursor, err := s.entries.Find(ctx, entriesFilter(filter), options.Find().SetSort(bson.M{"receivedAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer entriesCursor.Close(ctx)
+	for entriesCursor.Next(ctx) {
+		var entry timeSeriesEntry
+		if err := entriesCursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		// entries of a validator just removed, they are about to be removed too
+		i, ok := positions[entry.Validator]
+		if !ok {
+			continue
+		}
+		validators[i].Entries = append(validators[i].Entries, entry.Entry)
+	}
+	if err := entriesCursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(filter.Metadata) == 0 {
+		return validators, nil
+	}
+	// as the embedded store, only the validators with entries matching the metadata are returned
+	matching := []Validator{}
+	for _, validator := range validators {
+		if len(validator.Entries) > 0 {
+			matching = append(matching, validator)
+		}
+	}
+	return matching, nil
+}
+
+func (s *mongoTimeSeriesStore) ForEachValidator(ctx context.Context, filter Filter, fn func(Validator) error) error {
+	cursor, err := s.collection.Find(ctx, scopeFilter(filter, ""))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var summary timeSeriesSummary
+		if err := cursor.Decode(&summary); err != nil {
+			return err
+		}
+		validator := summary.toValidator()
+		validatorFilter := Filter{
+			Tags:     []string{string(summary.Tag)},
+			Networks: []string{string(summary.Network)},
+			Pubkeys:  []string{summary.Pubkey},
+			Metadata: filter.Metadata,
+		}
+		if err := s.readEntries(ctx, validatorFilter, &validator); err != nil {
+			return err
+		}
+		if len(filter.Metadata) > 0 && len(validator.Entries) == 0 {
+			continue
+		}
+		if err := fn(validator); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// readEntries appends the entries matching the filter to the validator
+func (s *mongoTimeSeriesStore) readEntries(ctx context.Context, filter Filter, validator *Validator) error {
+	cursor, err := s.entries.Find(ctx, entriesFilter(filter), options.Find().SetSort(bson.M{"receivedAt": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var entry timeSeriesEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		validator.Entries = append(validator.Entries, entry.Entry)
+	}
+	return cursor.Err()
+}
+
+func (s *mongoTimeSeriesStore) ImportValidator(ctx context.Context, validator Validator) error {
+	return s.replaceValidator(ctx, validator.ValidatorKey, validator.Status, validator.Entries)
+}
+
+// replaceValidator replaces the summary and the entries of the validator. Running it again with the same entries
+// gives the same result
+func (s *mongoTimeSeriesStore) replaceValidator(ctx context.Context, key ValidatorKey, status types.Status, entries []Entry) error {
+	summary := timeSeriesSummary{ValidatorKey: key, Status: status}
+	documents := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		receivedAt := entryTime(entry)
+		if receivedAt.After(summary.LastEntryAt) {
+			summary.LastEntryAt = receivedAt
+		}
+		documents[i] = timeSeriesEntry{ReceivedAt: receivedAt, Validator: key, Entry: entry}
+	}
+	// a validator without entries is kept until it is considered old
+	if summary.LastEntryAt.IsZero() {
+		summary.LastEntryAt = time.Now()
+	}
+
+	if _, err := s.entries.DeleteMany(ctx, keyEntriesFilter(key)); err != nil {
+		return err
+	}
+	if len(documents) > 0 {
+		if _, err := s.entries.InsertMany(ctx, documents); err != nil {
+			return err
+		}
+	}
+	_, err := s.collection.ReplaceOne(ctx, keyFilter(key), summary, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *mongoTimeSeriesStore) GetValidatorKeysByStatus(ctx context.Context, status types.Status) ([]ValidatorKey, error) {
+	return findValidatorKeys(ctx, s.collection, status)
+}
+
+func (s *mongoTimeSeriesStore) UpdateStatus(ctx context.Context, key ValidatorKey, status types.Status, onlyIf types.Status) error {
+	_, err := s.collection.UpdateOne(ctx, statusFilter(key, onlyIf), bson.M{"$set": bson.M{"status": status}})
+	return err
+}
+
+func (s *mongoTimeSeriesStore) DeleteValidator(ctx context.Context, key ValidatorKey, onlyIf types.Status) error {
+	result, err := s.collection.DeleteOne(ctx, statusFilter(key, onlyIf))
+	if err != nil {
+		return err
+	}
+	// the validator has another status, its entries are kept
+	if result.DeletedCount == 0 && onlyIf != "" {
+		return nil
+	}
+	_, err = s.entries.DeleteMany(ctx, keyEntriesFilter(key))
+	return err
+}
+
+func (s *mongoTimeSeriesStore) RemoveEntries(ctx context.Context, key ValidatorKey, signatures []string) error {
+	filter := keyEntriesFilter(key)
+	filter["signature"] = bson.M{"$in": signatures}
+	_, err := s.entries.DeleteMany(ctx, filter)
+	return err
+}
+
+// RemoveOldSignatures removes the entries older than the retention, which mongo also expires on its own, and the
+// validators left without entries, the ones whose last entry is older than the retention
+func (s *mongoTimeSeriesStore) RemoveOldSignatures(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	if _, err := s.entries.DeleteMany(ctx, bson.M{"receivedAt": bson.M{"$lt": cutoff}}); err != nil {
+		return 0, err
+	}
+	result, err := s.collection.DeleteMany(ctx, bson.M{"lastEntryAt": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (s *mongoTimeSeriesStore) EnsureIndexes(ctx context.Context) error {
+	if err := mongodb.EnsureIndexes(ctx, s.collection, timeSeriesSummaryIndexes); err != nil {
+		return err
+	}
+	return mongodb.EnsureIndexes(ctx, s.entries, timeSeriesEntriesIndexes)
+}
+
+func (s *mongoTimeSeriesStore) CheckIndexes(ctx context.Context) ([]string, error) {
+	drift, err := mongodb.CheckIndexes(ctx, s.collection, timeSeriesSummaryIndexes)
+	if err != nil {
+		return nil, err
+	}
+	entriesDrift, err := mongodb.CheckIndexes(ctx, s.entries, timeSeriesEntriesIndexes)
+	if err != nil {
+		return nil, err
+	}
+	return append(drift, entriesDrift...), nil
+}
+
+func (s *mongoTimeSeriesStore) Ping(ctx context.Context) error {
+	return s.collection.Database().Client().Ping(ctx, readpref.Primary())
+}
+
+func (summary timeSeriesSummary) toValidator() Validator {
+	return Validator{ID: summary.ID, ValidatorKey: summary.ValidatorKey, Status: summary.Status, Entries: []Entry{}}
+}
+
+// entriesFilter returns the filter of the entries of the validators and metadata of the filter
+func entriesFilter(filter Filter) bson.M {
+	entries := scopeFilter(filter, "validator.")
+	for field, value := range filter.Metadata {
+		entries["metadata."+field] = value
+	}
+	return entries
+}
+
+// keyEntriesFilter returns the filter of every entry of the validator
+func keyEntriesFilter(key ValidatorKey) bson.M {
+	return bson.M{"validator.network": key.Network, "validator.pubkey": key.Pubkey, "validator.tag": key.Tag}
+}
+
+// entryTime returns the receive time of an entry imported or migrated, whose actual receive time is unknown: the
+// timestamp of its payload, which is the time the proof was signed. The timestamp is set by the client, so it is never
+// later than now, an entry in the future would never expire. Entries without a valid timestamp are considered received
+// now
+func entryTime(entry Entry) time.Time {
+	now := time.Now()
+	timestamp, _ := entry.DecodedPayload["timestamp"].(string)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || seconds > now.Unix() {
+		return now
+	}
+	return time.Unix(seconds, 0)
+}
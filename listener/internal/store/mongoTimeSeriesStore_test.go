@@ -0,0 +1,25 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEntryTime(t *testing.T) {
+	past := time.Unix(time.Now().Add(-time.Hour).Unix(), 0)
+	entry := Entry{DecodedPayload: map[string]interface{}{"timestamp": strconv.FormatInt(past.Unix(), 10)}}
+	if received := entryTime(entry); !received.Equal(past) {
+		t.Errorf("Expected a past payload timestamp to be kept, got %s instead of %s", received, past)
+	}
+	for description, timestamp := range map[string]string{
+		"future timestamp":    strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10),
+		"milliseconds":        strconv.FormatInt(time.Now().UnixMilli(), 10),
+		"malformed timestamp": "yesterday",
+	} {
+		entry := Entry{DecodedPayload: map[string]interface{}{"timestamp": timestamp}}
+		if received := entryTime(entry); time.Since(received) > time.Minute || received.After(time.Now()) {
+			t.Errorf("%s: expected the entry to be considered received now, got %s", description, received)
+		}
+	}
+}
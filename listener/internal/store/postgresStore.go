@@ -0,0 +1,286 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresIndexes are the indexes created by the Postgres migrations, per table
+var postgresIndexes = []struct {
+	table   string
+	indexes []string
+}{
+	{table: "validators", indexes: []string{"validators_pkey", "validators_network_pubkey_tag_unique", "validators_status", "validators_tag"}},
+	{table: "signature_entries", indexes: []string{"signature_entries_pkey", "signature_entries_validator_received_at", "signature_entries_received_at", "signature_entries_metadata"}},
+}
+
+// postgresStore keeps a row per validator in the validators table and a row per entry in the signature_entries table.
+// The tables are created by the Postgres migrations, see migrations.PostgresAll
+type postgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore returns the store of the Postgres database of the pool, which must be on the latest schema
+func NewPostgresStore(pool *pgxpool.Pool) SignatureStore {
+	return &postgresStore{pool: pool}
+}
+
+func (s *postgresStore) InsertSignature(ctx context.Context, key ValidatorKey, status types.Status, entry Entry) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// Only update status unknown -> active
+		var validatorID int64
+		err := tx.QueryRow(ctx, `
+INSERT INTO validators (network, pubkey, tag, status) VALUES ($1, $2, $3, $4)
+ON CONFLICT (network, pubkey, tag) DO UPDATE
+SET status = CASE WHEN EXCLUDED.status = $5 THEN EXCLUDED.status ELSE validators.status END
+RETURNING id`, key.Network, key.Pubkey, key.Tag, status, types.Active).Scan(&validatorID)
+		if err != nil {
+			return err
+		}
+		// the time the listener received the entry, the payload timestamp is set by the client
+		return insertPostgresEntries(ctx, tx, validatorID, []Entry{entry}, time.Now())
+	})
+}
+
+// insertPostgresEntries inserts the entries of the validator, received at receivedAt. A zero receivedAt means the
+// receive time is unknown, see entryTime
+func insertPostgresEntries(ctx context.Context, tx pgx.Tx, validatorID int64, entries []Entry, receivedAt time.Time) error {
+	for _, entry := range entries {
+		entryReceivedAt := receivedAt
+		if entryReceivedAt.IsZero() {
+			entryReceivedAt = entryTime(entry)
+		}
+		var submitter *string
+		if entry.Submitter != "" {
+			submitter = &entry.Submitter
+		}
+		_, err := tx.Exec(ctx, `
+INSERT INTO signature_entries (validator_id, received_at, payload, signature, decoded_payload, submitter, metadata)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`, validatorID, entryReceivedAt, entry.Payload, entry.Signature, entry.DecodedPayload, submitter, entry.Metadata)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *postgresStore) GetSignatures(ctx context.Context, filter Filter) ([]Validator, error) {
+	validators := []Validator{}
+	err := s.ForEachValidator(ctx, filter, func(validator Validator) error {
+		validators = append(validators, validator)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return validators, nil
+}
+
+// ForEachValidator reads the validators and their entries with a single query, ordered by validator
+func (s *postgresStore) ForEachValidator(ctx context.Context, filter Filter, fn func(Validator) error) error {
+	conditions, args := postgresScopeConditions(filter)
+	// with a metadata filter only the validators with matching entries are returned, as in MongoDB
+	join := "LEFT JOIN"
+	entryCondition := ""
+	if len(filter.Metadata) > 0 {
+		join = "JOIN"
+		args = append(args, filter.Metadata)
+		entryCondition = fmt.Sprintf(" AND e.metadata @> $%d", len(args))
+	}
+	query := fmt.Sprintf(`
+SELECT v.id, v.network, v.pubkey, v.tag, v.status, e.payload, e.signature, e.decoded_payload, e.submitter, e.metadata
+FROM validators v
+%s signature_entries e ON e.validator_id = v.id%s
+WHERE %s
+ORDER BY v.id, e.received_at, e.id`, join, entryCondition, strings.Join(conditions, " AND "))
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *Validator
+	for rows.Next() {
+		var validator Validator
+		var id int64
+		var payload, signature, submitter *string
+		var entry Entry
+		if err := rows.Scan(&id, &validator.Network, &validator.Pubkey, &validator.Tag, &validator.Status, &payload, &signature, &entry.DecodedPayload, &submitter, &entry.Metadata); err != nil {
+			return err
+		}
+		if current == nil || current.ID != id {
+			if current != nil {
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+			validator.ID = id
+			validator.Entries = []Entry{}
+			current = &validator
+		}
+		// a validator without entries has a single row without entry
+		if payload == nil {
+			continue
+		}
+		entry.Payload, entry.Signature = *payload, *signature
+		if submitter != nil {
+			entry.Submitter = *submitter
+		}
+		current.Entries = append(current.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// postgresScopeConditions returns the conditions on the validators table v of the scope of the filter, and their
+// arguments
+func postgresScopeConditions(filter Filter) ([]string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	for _, scope := range []struct {
+		column string
+		values []string
+	}{{"v.tag", filter.Tags}, {"v.network", filter.Networks}, {"v.pubkey", filter.Pubkeys}} {
+		if scope.values == nil {
+			continue
+		}
+		args = append(args, scope.values)
+		conditions = append(conditions, fmt.Sprintf("%s = ANY($%d)", scope.column, len(args)))
+	}
+	return conditions, args
+}
+
+func (s *postgresStore) ImportValidator(ctx context.Context, validator Validator) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var validatorID int64
+		err := tx.QueryRow(ctx, `
+INSERT INTO validators (network, pubkey, tag, status) VALUES ($1, $2, $3, $4)
+ON CONFLICT (network, pubkey, tag) DO UPDATE SET status = EXCLUDED.status
+RETURNING id`, validator.Network, validator.Pubkey, validator.Tag, validator.Status).Scan(&validatorID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM signature_entries WHERE validator_id = $1", validatorID); err != nil {
+			return err
+		}
+		return insertPostgresEntries(ctx, tx, validatorID, validator.Entries, time.Time{})
+	})
+}
+
+func (s *postgresStore) GetValidatorKeysByStatus(ctx context.Context, status types.Status) ([]ValidatorKey, error) {
+	rows, err := s.pool.Query(ctx, "SELECT network, pubkey, tag FROM validators WHERE status = $1", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []ValidatorKey{}
+	for rows.Next() {
+		var key ValidatorKey
+		if err := rows.Scan(&key.Network, &key.Pubkey, &key.Tag); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *postgresStore) UpdateStatus(ctx context.Context, key ValidatorKey, status types.Status, onlyIf types.Status) error {
+	_, err := s.pool.Exec(ctx, `
+UPDATE validators SET status = $1
+WHERE network = $2 AND pubkey = $3 AND tag = $4 AND ($5 = '' OR status = $5)`, status, key.Network, key.Pubkey, key.Tag, onlyIf)
+	return err
+}
+
+// DeleteValidator removes the validator, its entries are removed by the foreign key
+func (s *postgresStore) DeleteValidator(ctx context.Context, key ValidatorKey, onlyIf types.Status) error {
+	_, err := s.pool.Exec(ctx, `
+DELETE FROM validators
+WHERE network = $1 AND pubkey = $2 AND tag = $3 AND ($4 = '' OR status = $4)`, key.Network, key.Pubkey, key.Tag, onlyIf)
+	return err
+}
+
+func (s *postgresStore) RemoveEntries(ctx context.Context, key ValidatorKey, signatures []string) error {
+	_, err := s.pool.Exec(ctx, `
+DELETE FROM signature_entries
+WHERE validator_id = (SELECT id FROM validators WHERE network = $1 AND pubkey = $2 AND tag = $3) AND signature = ANY($4)`,
+		key.Network, key.Pubkey, key.Tag, signatures)
+	return err
+}
+
+// RemoveOldSignatures removes the entries older than the retention, and the validators left without entries
+func (s *postgresStore) RemoveOldSignatures(ctx context.Context, retention time.Duration) (int64, error) {
+	var deleted int64
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM signature_entries WHERE received_at < $1", time.Now().Add(-retention)); err != nil {
+			return err
+		}
+		result, err := tx.Exec(ctx, "DELETE FROM validators v WHERE NOT EXISTS (SELECT 1 FROM signature_entries e WHERE e.validator_id = v.id)")
+		if err != nil {
+			return err
+		}
+		deleted = result.RowsAffected()
+		return nil
+	})
+	return deleted, err
+}
+
+// EnsureIndexes does nothing, the indexes are created by the Postgres migrations with the tables
+func (s *postgresStore) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (s *postgresStore) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+func (s *postgresStore) CheckIndexes(ctx context.Context) ([]string, error) {
+	rows, err := s.pool.Query(ctx, "SELECT tablename, indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename IN ('validators', 'signature_entries')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+	existing := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, index string
+		if err := rows.Scan(&table, &index); err != nil {
+			return nil, fmt.Errorf("failed to list indexes: %w", err)
+		}
+		if existing[table] == nil {
+			existing[table] = make(map[string]bool)
+		}
+		existing[table][index] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	drift := []string{}
+	for _, table := range postgresIndexes {
+		required := make(map[string]bool)
+		for _, index := range table.indexes {
+			required[index] = true
+			if !existing[table.table][index] {
+				drift = append(drift, "index "+index+" of "+table.table+" is missing")
+			}
+		}
+		for index := range existing[table.table] {
+			if !required[index] {
+				drift = append(drift, "index "+index+" of "+table.table+" is not managed by the listener")
+			}
+		}
+	}
+	return drift, nil
+}
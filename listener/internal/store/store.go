@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Databases the signatures can be stored in, see Config.Database
+const (
+	DatabaseMongoDB  = "mongodb"
+	DatabasePostgres = "postgres"
+)
+
+// Storage modes of MongoDB, see Config.StorageMode
+const (
+	// ModeEmbedded stores one document per validator with its entries embedded, limited by maxEntriesPerBson
+	ModeEmbedded = "embedded"
+	// ModeTimeSeries stores one document per entry in a time-series collection that expires them, and one summary
+	// document per validator
+	ModeTimeSeries = "timeseries"
+)
+
+// ErrMaxEntries is returned when a validator already has the max number of entries allowed
+var ErrMaxEntries = errors.New("max number of entries reached")
+
+// ValidatorKey identifies the signatures of a validator, there is one document per key
+type ValidatorKey struct {
+	Network types.Network `json:"network" bson:"network"`
+	Pubkey  string        `json:"pubkey" bson:"pubkey"`
+	Tag     types.Tag     `json:"tag" bson:"tag"`
+}
+
+// Entry is a stored signature
+type Entry struct {
+	Payload        string                 `json:"payload" bson:"payload"`
+	Signature      string                 `json:"signature" bson:"signature"`
+	DecodedPayload map[string]interface{} `json:"decodedPayload" bson:"decodedPayload"`
+	// Submitter is the id of the authenticated submitter, empty if submitter auth is disabled
+	Submitter string          `json:"submitter,omitempty" bson:"submitter,omitempty"`
+	Metadata  *types.Metadata `json:"metadata,omitempty" bson:"metadata,omitempty"`
+}
+
+// Validator is a validator with its entries, in the shape returned by GET /signatures
+type Validator struct {
+	// ID is the id given by the database, an ObjectID in MongoDB and a number in Postgres. Ignored on import
+	ID           interface{} `json:"_id,omitempty" bson:"_id,omitempty"`
+	ValidatorKey `bson:",inline"`
+	Status       types.Status `json:"status" bson:"status"`
+	Entries      []Entry      `json:"entries" bson:"entries"`
+}
+
+// Filter restricts the validators and entries read. nil fields do not restrict
+type Filter struct {
+	Tags     []string
+	Networks []string
+	Pubkeys  []string
+	// Metadata keeps only the entries whose metadata has every field with the given value, and the validators with
+	// at least one of them
+	Metadata map[string]string
+}
+
+// SignatureStore is where the signatures are stored. Every operation of the API, the crons and the maintenance
+// commands goes through it, so the storage layout can change without touching them.
+type SignatureStore interface {
+	// InsertSignature adds an entry to the validator, creating it if needed. The status only goes from unknown to
+	// active, a new validator gets the given status
+	InsertSignature(ctx context.Context, key ValidatorKey, status types.Status, entry Entry) error
+	// GetSignatures returns the validators matching the filter with their entries
+	GetSignatures(ctx context.Context, filter Filter) ([]Validator, error)
+	// ForEachValidator calls fn with every validator matching the filter, loading them one by one
+	ForEachValidator(ctx context.Context, filter Filter, fn func(Validator) error) error
+	// ImportValidator replaces the validator and its entries
+	ImportValidator(ctx context.Context, validator Validator) error
+	// GetValidatorKeysByStatus returns the keys of the validators with the status
+	GetValidatorKeysByStatus(ctx context.Context, status types.Status) ([]ValidatorKey, error)
+	// UpdateStatus sets the status of the validator, only if its current status is onlyIf. An empty onlyIf updates it
+	// whatever its status
+	UpdateStatus(ctx context.Context, key ValidatorKey, status types.Status, onlyIf types.Status) error
+	// DeleteValidator removes the validator and its entries, only if its current status is onlyIf. An empty onlyIf
+	// removes it whatever its status
+	DeleteValidator(ctx context.Context, key ValidatorKey, onlyIf types.Status) error
+	// RemoveEntries removes the entries of the validator with the given signatures
+	RemoveEntries(ctx context.Context, key ValidatorKey, signatures []string) error
+	// RemoveOldSignatures removes the entries older than the retention, keeping the newer entries of the same
+	// validator, and then the validators left without entries. Returns the number of validators removed
+	RemoveOldSignatures(ctx context.Context, retention time.Duration) (int64, error)
+	// EnsureIndexes creates the indexes the store relies on
+	EnsureIndexes(ctx context.Context) error
+	// CheckIndexes returns the differences between the existing indexes and the required ones
+	CheckIndexes(ctx context.Context) ([]string, error)
+	// Ping checks that the database can be reached
+	Ping(ctx context.Context) error
+}
+
+// NewMongoStore returns the store of the storage mode. collection is the collection of the validators, the entries of
+// the time-series mode are stored in a collection of the same database. retention is how long entries are kept
+func NewMongoStore(ctx context.Context, mode string, collection *mongo.Collection, maxEntriesPerBson int, retention time.Duration) (SignatureStore, error) {
+	switch mode {
+	case ModeEmbedded:
+		return &mongoEmbeddedStore{collection: collection, maxEntriesPerBson: maxEntriesPerBson}, nil
+	case ModeTimeSeries:
+		if err := checkTimeSeriesLayout(ctx, collection); err != nil {
+			return nil, err
+		}
+		return newMongoTimeSeriesStore(ctx, collection, retention)
+	default:
+		return nil, fmt.Errorf("unknown storage mode %s", mode)
+	}
+}
@@ -0,0 +1,270 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+	"github.com/dappnode/validator-monitoring/listener/internal/migrations"
+	"github.com/dappnode/validator-monitoring/listener/internal/store"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The tests run against every backend with a test server configured, and are skipped for the others:
+// MONGO_TEST_URI (both storage modes) and POSTGRES_TEST_URL. Every test gets an empty database
+const (
+	mongoTestURIEnv    = "MONGO_TEST_URI"
+	postgresTestURLEnv = "POSTGRES_TEST_URL"
+)
+
+// testBackends returns a fresh store of every backend, by name
+func testBackends(t *testing.T) map[string]store.SignatureStore {
+	ctx := context.Background()
+	backends := make(map[string]store.SignatureStore)
+
+	if uri := os.Getenv(mongoTestURIEnv); uri != "" {
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			t.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		t.Cleanup(func() { client.Disconnect(ctx) })
+		for _, mode := range []string{store.ModeEmbedded, store.ModeTimeSeries} {
+			db := client.Database(fmt.Sprintf("storeTest_%s_%d", mode, time.Now().UnixNano()))
+			t.Cleanup(func() { db.Drop(ctx) })
+			signatureStore, err := store.NewMongoStore(ctx, mode, db.Collection("signatures"), 30, 720*time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to open the %s store: %v", mode, err)
+			}
+			if err := signatureStore.EnsureIndexes(ctx); err != nil {
+				t.Fatalf("Failed to create the indexes of the %s store: %v", mode, err)
+			}
+			backends["mongodb_"+mode] = signatureStore
+		}
+	}
+
+	if url := os.Getenv(postgresTestURLEnv); url != "" {
+		pool := newPostgresTestPool(t, url)
+		if err := migrations.CheckPostgresSchema(ctx, pool); err != nil {
+			t.Fatalf("Failed to migrate Postgres: %v", err)
+		}
+		backends["postgres"] = store.NewPostgresStore(pool)
+	}
+
+	if len(backends) == 0 {
+		t.Skipf("No test database, set %s or %s", mongoTestURIEnv, postgresTestURLEnv)
+	}
+	return backends
+}
+
+// newPostgresTestPool connects to url with a new schema of its own, dropped at the end of the test, so every test
+// starts from the migrations, whatever tables they create, and the tests of other packages do not interfere
+func newPostgresTestPool(t *testing.T, url string) *pgxpool.Pool {
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	schema := fmt.Sprintf("store_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("Failed to create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("Invalid Postgres URL: %v", err)
+	}
+	poolConfig.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func newTestEntry(signature string, age time.Duration, metadata *types.Metadata) store.Entry {
+	return store.Entry{
+		Payload:   "payload-" + signature,
+		Signature: signature,
+		DecodedPayload: map[string]interface{}{
+			"type":      "PROOF_OF_VALIDATION",
+			"platform":  "dappnode",
+			"timestamp": strconv.FormatInt(time.Now().Add(-age).Unix(), 10),
+		},
+		Metadata: metadata,
+	}
+}
+
+func signaturesOf(validator store.Validator) []string {
+	signatures := []string{}
+	for _, entry := range validator.Entries {
+		signatures = append(signatures, entry.Signature)
+	}
+	return signatures
+}
+
+func TestSignatureStore(t *testing.T) {
+	ctx := context.Background()
+	solo := store.ValidatorKey{Network: "holesky", Pubkey: "0x01", Tag: "solo"}
+	lido := store.ValidatorKey{Network: "holesky", Pubkey: "0x02", Tag: "lido"}
+	mainnet := store.ValidatorKey{Network: "mainnet", Pubkey: "0x03", Tag: "solo"}
+
+	for name, signatureStore := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			lighthouse := &types.Metadata{ConsensusClient: "lighthouse"}
+			for _, insert := range []struct {
+				key    store.ValidatorKey
+				status types.Status
+				entry  store.Entry
+			}{
+				{solo, types.Unknown, newTestEntry("0xa1", 2*time.Hour, lighthouse)},
+				{solo, types.Active, newTestEntry("0xa2", time.Hour, nil)},
+				// unknown does not override active
+				{solo, types.Unknown, newTestEntry("0xa3", 0, lighthouse)},
+				{lido, types.Unknown, newTestEntry("0xb1", 0, nil)},
+				{mainnet, types.Active, newTestEntry("0xc1", 0, nil)},
+			} {
+				if err := signatureStore.InsertSignature(ctx, insert.key, insert.status, insert.entry); err != nil {
+					t.Fatalf("Failed to insert signature %s: %v", insert.entry.Signature, err)
+				}
+			}
+
+			// the scope of the filter
+			validators, err := signatureStore.GetSignatures(ctx, store.Filter{Tags: []string{"solo"}, Networks: []string{"holesky"}})
+			if err != nil {
+				t.Fatalf("Failed to get signatures: %v", err)
+			}
+			if len(validators) != 1 || validators[0].ValidatorKey != solo {
+				t.Fatalf("Expected only the solo validator of holesky, got %+v", validators)
+			}
+			if validators[0].Status != types.Active {
+				t.Errorf("Expected status active, got %s", validators[0].Status)
+			}
+			if got := signaturesOf(validators[0]); fmt.Sprint(got) != "[0xa1 0xa2 0xa3]" {
+				t.Errorf("Expected the entries in order, got %v", got)
+			}
+			if validators[0].Entries[0].DecodedPayload["type"] != "PROOF_OF_VALIDATION" {
+				t.Errorf("Expected the decoded payload to be stored, got %v", validators[0].Entries[0].DecodedPayload)
+			}
+
+			// the metadata filter keeps only the matching entries and validators
+			validators, err = signatureStore.GetSignatures(ctx, store.Filter{Tags: []string{"solo", "lido"}, Metadata: map[string]string{"consensusClient": "lighthouse"}})
+			if err != nil {
+				t.Fatalf("Failed to get signatures by metadata: %v", err)
+			}
+			if len(validators) != 1 || fmt.Sprint(signaturesOf(validators[0])) != "[0xa1 0xa3]" {
+				t.Errorf("Expected the lighthouse entries of the solo validator, got %+v", validators)
+			}
+
+			keys, err := signatureStore.GetValidatorKeysByStatus(ctx, types.Unknown)
+			if err != nil {
+				t.Fatalf("Failed to get unknown validators: %v", err)
+			}
+			if len(keys) != 1 || keys[0] != lido {
+				t.Errorf("Expected the lido validator to be unknown, got %v", keys)
+			}
+
+			// conditional status changes
+			if err := signatureStore.DeleteValidator(ctx, solo, types.Unknown); err != nil {
+				t.Fatalf("Failed to delete validator: %v", err)
+			}
+			if err := signatureStore.UpdateStatus(ctx, lido, types.Active, types.Unknown); err != nil {
+				t.Fatalf("Failed to update status: %v", err)
+			}
+			if keys, _ := signatureStore.GetValidatorKeysByStatus(ctx, types.Active); len(keys) != 3 {
+				t.Errorf("Expected the 3 validators to be active, got %v", keys)
+			}
+
+			if err := signatureStore.RemoveEntries(ctx, solo, []string{"0xa2"}); err != nil {
+				t.Fatalf("Failed to remove entries: %v", err)
+			}
+			validators, _ = signatureStore.GetSignatures(ctx, store.Filter{Pubkeys: []string{solo.Pubkey}})
+			if len(validators) != 1 || fmt.Sprint(signaturesOf(validators[0])) != "[0xa1 0xa3]" {
+				t.Errorf("Expected the entry 0xa2 to be removed, got %+v", validators)
+			}
+
+			// export and import back
+			exported := []store.Validator{}
+			if err := signatureStore.ForEachValidator(ctx, store.Filter{}, func(validator store.Validator) error {
+				exported = append(exported, validator)
+				return nil
+			}); err != nil {
+				t.Fatalf("Failed to iterate validators: %v", err)
+			}
+			if len(exported) != 3 {
+				t.Fatalf("Expected 3 validators, got %d", len(exported))
+			}
+			for _, validator := range exported {
+				if err := signatureStore.ImportValidator(ctx, validator); err != nil {
+					t.Fatalf("Failed to import validator %s: %v", validator.Pubkey, err)
+				}
+			}
+			if validators, _ := signatureStore.GetSignatures(ctx, store.Filter{}); len(validators) != 3 {
+				t.Errorf("Expected importing to replace the validators, got %d validators", len(validators))
+			}
+
+			if drift, err := signatureStore.CheckIndexes(ctx); err != nil || len(drift) > 0 {
+				t.Errorf("Expected no index drift, got %v, %v", drift, err)
+			}
+
+			if err := signatureStore.DeleteValidator(ctx, mainnet, ""); err != nil {
+				t.Fatalf("Failed to delete validator: %v", err)
+			}
+			if validators, _ := signatureStore.GetSignatures(ctx, store.Filter{Networks: []string{"mainnet"}}); len(validators) != 0 {
+				t.Errorf("Expected the mainnet validator to be deleted, got %+v", validators)
+			}
+		})
+	}
+}
+
+func TestSignatureStoreRemoveOldSignatures(t *testing.T) {
+	ctx := context.Background()
+	recent := store.ValidatorKey{Network: "holesky", Pubkey: "0x01", Tag: "solo"}
+	old := store.ValidatorKey{Network: "holesky", Pubkey: "0x02", Tag: "solo"}
+	mixed := store.ValidatorKey{Network: "holesky", Pubkey: "0x03", Tag: "solo"}
+
+	for name, signatureStore := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// imported, since new signatures are received now whatever their payload timestamp
+			for _, validator := range []store.Validator{
+				{ValidatorKey: recent, Status: types.Active, Entries: []store.Entry{newTestEntry("0xa1", 0, nil)}},
+				{ValidatorKey: old, Status: types.Active, Entries: []store.Entry{newTestEntry("0xb1", 48*time.Hour, nil)}},
+				{ValidatorKey: mixed, Status: types.Active, Entries: []store.Entry{newTestEntry("0xc1", 48*time.Hour, nil), newTestEntry("0xc2", time.Hour, nil)}},
+			} {
+				if err := signatureStore.ImportValidator(ctx, validator); err != nil {
+					t.Fatalf("Failed to import validator: %v", err)
+				}
+			}
+
+			deleted, err := signatureStore.RemoveOldSignatures(ctx, 24*time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to remove old signatures: %v", err)
+			}
+			if deleted != 1 {
+				t.Errorf("Expected 1 validator to be removed, got %d", deleted)
+			}
+			validators, _ := signatureStore.GetSignatures(ctx, store.Filter{})
+			kept := make(map[store.ValidatorKey][]string)
+			for _, validator := range validators {
+				kept[validator.ValidatorKey] = signaturesOf(validator)
+			}
+			if len(kept) != 2 || len(kept[recent]) != 1 {
+				t.Errorf("Expected the recent and mixed validators to be kept, got %+v", kept)
+			}
+			// only the old entry of a validator with a fresh one is removed
+			if signatures := kept[mixed]; len(signatures) != 1 || signatures[0] != "0xc2" {
+				t.Errorf("Expected only the fresh entry of the mixed validator to be kept, got %v", signatures)
+			}
+		})
+	}
+}
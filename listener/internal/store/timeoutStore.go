@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/dappnode/validator-monitoring/listener/internal/api/types"
+)
+
+// timeoutStore sets a deadline on every operation of the store it wraps, so a slow database fails the operation
+// instead of holding the request or the cron job
+type timeoutStore struct {
+	SignatureStore
+	timeout time.Duration
+}
+
+// WithTimeout returns the store with a deadline of timeout on every operation. ForEachValidator, RemoveOldSignatures
+// and EnsureIndexes go through every validator, so they are only limited by the context of the caller
+func WithTimeout(signatureStore SignatureStore, timeout time.Duration) SignatureStore {
+	return &timeoutStore{SignatureStore: signatureStore, timeout: timeout}
+}
+
+func (s *timeoutStore) InsertSignature(ctx context.Context, key ValidatorKey, status types.Status, entry Entry) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.InsertSignature(ctx, key, status, entry)
+}
+
+func (s *timeoutStore) GetSignatures(ctx context.Context, filter Filter) ([]Validator, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.GetSignatures(ctx, filter)
+}
+
+func (s *timeoutStore) ImportValidator(ctx context.Context, validator Validator) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.ImportValidator(ctx, validator)
+}
+
+func (s *timeoutStore) GetValidatorKeysByStatus(ctx context.Context, status types.Status) ([]ValidatorKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.GetValidatorKeysByStatus(ctx, status)
+}
+
+func (s *timeoutStore) UpdateStatus(ctx context.Context, key ValidatorKey, status types.Status, onlyIf types.Status) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.UpdateStatus(ctx, key, status, onlyIf)
+}
+
+func (s *timeoutStore) DeleteValidator(ctx context.Context, key ValidatorKey, onlyIf types.Status) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.DeleteValidator(ctx, key, onlyIf)
+}
+
+func (s *timeoutStore) RemoveEntries(ctx context.Context, key ValidatorKey, signatures []string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.RemoveEntries(ctx, key, signatures)
+}
+
+func (s *timeoutStore) CheckIndexes(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.CheckIndexes(ctx)
+}
+
+func (s *timeoutStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.SignatureStore.Ping(ctx)
+}